@@ -0,0 +1,185 @@
+package apikey
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/meruff/go-trailhead-leaderboard-api/ratelimit"
+)
+
+// Key is an API key issued to a client. RatePerMinute and Burst override the registry defaults
+// when set, and Admin keys may also use the admin routes.
+type Key struct {
+	Name          string  `json:"name"`
+	Key           string  `json:"key"`
+	RatePerMinute float64 `json:"ratePerMinute,omitempty"`
+	Burst         int     `json:"burst,omitempty"`
+	Admin         bool    `json:"admin,omitempty"`
+}
+
+// Usage counts the requests made with a key.
+type Usage struct {
+	Name          string     `json:"name"`
+	Admin         bool       `json:"admin"`
+	Requests      int64      `json:"requests"`
+	Rejected      int64      `json:"rejected"`
+	LastUsed      *time.Time `json:"lastUsed,omitempty"`
+	RatePerMinute float64    `json:"ratePerMinute"`
+	Burst         int        `json:"burst"`
+}
+
+// Client is a known key with its rate limiter and usage.
+type Client struct {
+	Key     Key
+	Limiter *ratelimit.Limiter
+
+	mu    sync.Mutex
+	usage Usage
+}
+
+// Record counts a request made with the client's key, and whether it was rejected for going over
+// the rate limit.
+func (c *Client) Record(rejected bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	c.usage.Requests++
+	c.usage.LastUsed = &now
+	if rejected {
+		c.usage.Rejected++
+	}
+}
+
+// Usage returns a snapshot of the client's usage.
+func (c *Client) Usage() Usage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.usage
+}
+
+// Registry holds the configured API keys. Keys are looked up by their SHA-256 hash so the raw
+// keys aren't kept in a map.
+type Registry struct {
+	clients map[[sha256.Size]byte]*Client
+}
+
+// NewRegistry returns a Registry for the given keys, limiting each to defaultRatePerMinute
+// requests per minute with bursts of defaultBurst unless the key sets its own limits. The default
+// rate must be positive and the default burst at least 1.
+func NewRegistry(keys []Key, defaultRatePerMinute float64, defaultBurst int) (*Registry, error) {
+	if defaultRatePerMinute <= 0 {
+		return nil, fmt.Errorf("default rate limit must be positive, got %g per minute", defaultRatePerMinute)
+	}
+	if defaultBurst < 1 {
+		return nil, fmt.Errorf("default burst must be at least 1, got %d", defaultBurst)
+	}
+
+	r := &Registry{clients: map[[sha256.Size]byte]*Client{}}
+
+	for _, key := range keys {
+		if key.Key == "" {
+			return nil, fmt.Errorf("API key %q has no key", key.Name)
+		}
+
+		hash := sha256.Sum256([]byte(key.Key))
+		if _, ok := r.clients[hash]; ok {
+			return nil, fmt.Errorf("API key %q is configured more than once", key.Name)
+		}
+
+		if key.RatePerMinute <= 0 {
+			key.RatePerMinute = defaultRatePerMinute
+		}
+		if key.Burst <= 0 {
+			key.Burst = defaultBurst
+		}
+
+		r.clients[hash] = &Client{
+			Key:     key,
+			Limiter: ratelimit.NewLimiter(key.RatePerMinute/60, key.Burst),
+			usage: Usage{
+				Name:          key.Name,
+				Admin:         key.Admin,
+				RatePerMinute: key.RatePerMinute,
+				Burst:         key.Burst,
+			},
+		}
+	}
+
+	return r, nil
+}
+
+// Enabled reports whether any keys are configured. With no keys the API is open to everyone.
+func (r *Registry) Enabled() bool {
+	return len(r.clients) > 0
+}
+
+// Lookup returns the Client for a raw API key.
+func (r *Registry) Lookup(key string) (*Client, bool) {
+	client, ok := r.clients[sha256.Sum256([]byte(key))]
+	return client, ok
+}
+
+// Usage returns the usage of every key, sorted by name.
+func (r *Registry) Usage() []Usage {
+	usage := make([]Usage, 0, len(r.clients))
+	for _, client := range r.clients {
+		usage = append(usage, client.Usage())
+	}
+
+	sort.Slice(usage, func(i, j int) bool {
+		return usage[i].Name < usage[j].Name
+	})
+
+	return usage
+}
+
+// LoadFile reads keys from a JSON file of the form {"keys": [{"name": "...", "key": "..."}]}.
+func LoadFile(path string) ([]Key, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Keys []Key `json:"keys"`
+	}
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("parsing API keys file %s: %w", path, err)
+	}
+
+	return file.Keys, nil
+}
+
+// ParseList parses name:key pairs, as listed in the config file or, comma separated, in environment
+// variables. A key without a name is named after its position in the list.
+func ParseList(list []string, admin bool) ([]Key, error) {
+	var keys []Key
+
+	for i, entry := range list {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, key, found := strings.Cut(entry, ":")
+		if !found {
+			name, key = fmt.Sprintf("key-%d", i+1), entry
+		}
+
+		if key == "" {
+			return nil, fmt.Errorf("API key %q has no key", name)
+		}
+
+		keys = append(keys, Key{Name: name, Key: key, Admin: admin})
+	}
+
+	return keys, nil
+}
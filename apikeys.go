@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/meruff/go-trailhead-leaderboard-api/apikey"
+	"github.com/meruff/go-trailhead-leaderboard-api/config"
+)
+
+// apiClientKey is the request context key holding the *apikey.Client making the request.
+type apiClientKey struct{}
+
+// apiKeysFromConfig builds the API key registry from the keys file and the keys and admin keys
+// listed in the settings, which also hold the default per-key limits.
+func apiKeysFromConfig(conf config.APIKeys) (*apikey.Registry, error) {
+	var keys []apikey.Key
+
+	if conf.File != "" {
+		fileKeys, err := apikey.LoadFile(conf.File)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, fileKeys...)
+	}
+
+	listedKeys, err := apikey.ParseList(conf.Keys, false)
+	if err != nil {
+		return nil, fmt.Errorf("apiKeys.keys (API_KEYS): %w", err)
+	}
+	keys = append(keys, listedKeys...)
+
+	adminKeys, err := apikey.ParseList(conf.AdminKeys, true)
+	if err != nil {
+		return nil, fmt.Errorf("apiKeys.adminKeys (API_ADMIN_KEYS): %w", err)
+	}
+	keys = append(keys, adminKeys...)
+
+	return apikey.NewRegistry(keys, config.Or(conf.RatePerMinute, 60), config.Or(conf.Burst, 20))
+}
+
+// keylessPaths are answered without an API key, so load balancers can check on the app.
+var keylessPaths = map[string]bool{"/healthz": true, "/readyz": true}
+
+// apiKeyHandler requires a valid API key on every request once any keys are configured, and
+// limits each key to its request rate. Rate limit state is reported in X-RateLimit-* headers.
+func apiKeyHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !apiKeys.Enabled() || keylessPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		client, ok := apiKeys.Lookup(requestAPIKey(r))
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeErrorToBrowser(w, "A valid API key is required in the X-API-Key header.", 401)
+			return
+		}
+
+		allowed, wait := client.Limiter.Allow()
+		client.Record(!allowed)
+
+		remaining, untilFull := client.Limiter.Status()
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(client.Limiter.Burst()))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		w.Header().Set("X-RateLimit-Reset", strconv.Itoa(int(math.Ceil(untilFull.Seconds()))))
+
+		if !allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			writeErrorToBrowser(w, "Rate limit exceeded, please slow down.", 429)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiClientKey{}, client)))
+	})
+}
+
+// requestAPIKey returns the API key sent with a request, from the X-API-Key header, a bearer
+// token, or the api_key query parameter for clients that can't set headers like <img> tags.
+func requestAPIKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+
+	return r.URL.Query().Get("api_key")
+}
+
+// requireAdminKey checks that the request was made with an admin API key. While no API keys are
+// configured the whole API is open, admin endpoints included, so every request passes. Writes an
+// error to the browser and returns false if it wasn't.
+func requireAdminKey(w http.ResponseWriter, r *http.Request) bool {
+	if !apiKeys.Enabled() {
+		return true
+	}
+
+	client, _ := r.Context().Value(apiClientKey{}).(*apikey.Client)
+	if client == nil || !client.Key.Admin {
+		writeErrorToBrowser(w, "An admin API key is required.", 403)
+		return false
+	}
+
+	return true
+}
+
+// usageHandler lists the usage of every API key, none while no keys are configured. Only admin
+// keys may use it.
+func usageHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdminKey(w, r) {
+		return
+	}
+
+	encodeAndWriteToBrowser(w, apiKeys.Usage())
+}
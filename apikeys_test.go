@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/meruff/go-trailhead-leaderboard-api/apikey"
+)
+
+func TestAPIKeysFromConfigRateLimit(t *testing.T) {
+	tests := []struct {
+		name, rate, burst string
+		wantErr           string
+	}{
+		{name: "defaults"},
+		{name: "custom limits", rate: "0.5", burst: "1"},
+		{name: "zero rate", rate: "0", wantErr: "(API_RATE_LIMIT_PER_MINUTE) must be positive"},
+		{name: "negative rate", rate: "-10", wantErr: "(API_RATE_LIMIT_PER_MINUTE) must not be negative"},
+		{name: "zero burst", burst: "0", wantErr: "(API_RATE_LIMIT_BURST) must be positive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("API_KEYS", "dashboard:s3cret")
+			t.Setenv("API_RATE_LIMIT_PER_MINUTE", tt.rate)
+			t.Setenv("API_RATE_LIMIT_BURST", tt.burst)
+
+			conf, err := configFromEnv()
+			if err == nil {
+				_, err = apiKeysFromConfig(conf.APIKeys)
+			}
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("apiKeysFromConfig() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("configFromEnv() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLeaderboardAndAliasWritesNeedAdminKey(t *testing.T) {
+	registry, err := apikey.NewRegistry([]apikey.Key{{Name: "dashboard", Key: "s3cret"}}, 60, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(previous *apikey.Registry) { apiKeys = previous }(apiKeys)
+	apiKeys = registry
+
+	client, _ := registry.Lookup("s3cret")
+
+	for name, handler := range map[string]http.HandlerFunc{
+		"PUT /leaderboards/team":    putLeaderboardHandler,
+		"DELETE /leaderboards/team": deleteLeaderboardHandler,
+		"POST /aliases/check":       checkHandlesHandler,
+	} {
+		method, path, _ := strings.Cut(name, " ")
+		req := httptest.NewRequest(method, path, strings.NewReader(`{"members": ["astro"]}`))
+		req = req.WithContext(context.WithValue(req.Context(), apiClientKey{}, client))
+
+		w := httptest.NewRecorder()
+		handler(w, req)
+
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s with a non-admin key = %d, want 403", name, w.Code)
+		}
+	}
+}
+
+func TestRequireAdminKey(t *testing.T) {
+	registry, err := apikey.NewRegistry([]apikey.Key{
+		{Name: "dashboard", Key: "s3cret"},
+		{Name: "ops", Key: "t0psecret", Admin: true},
+	}, 60, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	disabled, err := apikey.NewRegistry(nil, 60, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		registry   *apikey.Registry
+		key        string
+		wantStatus int
+		wantUsage  int
+	}{
+		{name: "keys disabled", registry: disabled, wantStatus: http.StatusOK, wantUsage: 0},
+		{name: "no key", registry: registry, wantStatus: http.StatusForbidden},
+		{name: "non-admin key", registry: registry, key: "s3cret", wantStatus: http.StatusForbidden},
+		{name: "admin key", registry: registry, key: "t0psecret", wantStatus: http.StatusOK, wantUsage: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(previous *apikey.Registry) { apiKeys = previous }(apiKeys)
+			apiKeys = tt.registry
+
+			req := httptest.NewRequest("GET", "/admin/usage", nil)
+			if client, ok := tt.registry.Lookup(tt.key); ok {
+				req = req.WithContext(context.WithValue(req.Context(), apiClientKey{}, client))
+			}
+
+			w := httptest.NewRecorder()
+			usageHandler(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("GET /admin/usage = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var usage []apikey.Usage
+			if err := json.Unmarshal(w.Body.Bytes(), &usage); err != nil {
+				t.Fatalf("body isn't valid JSON: %v\n%s", err, w.Body)
+			}
+			if len(usage) != tt.wantUsage {
+				t.Errorf("got usage of %d keys, want %d", len(usage), tt.wantUsage)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package badge
+
+import (
+	"bytes"
+	"strconv"
+	"text/template"
+	"unicode/utf8"
+)
+
+const (
+	// LabelColor is the background of the label half of a shield.
+	LabelColor = "#555"
+	// ValueColor is the default background of the value half of a shield.
+	ValueColor = "#0176d3"
+	// ErrorColor is the background of the value half of a shield reporting an error.
+	ErrorColor = "#e05d44"
+)
+
+// Shield is a shields.io style badge, a label and a value side by side.
+type Shield struct {
+	Label string
+	Value string
+	Color string
+}
+
+// shieldLayout is a Shield with the sizes needed to draw it.
+type shieldLayout struct {
+	Shield
+	LabelWidth int
+	ValueWidth int
+	Width      int
+}
+
+var shieldTemplate = template.Must(template.New("shield").Funcs(funcs).Parse(
+	`<svg xmlns="http://www.w3.org/2000/svg" width="{{.Width}}" height="20" role="img" aria-label="{{html .Label}}: {{html .Value}}">
+<title>{{html .Label}}: {{html .Value}}</title>
+<linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>
+<clipPath id="r"><rect width="{{.Width}}" height="20" rx="3" fill="#fff"/></clipPath>
+<g clip-path="url(#r)">
+<rect width="{{.LabelWidth}}" height="20" fill="` + LabelColor + `"/>
+<rect x="{{.LabelWidth}}" width="{{.ValueWidth}}" height="20" fill="{{html .Color}}"/>
+<rect width="{{.Width}}" height="20" fill="url(#s)"/>
+</g>
+<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">
+<text x="{{half .LabelWidth}}" y="15" fill="#010101" fill-opacity=".3">{{html .Label}}</text>
+<text x="{{half .LabelWidth}}" y="14">{{html .Label}}</text>
+<text x="{{add .LabelWidth (half .ValueWidth)}}" y="15" fill="#010101" fill-opacity=".3">{{html .Value}}</text>
+<text x="{{add .LabelWidth (half .ValueWidth)}}" y="14">{{html .Value}}</text>
+</g>
+</svg>
+`))
+
+// SVG draws the shield.
+func (s Shield) SVG() []byte {
+	if s.Color == "" {
+		s.Color = ValueColor
+	}
+
+	layout := shieldLayout{Shield: s, LabelWidth: textWidth(s.Label) + 10, ValueWidth: textWidth(s.Value) + 10}
+	layout.Width = layout.LabelWidth + layout.ValueWidth
+
+	return render(shieldTemplate, layout)
+}
+
+// Card is a Trailblazer's profile card: their photo, name, rank and totals.
+type Card struct {
+	Name         string
+	Handle       string
+	Company      string
+	PhotoURL     string
+	RankTitle    string
+	RankImageURL string
+	Points       int
+	Badges       int
+	Trails       int
+}
+
+var cardTemplate = template.Must(template.New("card").Funcs(funcs).Parse(
+	`<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="400" height="160" viewBox="0 0 400 160" role="img" aria-label="{{html .Name}}, {{html .RankTitle}}">
+<title>{{html .Name}}, {{html .RankTitle}}</title>
+<style>
+text { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; fill: #181818; }
+.name { font-size: 18px; font-weight: 600; }
+.muted { font-size: 12px; fill: #5c6b7a; }
+.total { font-size: 18px; font-weight: 600; }
+.caption { font-size: 11px; fill: #5c6b7a; text-transform: uppercase; }
+</style>
+<rect x="0.5" y="0.5" width="399" height="159" rx="8" fill="#fff" stroke="#dde3ea"/>
+<clipPath id="photo"><circle cx="52" cy="52" r="32"/></clipPath>
+<circle cx="52" cy="52" r="32" fill="#dde3ea"/>
+{{if .PhotoURL}}<image x="20" y="20" width="64" height="64" href="{{html .PhotoURL}}" xlink:href="{{html .PhotoURL}}" clip-path="url(#photo)" preserveAspectRatio="xMidYMid slice"/>
+{{end}}<text x="100" y="46" class="name">{{html .Name}}</text>
+<text x="100" y="66" class="muted">{{if .Company}}{{html .Company}}{{else}}@{{html .Handle}}{{end}}</text>
+{{if .RankImageURL}}<image x="324" y="20" width="56" height="56" href="{{html .RankImageURL}}" xlink:href="{{html .RankImageURL}}"/>
+{{end}}<line x1="20" y1="100" x2="380" y2="100" stroke="#dde3ea"/>
+<text x="20" y="128" class="total">{{count .Points}}</text>
+<text x="20" y="145" class="caption">Points</text>
+<text x="140" y="128" class="total">{{count .Badges}}</text>
+<text x="140" y="145" class="caption">Badges</text>
+<text x="260" y="128" class="total">{{count .Trails}}</text>
+<text x="260" y="145" class="caption">Trails</text>
+</svg>
+`))
+
+// SVG draws the card.
+func (c Card) SVG() []byte {
+	return render(cardTemplate, c)
+}
+
+// FormatCount formats a count with thousands separators, i.e. 123,456.
+func FormatCount(n int) string {
+	if n < 0 {
+		return "-" + FormatCount(-n)
+	}
+
+	s := strconv.Itoa(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+
+	return s
+}
+
+var funcs = template.FuncMap{
+	"add":   func(a, b int) int { return a + b },
+	"half":  func(n int) int { return n / 2 },
+	"count": FormatCount,
+}
+
+// render executes a template that can't fail on well formed data.
+func render(t *template.Template, data interface{}) []byte {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		panic(err)
+	}
+
+	return buf.Bytes()
+}
+
+// textWidth estimates the width in pixels of text drawn in 11px Verdana.
+func textWidth(text string) int {
+	return int(float64(utf8.RuneCountInString(text))*6.8 + 0.5)
+}
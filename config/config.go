@@ -0,0 +1,488 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// File is a config file. Every setting but the Trailhead request headers has an environment
+// variable, named by its env tag, that overrides it. Settings left out of the file are nil or
+// empty, so the app's defaults apply. A validate tag of positive or oneof=<words> adds to the
+// checks made by Load and ApplyEnv.
+type File struct {
+	Server    Server    `yaml:"server" toml:"server"`
+	Trailhead Trailhead `yaml:"trailhead" toml:"trailhead"`
+	Badges    Badges    `yaml:"badges" toml:"badges"`
+	Storage   Storage   `yaml:"storage" toml:"storage"`
+	APIKeys   APIKeys   `yaml:"apiKeys" toml:"apiKeys"`
+	CORS      CORS      `yaml:"cors" toml:"cors"`
+	Log       Log       `yaml:"log" toml:"log"`
+	Poll      Poll      `yaml:"poll" toml:"poll"`
+	Webhooks  Webhooks  `yaml:"webhooks" toml:"webhooks"`
+	Digest    Digest    `yaml:"digest" toml:"digest"`
+	SMTP      SMTP      `yaml:"smtp" toml:"smtp"`
+}
+
+// Server configures the HTTP server.
+type Server struct {
+	Listen            string    `yaml:"listen" toml:"listen" env:"LISTEN_ADDR"`
+	ReadTimeout       *Duration `yaml:"readTimeout" toml:"readTimeout" env:"HTTP_READ_TIMEOUT"`
+	ReadHeaderTimeout *Duration `yaml:"readHeaderTimeout" toml:"readHeaderTimeout" env:"HTTP_READ_HEADER_TIMEOUT"`
+	WriteTimeout      *Duration `yaml:"writeTimeout" toml:"writeTimeout" env:"HTTP_WRITE_TIMEOUT"`
+	IdleTimeout       *Duration `yaml:"idleTimeout" toml:"idleTimeout" env:"HTTP_IDLE_TIMEOUT"`
+	MaxHeaderBytes    *int      `yaml:"maxHeaderBytes" toml:"maxHeaderBytes" env:"HTTP_MAX_HEADER_BYTES" validate:"positive"`
+	ShutdownTimeout   *Duration `yaml:"shutdownTimeout" toml:"shutdownTimeout" env:"SHUTDOWN_TIMEOUT" validate:"positive"`
+	TLS               TLS       `yaml:"tls" toml:"tls"`
+}
+
+// TLS configures the server to terminate TLS itself.
+type TLS struct {
+	CertFile       string    `yaml:"certFile" toml:"certFile" env:"TLS_CERT_FILE"`
+	KeyFile        string    `yaml:"keyFile" toml:"keyFile" env:"TLS_KEY_FILE"`
+	ClientCAFile   string    `yaml:"clientCaFile" toml:"clientCaFile" env:"TLS_CLIENT_CA_FILE"`
+	ClientAuth     string    `yaml:"clientAuth" toml:"clientAuth" env:"TLS_CLIENT_AUTH" validate:"oneof=require optional"`
+	ReloadInterval *Duration `yaml:"reloadInterval" toml:"reloadInterval" env:"TLS_RELOAD_INTERVAL" validate:"positive"`
+}
+
+// Trailhead configures callouts to Trailhead.
+type Trailhead struct {
+	GraphqlURL string            `yaml:"graphqlUrl" toml:"graphqlUrl" env:"TRAILHEAD_GRAPHQL_URL"`
+	ProfileURL string            `yaml:"profileUrl" toml:"profileUrl" env:"TRAILHEAD_PROFILE_URL"`
+	Headers    map[string]string `yaml:"headers" toml:"headers"`
+	Timeout    *Duration         `yaml:"timeout" toml:"timeout" env:"TRAILHEAD_TIMEOUT"`
+	MaxQPS     *float64          `yaml:"maxQps" toml:"maxQps" env:"TRAILHEAD_MAX_QPS"`
+	Burst      *int              `yaml:"burst" toml:"burst" env:"TRAILHEAD_BURST"`
+	Retry      Retry             `yaml:"retry" toml:"retry"`
+	Breaker    Breaker           `yaml:"breaker" toml:"breaker"`
+	StaleCache StaleCache        `yaml:"staleCache" toml:"staleCache"`
+	Probe      Probe             `yaml:"probe" toml:"probe"`
+	Identities Identities        `yaml:"identities" toml:"identities"`
+}
+
+// Retry configures retries of failed callouts to Trailhead.
+type Retry struct {
+	MaxAttempts     *int      `yaml:"maxAttempts" toml:"maxAttempts" env:"TRAILHEAD_RETRY_MAX_ATTEMPTS" validate:"positive"`
+	InitialInterval *Duration `yaml:"initialInterval" toml:"initialInterval" env:"TRAILHEAD_RETRY_INITIAL_INTERVAL"`
+	MaxInterval     *Duration `yaml:"maxInterval" toml:"maxInterval" env:"TRAILHEAD_RETRY_MAX_INTERVAL"`
+	Multiplier      *float64  `yaml:"multiplier" toml:"multiplier" env:"TRAILHEAD_RETRY_MULTIPLIER"`
+	Jitter          *float64  `yaml:"jitter" toml:"jitter" env:"TRAILHEAD_RETRY_JITTER"`
+	MaxElapsedTime  *Duration `yaml:"maxElapsedTime" toml:"maxElapsedTime" env:"TRAILHEAD_RETRY_MAX_ELAPSED_TIME"`
+}
+
+// Breaker configures the circuit breakers in front of Trailhead.
+type Breaker struct {
+	Failures    *int      `yaml:"failures" toml:"failures" env:"TRAILHEAD_BREAKER_FAILURES" validate:"positive"`
+	OpenTimeout *Duration `yaml:"openTimeout" toml:"openTimeout" env:"TRAILHEAD_BREAKER_OPEN_TIMEOUT"`
+}
+
+// StaleCache configures the responses kept to serve while a breaker is open.
+type StaleCache struct {
+	TTL  *Duration `yaml:"ttl" toml:"ttl" env:"TRAILHEAD_STALE_CACHE_TTL"`
+	Size *int      `yaml:"size" toml:"size" env:"TRAILHEAD_STALE_CACHE_SIZE"`
+}
+
+// Probe configures the checks of Trailhead reported at /status/upstream.
+type Probe struct {
+	Handle  string    `yaml:"handle" toml:"handle" env:"UPSTREAM_PROBE_HANDLE"`
+	Timeout *Duration `yaml:"timeout" toml:"timeout" env:"UPSTREAM_PROBE_TIMEOUT"`
+	Cache   *Duration `yaml:"cache" toml:"cache" env:"UPSTREAM_PROBE_CACHE"`
+}
+
+// Identities configures the cache of Trailblazer handles and user IDs.
+type Identities struct {
+	TTL *Duration `yaml:"ttl" toml:"ttl" env:"IDENTITY_CACHE_TTL" validate:"positive"`
+}
+
+// Badges configures the badge endpoints.
+type Badges struct {
+	DefaultCount *int     `yaml:"defaultCount" toml:"defaultCount" env:"BADGE_DEFAULT_COUNT" validate:"positive"`
+	Filters      []string `yaml:"filters" toml:"filters" env:"BADGE_FILTERS"`
+}
+
+// Storage names the files the app saves its state to.
+type Storage struct {
+	Leaderboards string `yaml:"leaderboards" toml:"leaderboards" env:"LEADERBOARD_STORE"`
+	Webhooks     string `yaml:"webhooks" toml:"webhooks" env:"WEBHOOK_STORE"`
+	Events       string `yaml:"events" toml:"events" env:"EVENT_STATE_STORE"`
+	Digest       string `yaml:"digest" toml:"digest" env:"DIGEST_STATE_STORE"`
+}
+
+// APIKeys configures the API keys clients must send. Keys and AdminKeys are name:key pairs.
+type APIKeys struct {
+	File          string   `yaml:"file" toml:"file" env:"API_KEYS_FILE"`
+	Keys          []string `yaml:"keys" toml:"keys" env:"API_KEYS"`
+	AdminKeys     []string `yaml:"adminKeys" toml:"adminKeys" env:"API_ADMIN_KEYS"`
+	RatePerMinute *float64 `yaml:"ratePerMinute" toml:"ratePerMinute" env:"API_RATE_LIMIT_PER_MINUTE" validate:"positive"`
+	Burst         *int     `yaml:"burst" toml:"burst" env:"API_RATE_LIMIT_BURST" validate:"positive"`
+}
+
+// CORS configures which browser apps on other origins may call the API.
+type CORS struct {
+	AllowedOrigins []string  `yaml:"allowedOrigins" toml:"allowedOrigins" env:"CORS_ALLOWED_ORIGINS"`
+	AllowedMethods []string  `yaml:"allowedMethods" toml:"allowedMethods" env:"CORS_ALLOWED_METHODS"`
+	AllowedHeaders []string  `yaml:"allowedHeaders" toml:"allowedHeaders" env:"CORS_ALLOWED_HEADERS"`
+	MaxAge         *Duration `yaml:"maxAge" toml:"maxAge" env:"CORS_MAX_AGE"`
+}
+
+// Log configures logging.
+type Log struct {
+	Level  string `yaml:"level" toml:"level" env:"LOG_LEVEL" validate:"oneof=debug info warn error"`
+	Format string `yaml:"format" toml:"format" env:"LOG_FORMAT" validate:"oneof=text json"`
+}
+
+// Poll configures how often leaderboard members are checked on.
+type Poll struct {
+	Handles *Duration `yaml:"handles" toml:"handles" env:"HANDLE_CHECK_INTERVAL" validate:"positive"`
+	Events  *Duration `yaml:"events" toml:"events" env:"EVENT_POLL_INTERVAL" validate:"positive"`
+}
+
+// Webhooks configures webhook deliveries.
+type Webhooks struct {
+	MaxAttempts *int      `yaml:"maxAttempts" toml:"maxAttempts" env:"WEBHOOK_MAX_ATTEMPTS" validate:"positive"`
+	Timeout     *Duration `yaml:"timeout" toml:"timeout" env:"WEBHOOK_TIMEOUT"`
+	// AllowedNetworks are the private networks, in CIDR notation, webhooks may be delivered to.
+	AllowedNetworks []string `yaml:"allowedNetworks" toml:"allowedNetworks" env:"WEBHOOK_ALLOWED_NETWORKS"`
+}
+
+// Digest configures the weekly digest.
+type Digest struct {
+	Schedule          string    `yaml:"schedule" toml:"schedule" env:"DIGEST_SCHEDULE"`
+	Timezone          string    `yaml:"timezone" toml:"timezone" env:"DIGEST_TIMEZONE"`
+	Recipients        []string  `yaml:"recipients" toml:"recipients" env:"DIGEST_RECIPIENTS"`
+	TopMovers         *int      `yaml:"topMovers" toml:"topMovers" env:"DIGEST_TOP_MOVERS"`
+	MaintenanceWindow *Duration `yaml:"maintenanceWindow" toml:"maintenanceWindow" env:"DIGEST_MAINTENANCE_WINDOW" validate:"positive"`
+}
+
+// SMTP configures the mail server digests are sent through.
+type SMTP struct {
+	Host     string    `yaml:"host" toml:"host" env:"SMTP_HOST"`
+	Port     *int      `yaml:"port" toml:"port" env:"SMTP_PORT"`
+	TLS      string    `yaml:"tls" toml:"tls" env:"SMTP_TLS" validate:"oneof=starttls tls none"`
+	Username string    `yaml:"username" toml:"username" env:"SMTP_USERNAME"`
+	Password string    `yaml:"password" toml:"password" env:"SMTP_PASSWORD"`
+	From     string    `yaml:"from" toml:"from" env:"SMTP_FROM"`
+	Timeout  *Duration `yaml:"timeout" toml:"timeout" env:"SMTP_TIMEOUT"`
+}
+
+// Duration is a time.Duration written like "500ms" or "2m".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return fmt.Errorf("must be a duration like 500ms or 2m, got %q", text)
+	}
+
+	*d = Duration(parsed)
+	return nil
+}
+
+// UnmarshalYAML decodes a duration, reporting the line of an invalid one since yaml.v3 doesn't.
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	if err := d.UnmarshalText([]byte(node.Value)); err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+
+	return nil
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// Or returns the duration, or fallback if it isn't set.
+func (d *Duration) Or(fallback time.Duration) time.Duration {
+	if d == nil {
+		return fallback
+	}
+
+	return time.Duration(*d)
+}
+
+// Or returns a number setting, or fallback if it isn't set.
+func Or[T int | float64](setting *T, fallback T) T {
+	if setting == nil {
+		return fallback
+	}
+
+	return *setting
+}
+
+// Load reads a YAML (.yaml or .yml) or TOML (.toml) config file. Unknown keys, values of the wrong
+// type, negative numbers and invalid URLs are errors.
+func Load(path string) (*File, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file File
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(raw))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("parsing config file %s: %w", path, err)
+		}
+
+	case ".toml":
+		meta, err := toml.Decode(string(raw), &file)
+		if err != nil {
+			return nil, fmt.Errorf("parsing config file %s: %w", path, err)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return nil, fmt.Errorf("config file %s has unknown key %s", path, undecoded[0])
+		}
+
+	default:
+		return nil, fmt.Errorf("config file %s must be .yaml, .yml or .toml", path)
+	}
+
+	if err := file.validate(); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	return &file, nil
+}
+
+// ApplyEnv overrides the file's settings with the environment variables named by their env tags,
+// found with lookup (i.e. os.LookupEnv), then validates the result the way Load validates the file.
+// Variables set to "" are ignored, like unset ones.
+func (f *File) ApplyEnv(lookup func(string) (string, bool)) error {
+	var errs []error
+
+	walk(reflect.ValueOf(f).Elem(), "", func(_ string, tag reflect.StructTag, value reflect.Value) {
+		env := tag.Get("env")
+		if env == "" {
+			return
+		}
+		raw, ok := lookup(env)
+		if !ok || raw == "" {
+			return
+		}
+		if err := parse(value, raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s %w", env, err))
+		}
+	})
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	return f.validate()
+}
+
+// parse sets a setting from its environment variable's value.
+func parse(value reflect.Value, raw string) error {
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+
+	case reflect.Slice:
+		var list []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		value.Set(reflect.ValueOf(list))
+
+	case reflect.Ptr:
+		parsed := reflect.New(value.Type().Elem())
+		switch p := parsed.Interface().(type) {
+		case *int:
+			i, err := strconv.Atoi(raw)
+			if err != nil {
+				return fmt.Errorf("must be a whole number, got %q", raw)
+			}
+			*p = i
+		case *float64:
+			f, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return fmt.Errorf("must be a number, got %q", raw)
+			}
+			*p = f
+		case *Duration:
+			if err := p.UnmarshalText([]byte(raw)); err != nil {
+				return err
+			}
+		}
+		value.Set(parsed)
+	}
+
+	return nil
+}
+
+// validate checks the settings are usable: numbers and durations aren't negative, and those
+// tagged positive aren't zero either; settings tagged oneof are one of the listed words, in any
+// case; the Trailhead URLs are absolute http(s) URLs; and settings that depend on each other agree.
+// Errors name both the key and the environment variable, since either may have set the value.
+func (f *File) validate() error {
+	var errs []error
+
+	walk(reflect.ValueOf(f).Elem(), "", func(key string, tag reflect.StructTag, value reflect.Value) {
+		if env := tag.Get("env"); env != "" {
+			key = fmt.Sprintf("%s (%s)", key, env)
+		}
+		rule := tag.Get("validate")
+
+		if options, ok := strings.CutPrefix(rule, "oneof="); ok {
+			if value.String() != "" && !containsFold(strings.Fields(options), value.String()) {
+				errs = append(errs, fmt.Errorf("%s must be %s, got %q", key, orList(strings.Fields(options)), value.String()))
+			}
+			return
+		}
+		if value.Kind() != reflect.Ptr || value.IsNil() {
+			return
+		}
+
+		var negative, zero bool
+		switch v := value.Elem().Interface().(type) {
+		case int:
+			negative, zero = v < 0, v == 0
+		case float64:
+			negative, zero = v < 0, v == 0
+		case Duration:
+			negative, zero = v < 0, v == 0
+		}
+
+		switch {
+		case negative:
+			errs = append(errs, fmt.Errorf("%s must not be negative, got %s", key, format(value.Elem().Interface())))
+		case zero && rule == "positive":
+			errs = append(errs, fmt.Errorf("%s must be positive, got %s", key, format(value.Elem().Interface())))
+		}
+	})
+
+	for key, value := range map[string]string{
+		"trailhead.graphqlUrl (TRAILHEAD_GRAPHQL_URL)": f.Trailhead.GraphqlURL,
+		"trailhead.profileUrl (TRAILHEAD_PROFILE_URL)": f.Trailhead.ProfileURL,
+	} {
+		if value == "" {
+			continue
+		}
+		if err := CheckURL(value); err != nil {
+			errs = append(errs, fmt.Errorf("%s %w", key, err))
+		}
+	}
+
+	return errors.Join(append(errs, f.validateDependencies()...)...)
+}
+
+// validateDependencies checks the settings whose valid values depend on other settings, or that
+// need more than a sign or a list of words checked.
+func (f *File) validateDependencies() []error {
+	var errs []error
+
+	retry := f.Trailhead.Retry
+	if retry.Multiplier != nil && *retry.Multiplier < 1 {
+		errs = append(errs, fmt.Errorf("trailhead.retry.multiplier (TRAILHEAD_RETRY_MULTIPLIER) must be at least 1, got %g", *retry.Multiplier))
+	}
+	if retry.Jitter != nil && *retry.Jitter > 1 {
+		errs = append(errs, fmt.Errorf("trailhead.retry.jitter (TRAILHEAD_RETRY_JITTER) must be between 0 and 1, got %g", *retry.Jitter))
+	}
+	if burst, qps := f.Trailhead.Burst, f.Trailhead.MaxQPS; burst != nil && *burst == 0 && (qps == nil || *qps > 0) {
+		errs = append(errs, errors.New("trailhead.burst (TRAILHEAD_BURST) must be at least 1 while trailhead.maxQps (TRAILHEAD_MAX_QPS) limits callouts, got 0"))
+	}
+
+	for _, filter := range f.Badges.Filters {
+		if strings.EqualFold(filter, "all") {
+			errs = append(errs, errors.New("badges.filters (BADGE_FILTERS) must not include all, it's always allowed"))
+		}
+	}
+
+	for _, network := range f.Webhooks.AllowedNetworks {
+		if _, _, err := net.ParseCIDR(network); err != nil {
+			errs = append(errs, fmt.Errorf("webhooks.allowedNetworks (WEBHOOK_ALLOWED_NETWORKS) must be networks like 10.0.0.0/8, got %q", network))
+		}
+	}
+
+	tls := f.Server.TLS
+	switch {
+	case (tls.CertFile == "") != (tls.KeyFile == ""):
+		errs = append(errs, errors.New("server.tls.certFile (TLS_CERT_FILE) and server.tls.keyFile (TLS_KEY_FILE) must be set together"))
+	case tls.ClientCAFile != "" && tls.CertFile == "":
+		errs = append(errs, errors.New("server.tls.clientCaFile (TLS_CLIENT_CA_FILE) requires server.tls.certFile (TLS_CERT_FILE) and server.tls.keyFile (TLS_KEY_FILE)"))
+	}
+
+	if f.Digest.Timezone != "" {
+		if _, err := time.LoadLocation(f.Digest.Timezone); err != nil {
+			errs = append(errs, fmt.Errorf("digest.timezone (DIGEST_TIMEZONE) must be a time zone like America/New_York, got %q", f.Digest.Timezone))
+		}
+	}
+	if len(f.Digest.Recipients) > 0 && (f.SMTP.Host == "" || (f.SMTP.From == "" && f.SMTP.Username == "")) {
+		errs = append(errs, errors.New("digest.recipients (DIGEST_RECIPIENTS) is set, so smtp.host (SMTP_HOST) and smtp.from (SMTP_FROM) are required"))
+	}
+
+	return errs
+}
+
+// CheckURL returns an error unless rawURL is an absolute http or https URL.
+func CheckURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("must be an absolute http or https URL, got %q", rawURL)
+	}
+
+	return nil
+}
+
+// walk calls visit with every setting in a config section, along with its dotted key and struct
+// tag.
+func walk(section reflect.Value, prefix string, visit func(key string, tag reflect.StructTag, value reflect.Value)) {
+	for i := 0; i < section.NumField(); i++ {
+		field := section.Type().Field(i)
+		key := prefix + field.Tag.Get("yaml")
+
+		if field.Type.Kind() == reflect.Struct {
+			walk(section.Field(i), key+".", visit)
+			continue
+		}
+
+		visit(key, field.Tag, section.Field(i))
+	}
+}
+
+func format(value interface{}) string {
+	switch v := value.(type) {
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// containsFold reports whether options holds s, ignoring case.
+func containsFold(options []string, s string) bool {
+	for _, option := range options {
+		if strings.EqualFold(option, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// orList joins words like "a, b or c".
+func orList(words []string) string {
+	if len(words) < 2 {
+		return strings.Join(words, "")
+	}
+
+	return strings.Join(words[:len(words)-1], ", ") + " or " + words[len(words)-1]
+}
@@ -0,0 +1,204 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func lookup(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+}
+
+func TestLoadRejectsNegativeNumbers(t *testing.T) {
+	path := writeFile(t, "config.yaml", "trailhead:\n  burst: -1\n")
+
+	_, err := Load(path)
+	if err == nil || !strings.Contains(err.Error(), "trailhead.burst (TRAILHEAD_BURST) must not be negative") {
+		t.Fatalf("Load() error = %v, want negative burst", err)
+	}
+}
+
+func TestApplyEnvOverridesFile(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+trailhead:
+  burst: 5
+  identities: { ttl: 2h }
+apiKeys: { burst: 20 }
+badges: { filters: [module] }
+`)
+
+	file, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = file.ApplyEnv(lookup(map[string]string{
+		"TRAILHEAD_BURST":      "7",
+		"TRAILHEAD_MAX_QPS":    "2.5",
+		"BADGE_FILTERS":        "project, superbadge,",
+		"LOG_LEVEL":            "debug",
+		"API_RATE_LIMIT_BURST": "",
+	}))
+	if err != nil {
+		t.Fatalf("ApplyEnv() error = %v", err)
+	}
+
+	if *file.Trailhead.Burst != 7 {
+		t.Errorf("Trailhead.Burst = %d, want 7", *file.Trailhead.Burst)
+	}
+	if *file.Trailhead.MaxQPS != 2.5 {
+		t.Errorf("Trailhead.MaxQPS = %g, want 2.5", *file.Trailhead.MaxQPS)
+	}
+	if time.Duration(*file.Trailhead.Identities.TTL) != 2*time.Hour {
+		t.Errorf("Trailhead.Identities.TTL = %s, want 2h", file.Trailhead.Identities.TTL)
+	}
+	if *file.APIKeys.Burst != 20 {
+		t.Errorf("APIKeys.Burst = %d, want 20 since an empty variable is ignored", *file.APIKeys.Burst)
+	}
+	if want := []string{"project", "superbadge"}; !reflect.DeepEqual(file.Badges.Filters, want) {
+		t.Errorf("Badges.Filters = %q, want %q", file.Badges.Filters, want)
+	}
+	if file.Log.Level != "debug" {
+		t.Errorf("Log.Level = %q, want debug", file.Log.Level)
+	}
+}
+
+func TestApplyEnvValidatesMergedSettings(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr string
+	}{
+		{
+			name:    "negative trailhead burst",
+			env:     map[string]string{"TRAILHEAD_BURST": "-1"},
+			wantErr: "trailhead.burst (TRAILHEAD_BURST) must not be negative, got -1",
+		},
+		{
+			name:    "negative api key burst",
+			env:     map[string]string{"API_RATE_LIMIT_BURST": "-2"},
+			wantErr: "apiKeys.burst (API_RATE_LIMIT_BURST) must not be negative, got -2",
+		},
+		{
+			name:    "negative top movers",
+			env:     map[string]string{"DIGEST_TOP_MOVERS": "-5"},
+			wantErr: "digest.topMovers (DIGEST_TOP_MOVERS) must not be negative, got -5",
+		},
+		{
+			name:    "negative duration",
+			env:     map[string]string{"IDENTITY_CACHE_TTL": "-1h"},
+			wantErr: "trailhead.identities.ttl (IDENTITY_CACHE_TTL) must not be negative, got -1h0m0s",
+		},
+		{
+			name:    "negative float",
+			env:     map[string]string{"TRAILHEAD_MAX_QPS": "-0.5"},
+			wantErr: "trailhead.maxQps (TRAILHEAD_MAX_QPS) must not be negative, got -0.5",
+		},
+		{
+			name:    "invalid url",
+			env:     map[string]string{"TRAILHEAD_GRAPHQL_URL": "ftp://example.com"},
+			wantErr: "trailhead.graphqlUrl (TRAILHEAD_GRAPHQL_URL) must be an absolute http or https URL",
+		},
+		{
+			name:    "not a whole number",
+			env:     map[string]string{"TRAILHEAD_BURST": "lots"},
+			wantErr: `TRAILHEAD_BURST must be a whole number, got "lots"`,
+		},
+		{
+			name:    "not a number",
+			env:     map[string]string{"TRAILHEAD_MAX_QPS": "fast"},
+			wantErr: `TRAILHEAD_MAX_QPS must be a number, got "fast"`,
+		},
+		{
+			name:    "zero duration that must be positive",
+			env:     map[string]string{"IDENTITY_CACHE_TTL": "0s"},
+			wantErr: "trailhead.identities.ttl (IDENTITY_CACHE_TTL) must be positive, got 0s",
+		},
+		{
+			name:    "zero number that must be positive",
+			env:     map[string]string{"API_RATE_LIMIT_PER_MINUTE": "0"},
+			wantErr: "apiKeys.ratePerMinute (API_RATE_LIMIT_PER_MINUTE) must be positive, got 0",
+		},
+		{
+			name:    "unknown log level",
+			env:     map[string]string{"LOG_LEVEL": "verbose"},
+			wantErr: `log.level (LOG_LEVEL) must be debug, info, warn or error, got "verbose"`,
+		},
+		{
+			name:    "zero burst while limited",
+			env:     map[string]string{"TRAILHEAD_BURST": "0"},
+			wantErr: "trailhead.burst (TRAILHEAD_BURST) must be at least 1 while trailhead.maxQps (TRAILHEAD_MAX_QPS) limits callouts, got 0",
+		},
+		{
+			name:    "certificate without a key",
+			env:     map[string]string{"TLS_CERT_FILE": "cert.pem"},
+			wantErr: "server.tls.certFile (TLS_CERT_FILE) and server.tls.keyFile (TLS_KEY_FILE) must be set together",
+		},
+		{
+			name:    "unknown time zone",
+			env:     map[string]string{"DIGEST_TIMEZONE": "Mars/Olympus"},
+			wantErr: `digest.timezone (DIGEST_TIMEZONE) must be a time zone like America/New_York, got "Mars/Olympus"`,
+		},
+		{
+			name:    "digest recipients without smtp",
+			env:     map[string]string{"DIGEST_RECIPIENTS": "ops@example.com"},
+			wantErr: "digest.recipients (DIGEST_RECIPIENTS) is set, so smtp.host (SMTP_HOST) and smtp.from (SMTP_FROM) are required",
+		},
+		{
+			name:    "not a duration",
+			env:     map[string]string{"IDENTITY_CACHE_TTL": "soon"},
+			wantErr: `IDENTITY_CACHE_TTL must be a duration like 500ms or 2m, got "soon"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The file is valid on its own, so only the environment can make it invalid.
+			file, err := Load(writeFile(t, "config.toml", "[trailhead]\nburst = 5\n[apiKeys]\nburst = 20\n[digest]\ntopMovers = 3\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = file.ApplyEnv(lookup(tt.env))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ApplyEnv() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOr(t *testing.T) {
+	burst, unset := 0, (*int)(nil)
+	if got := Or(&burst, 5); got != 0 {
+		t.Errorf("Or(&0, 5) = %d, want 0", got)
+	}
+	if got := Or(unset, 5); got != 5 {
+		t.Errorf("Or(nil, 5) = %d, want 5", got)
+	}
+
+	ttl, unsetTTL := Duration(30*time.Minute), (*Duration)(nil)
+	if got := ttl.Or(time.Hour); got != 30*time.Minute {
+		t.Errorf("Duration(30m).Or(1h) = %s, want 30m", got)
+	}
+	if got := unsetTTL.Or(time.Hour); got != time.Hour {
+		t.Errorf("(*Duration)(nil).Or(1h) = %s, want 1h", got)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/meruff/go-trailhead-leaderboard-api/config"
+)
+
+// corsPolicy holds which cross-origin browser clients may call the API.
+type corsPolicy struct {
+	// AllowedOrigins are the origins allowed to call the API. "*" allows any origin.
+	AllowedOrigins []string
+	// AllowedMethods are the methods a preflight request may ask to use.
+	AllowedMethods []string
+	// AllowedHeaders are the request headers a preflight request may ask to send.
+	AllowedHeaders []string
+	// ExposedHeaders are the response headers browser scripts may read.
+	ExposedHeaders []string
+	// MaxAge is how long browsers may cache a preflight response.
+	MaxAge time.Duration
+}
+
+// cors holds the CORS policy applied to every request, if any origins are configured.
+var cors *corsPolicy
+
+// corsFromConfig builds the CORS policy from the cors settings. Returns nil if no origins are
+// allowed.
+func corsFromConfig(conf config.CORS) *corsPolicy {
+	if len(conf.AllowedOrigins) == 0 {
+		return nil
+	}
+
+	policy := &corsPolicy{
+		AllowedOrigins: conf.AllowedOrigins,
+		AllowedMethods: []string{"GET", "PUT", "POST", "DELETE"},
+		AllowedHeaders: []string{"Content-Type", "Authorization", "X-API-Key"},
+		ExposedHeaders: []string{"Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "X-Request-ID"},
+		MaxAge:         conf.MaxAge.Or(10 * time.Minute),
+	}
+
+	if len(conf.AllowedMethods) > 0 {
+		policy.AllowedMethods = make([]string, len(conf.AllowedMethods))
+		for i, method := range conf.AllowedMethods {
+			policy.AllowedMethods[i] = strings.ToUpper(method)
+		}
+	}
+
+	if len(conf.AllowedHeaders) > 0 {
+		policy.AllowedHeaders = conf.AllowedHeaders
+	}
+
+	return policy
+}
+
+// allowsOrigin reports whether the origin may call the API.
+func (p *corsPolicy) allowsOrigin(origin string) bool {
+	for _, allowed := range p.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// allowsHeaders reports whether every header in a preflight's Access-Control-Request-Headers may
+// be sent. "*" in the allowed headers allows any header.
+func (p *corsPolicy) allowsHeaders(requested string) bool {
+	if contains(p.AllowedHeaders, "*") {
+		return true
+	}
+
+	for _, header := range splitList(requested) {
+		allowed := false
+		for _, allowedHeader := range p.AllowedHeaders {
+			if strings.EqualFold(allowedHeader, header) {
+				allowed = true
+				break
+			}
+		}
+
+		if !allowed {
+			return false
+		}
+	}
+
+	return true
+}
+
+// corsHandler adds CORS headers to responses for allowed origins and answers preflight requests
+// itself, so browsers can call the API from dashboards hosted elsewhere. It wraps the router rather
+// than being added with Use, because the router rejects OPTIONS requests to routes restricted to
+// other methods before any middleware runs.
+func corsHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if cors == nil || origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		w.Header().Add("Vary", "Origin")
+		if preflight {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		if !cors.allowsOrigin(origin) {
+			if preflight {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		allowOrigin := origin
+		if contains(cors.AllowedOrigins, "*") {
+			allowOrigin = "*"
+		}
+
+		if !preflight {
+			w.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+			w.Header().Set("Access-Control-Expose-Headers", strings.Join(cors.ExposedHeaders, ", "))
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+		requestedHeaders := r.Header.Get("Access-Control-Request-Headers")
+		if !contains(cors.AllowedMethods, method) || !cors.allowsHeaders(requestedHeaders) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(cors.AllowedMethods, ", "))
+		if requestedHeaders != "" {
+			w.Header().Set("Access-Control-Allow-Headers", requestedHeaders)
+		}
+		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(math.Ceil(cors.MaxAge.Seconds()))))
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// splitList splits a comma separated list, trimming spaces and dropping empty entries.
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/meruff/go-trailhead-leaderboard-api/config"
+)
+
+func TestCORSHandler(t *testing.T) {
+	policy := &corsPolicy{
+		AllowedOrigins: []string{"https://dashboard.example.com"},
+		AllowedMethods: []string{"GET", "PUT"},
+		AllowedHeaders: []string{"Content-Type", "X-API-Key"},
+		ExposedHeaders: []string{"Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "X-Request-ID"},
+		MaxAge:         90 * time.Second,
+	}
+	wildcard := *policy
+	wildcard.AllowedOrigins = []string{"*"}
+
+	preflightVary := []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"}
+
+	tests := []struct {
+		name          string
+		policy        *corsPolicy
+		method        string
+		headers       map[string]string
+		wantStatus    int
+		wantNext      bool
+		wantOrigin    string
+		wantVary      []string
+		wantMethods   string
+		wantHeaders   string
+		wantMaxAge    string
+		wantExposeAll bool
+	}{
+		{
+			name:       "no policy",
+			method:     http.MethodGet,
+			headers:    map[string]string{"Origin": "https://dashboard.example.com"},
+			wantStatus: http.StatusOK,
+			wantNext:   true,
+		},
+		{
+			name:       "same origin",
+			policy:     policy,
+			method:     http.MethodGet,
+			wantStatus: http.StatusOK,
+			wantNext:   true,
+		},
+		{
+			name:          "allowed origin",
+			policy:        policy,
+			method:        http.MethodGet,
+			headers:       map[string]string{"Origin": "https://Dashboard.example.com"},
+			wantStatus:    http.StatusOK,
+			wantNext:      true,
+			wantOrigin:    "https://Dashboard.example.com",
+			wantVary:      []string{"Origin"},
+			wantExposeAll: true,
+		},
+		{
+			name:          "any origin",
+			policy:        &wildcard,
+			method:        http.MethodGet,
+			headers:       map[string]string{"Origin": "https://elsewhere.example.com"},
+			wantStatus:    http.StatusOK,
+			wantNext:      true,
+			wantOrigin:    "*",
+			wantVary:      []string{"Origin"},
+			wantExposeAll: true,
+		},
+		{
+			name:       "disallowed origin",
+			policy:     policy,
+			method:     http.MethodGet,
+			headers:    map[string]string{"Origin": "https://evil.example.com"},
+			wantStatus: http.StatusOK,
+			wantNext:   true,
+			wantVary:   []string{"Origin"},
+		},
+		{
+			name:   "preflight",
+			policy: policy,
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                         "https://dashboard.example.com",
+				"Access-Control-Request-Method":  "put",
+				"Access-Control-Request-Headers": "content-type, x-api-key",
+			},
+			wantStatus:  http.StatusNoContent,
+			wantOrigin:  "https://dashboard.example.com",
+			wantVary:    preflightVary,
+			wantMethods: "GET, PUT",
+			wantHeaders: "content-type, x-api-key",
+			wantMaxAge:  "90",
+		},
+		{
+			name:   "preflight from disallowed origin",
+			policy: policy,
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                        "https://evil.example.com",
+				"Access-Control-Request-Method": "GET",
+			},
+			wantStatus: http.StatusForbidden,
+			wantVary:   preflightVary,
+		},
+		{
+			name:   "preflight for disallowed method",
+			policy: policy,
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                        "https://dashboard.example.com",
+				"Access-Control-Request-Method": "DELETE",
+			},
+			wantStatus: http.StatusForbidden,
+			wantVary:   preflightVary,
+		},
+		{
+			name:   "preflight for disallowed header",
+			policy: policy,
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                         "https://dashboard.example.com",
+				"Access-Control-Request-Method":  "GET",
+				"Access-Control-Request-Headers": "X-API-Key, X-Debug",
+			},
+			wantStatus: http.StatusForbidden,
+			wantVary:   preflightVary,
+		},
+		{
+			name:          "options without a requested method",
+			policy:        policy,
+			method:        http.MethodOptions,
+			headers:       map[string]string{"Origin": "https://dashboard.example.com"},
+			wantStatus:    http.StatusMethodNotAllowed,
+			wantNext:      true,
+			wantOrigin:    "https://dashboard.example.com",
+			wantVary:      []string{"Origin"},
+			wantExposeAll: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved := cors
+			cors = tt.policy
+			defer func() { cors = saved }()
+
+			reachedNext := false
+			r := mux.NewRouter()
+			r.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				reachedNext = true
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			})
+			r.HandleFunc("/leaderboards", func(w http.ResponseWriter, r *http.Request) {
+				reachedNext = true
+			}).Methods(http.MethodGet)
+
+			req := httptest.NewRequest(tt.method, "/leaderboards", nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+			corsHandler(r).ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if reachedNext != tt.wantNext {
+				t.Errorf("reached the router = %v, want %v", reachedNext, tt.wantNext)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if got := w.Header().Values("Vary"); !reflect.DeepEqual(got, tt.wantVary) {
+				t.Errorf("Vary = %q, want %q", got, tt.wantVary)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Methods"); got != tt.wantMethods {
+				t.Errorf("Access-Control-Allow-Methods = %q, want %q", got, tt.wantMethods)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Headers"); got != tt.wantHeaders {
+				t.Errorf("Access-Control-Allow-Headers = %q, want %q", got, tt.wantHeaders)
+			}
+			if got := w.Header().Get("Access-Control-Max-Age"); got != tt.wantMaxAge {
+				t.Errorf("Access-Control-Max-Age = %q, want %q", got, tt.wantMaxAge)
+			}
+
+			exposed := w.Header().Get("Access-Control-Expose-Headers")
+			if tt.wantExposeAll {
+				for _, header := range []string{"Retry-After", "X-RateLimit-Remaining", "X-Request-ID"} {
+					if !strings.Contains(exposed, header) {
+						t.Errorf("Access-Control-Expose-Headers = %q, want it to include %s", exposed, header)
+					}
+				}
+			} else if exposed != "" {
+				t.Errorf("Access-Control-Expose-Headers = %q, want none", exposed)
+			}
+		})
+	}
+}
+
+func TestCORSFromConfig(t *testing.T) {
+	t.Run("no origins", func(t *testing.T) {
+		if policy := corsFromConfig(config.CORS{}); policy != nil {
+			t.Fatalf("corsFromConfig() = %+v, want no policy", policy)
+		}
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		policy := corsFromConfig(config.CORS{AllowedOrigins: []string{"https://a.example.com", "https://b.example.com"}})
+		if want := []string{"https://a.example.com", "https://b.example.com"}; !reflect.DeepEqual(policy.AllowedOrigins, want) {
+			t.Errorf("AllowedOrigins = %q, want %q", policy.AllowedOrigins, want)
+		}
+		if !contains(policy.ExposedHeaders, "X-Request-ID") {
+			t.Errorf("ExposedHeaders = %q, want X-Request-ID", policy.ExposedHeaders)
+		}
+		if policy.MaxAge != 10*time.Minute {
+			t.Errorf("MaxAge = %s, want 10m", policy.MaxAge)
+		}
+	})
+
+	t.Run("methods are upper cased", func(t *testing.T) {
+		policy := corsFromConfig(config.CORS{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"get", "post"}})
+		if want := []string{"GET", "POST"}; !reflect.DeepEqual(policy.AllowedMethods, want) {
+			t.Errorf("AllowedMethods = %q, want %q", policy.AllowedMethods, want)
+		}
+	})
+
+	t.Run("negative max age", func(t *testing.T) {
+		t.Setenv("CORS_ALLOWED_ORIGINS", "*")
+		t.Setenv("CORS_MAX_AGE", "-1m")
+
+		if _, err := configFromEnv(); err == nil || !strings.Contains(err.Error(), "CORS_MAX_AGE") {
+			t.Errorf("configFromEnv() error = %v, want CORS_MAX_AGE rejected", err)
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package cron
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// from is a Wednesday.
+var from = time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+
+func TestScheduleNext(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want string
+	}{
+		{name: "every minute", expr: "* * * * *", want: "2024-05-01 10:31"},
+		{name: "seconds are dropped", expr: "* * * * *", from: from.Add(59 * time.Second), want: "2024-05-01 10:31"},
+		{name: "fixed minute and hour", expr: "0 8 * * *", want: "2024-05-02 08:00"},
+		{name: "later today", expr: "45 10 * * *", want: "2024-05-01 10:45"},
+		{name: "minute list", expr: "0,15,45 * * * *", want: "2024-05-01 10:45"},
+		{name: "hour range", expr: "0 9-17 * * *", want: "2024-05-01 11:00"},
+		{name: "minute step", expr: "*/20 * * * *", want: "2024-05-01 10:40"},
+		{name: "range with step", expr: "0 1-23/6 * * *", want: "2024-05-01 13:00"},
+		{name: "value with step runs to the end", expr: "50/5 * * * *", want: "2024-05-01 10:50"},
+		{name: "day of month", expr: "0 0 15 * *", want: "2024-05-15 00:00"},
+		{name: "month name", expr: "0 0 1 jan *", want: "2025-01-01 00:00"},
+		{name: "month range", expr: "0 0 1 jun-aug *", want: "2024-06-01 00:00"},
+		{name: "weekday", expr: "0 8 * * 1", want: "2024-05-06 08:00"},
+		{name: "weekday name", expr: "0 8 * * fri", want: "2024-05-03 08:00"},
+		{name: "weekday range", expr: "0 8 * * mon-fri", want: "2024-05-02 08:00"},
+		{name: "sunday as 0", expr: "0 0 * * 0", want: "2024-05-05 00:00"},
+		{name: "sunday as 7", expr: "0 0 * * 7", want: "2024-05-05 00:00"},
+		{name: "day of month or day of week", expr: "0 0 13 * fri", want: "2024-05-03 00:00"},
+		{name: "day of week or day of month", expr: "0 0 2 * sun", want: "2024-05-02 00:00"},
+		{name: "day of month with any day of week", expr: "0 0 13 * *", want: "2024-05-13 00:00"},
+		{name: "day of week with stepped day of month", expr: "0 0 */1 * fri", want: "2024-05-03 00:00"},
+		{name: "leap day", expr: "0 0 29 2 *", want: "2028-02-29 00:00"},
+		{name: "never matches", expr: "0 0 30 2 *", want: ""},
+		{name: "never matches on the 31st", expr: "0 0 31 apr,jun,sep,nov *", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.expr, err)
+			}
+
+			start := tt.from
+			if start.IsZero() {
+				start = from
+			}
+
+			next := schedule.Next(start)
+			got := ""
+			if !next.IsZero() {
+				got = next.Format("2006-01-02 15:04")
+			}
+			if got != tt.want {
+				t.Errorf("Next(%v) = %q, want %q", start, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduleNextKeepsLocation(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no time zone database:", err)
+	}
+
+	schedule, err := Parse("0 8 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next := schedule.Next(from.In(newYork))
+	if next.Location() != newYork || next.Hour() != 8 {
+		t.Errorf("Next() = %v, want 8am New York time", next)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr string
+	}{
+		{expr: "* * * *", wantErr: "must have 5 fields"},
+		{expr: "* * * * * *", wantErr: "must have 5 fields"},
+		{expr: "60 * * * *", wantErr: "minute"},
+		{expr: "* 24 * * *", wantErr: "hour"},
+		{expr: "* * 0 * *", wantErr: "day of month"},
+		{expr: "* * * 13 *", wantErr: "month"},
+		{expr: "* * * * 8", wantErr: "day of week"},
+		{expr: "5-1 * * * *", wantErr: "out of range"},
+		{expr: "*/0 * * * *", wantErr: "invalid step"},
+		{expr: "*/x * * * *", wantErr: "invalid step"},
+		{expr: "* * * foo *", wantErr: "invalid value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			if _, err := Parse(tt.expr); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Parse(%q) error = %v, want %q", tt.expr, err, tt.wantErr)
+			}
+		})
+	}
+}
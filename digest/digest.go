@@ -0,0 +1,189 @@
+package digest
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/meruff/go-trailhead-leaderboard-api/badge"
+)
+
+// Member is a leaderboard member as they are now.
+type Member struct {
+	Handle         string
+	Name           string
+	RankTitle      string
+	Points         int
+	Badges         int
+	Certifications []Certification
+}
+
+// Certification is a Salesforce certification a member holds.
+type Certification struct {
+	Title         string
+	DateCompleted time.Time
+	// MaintenanceDue is when the next maintenance module must be completed, zero if unknown.
+	MaintenanceDue time.Time
+	Expired        bool
+}
+
+// Baseline is a member's totals when the last digest was sent, to measure how far they moved.
+type Baseline struct {
+	Points int `json:"points"`
+	Badges int `json:"badges"`
+}
+
+// Mover is a member who gained points since the last digest.
+type Mover struct {
+	Name         string
+	Handle       string
+	RankTitle    string
+	PointsGained int
+	BadgesGained int
+	Points       int
+}
+
+// NewCertification is a certification earned since the last digest.
+type NewCertification struct {
+	Name          string
+	Handle        string
+	Title         string
+	DateCompleted time.Time
+}
+
+// Maintenance is a certification whose maintenance is due soon.
+type Maintenance struct {
+	Name   string
+	Handle string
+	Title  string
+	Due    time.Time
+}
+
+// Section summarizes one leaderboard.
+type Section struct {
+	Leaderboard       string
+	Members           int
+	Movers            []Mover
+	NewCertifications []NewCertification
+	Maintenance       []Maintenance
+	// FirstDigest is true when there's no baseline to measure movers against yet.
+	FirstDigest bool
+}
+
+// Digest is the summary sent to managers, a section per leaderboard.
+type Digest struct {
+	Since    time.Time
+	Until    time.Time
+	Sections []Section
+}
+
+// Options control what a digest includes.
+type Options struct {
+	// Since is when the last digest was sent. Certifications earned after it are new.
+	Since time.Time
+	// Now is when the digest is generated.
+	Now time.Time
+	// TopMovers is how many movers to list per leaderboard.
+	TopMovers int
+	// MaintenanceWindow is how far ahead to look for maintenance deadlines.
+	MaintenanceWindow time.Duration
+}
+
+// BuildSection summarizes a leaderboard's members against their baselines from the last digest.
+// Members without a baseline aren't counted as movers. Without any baselines at all FirstDigest is
+// set.
+func BuildSection(leaderboard string, members []Member, baselines map[string]Baseline, opts Options) Section {
+	section := Section{Leaderboard: leaderboard, Members: len(members), FirstDigest: len(baselines) == 0}
+
+	for _, member := range members {
+		name := member.Name
+		if name == "" {
+			name = member.Handle
+		}
+
+		if baseline, ok := baselines[strings.ToLower(member.Handle)]; ok && member.Points > baseline.Points {
+			section.Movers = append(section.Movers, Mover{
+				Name:         name,
+				Handle:       member.Handle,
+				RankTitle:    member.RankTitle,
+				PointsGained: member.Points - baseline.Points,
+				BadgesGained: member.Badges - baseline.Badges,
+				Points:       member.Points,
+			})
+		}
+
+		for _, certification := range member.Certifications {
+			if certification.DateCompleted.After(opts.Since) && !certification.Expired {
+				section.NewCertifications = append(section.NewCertifications, NewCertification{
+					Name:          name,
+					Handle:        member.Handle,
+					Title:         certification.Title,
+					DateCompleted: certification.DateCompleted,
+				})
+			}
+
+			due := certification.MaintenanceDue
+			if !certification.Expired && !due.IsZero() && due.After(opts.Now) && due.Before(opts.Now.Add(opts.MaintenanceWindow)) {
+				section.Maintenance = append(section.Maintenance, Maintenance{
+					Name:   name,
+					Handle: member.Handle,
+					Title:  certification.Title,
+					Due:    due,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(section.Movers, func(i, j int) bool {
+		return section.Movers[i].PointsGained > section.Movers[j].PointsGained
+	})
+	if len(section.Movers) > opts.TopMovers {
+		section.Movers = section.Movers[:opts.TopMovers]
+	}
+
+	sort.SliceStable(section.NewCertifications, func(i, j int) bool {
+		return section.NewCertifications[i].DateCompleted.Before(section.NewCertifications[j].DateCompleted)
+	})
+	sort.SliceStable(section.Maintenance, func(i, j int) bool {
+		return section.Maintenance[i].Due.Before(section.Maintenance[j].Due)
+	})
+
+	return section
+}
+
+//go:embed templates
+var templates embed.FS
+
+var funcs = map[string]interface{}{
+	"count": badge.FormatCount,
+	"date":  func(t time.Time) string { return t.Format("Mon Jan 2, 2006") },
+	"inc":   func(i int) int { return i + 1 },
+}
+
+var (
+	htmlTemplate = htmltemplate.Must(htmltemplate.New("digest.html").Funcs(funcs).ParseFS(templates, "templates/digest.html"))
+	textTemplate = template.Must(template.New("digest.txt").Funcs(funcs).ParseFS(templates, "templates/digest.txt"))
+)
+
+// Subject returns the subject line of the digest email.
+func (d Digest) Subject() string {
+	return "Trailhead leaderboard digest for " + d.Until.Format("Jan 2, 2006")
+}
+
+// HTML renders the digest as an HTML email body.
+func (d Digest) HTML() (string, error) {
+	var buf bytes.Buffer
+	err := htmlTemplate.Execute(&buf, d)
+	return buf.String(), err
+}
+
+// Text renders the digest as a plain text email body.
+func (d Digest) Text() (string, error) {
+	var buf bytes.Buffer
+	err := textTemplate.Execute(&buf, d)
+	return buf.String(), err
+}
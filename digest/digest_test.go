@@ -0,0 +1,176 @@
+package digest
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildSection(t *testing.T) {
+	now := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	since := now.AddDate(0, 0, -7)
+	opts := Options{Since: since, Now: now, TopMovers: 2, MaintenanceWindow: 30 * 24 * time.Hour}
+
+	tests := []struct {
+		name            string
+		members         []Member
+		baselines       map[string]Baseline
+		opts            Options
+		wantMovers      []Mover
+		wantCerts       []string
+		wantMaintenance []string
+		wantFirst       bool
+	}{
+		{
+			name:      "first digest",
+			members:   []Member{{Handle: "astro", Points: 500}},
+			opts:      opts,
+			wantFirst: true,
+		},
+		{
+			name: "movers ranked by points gained and capped",
+			members: []Member{
+				{Handle: "astro", Name: "Astro", Points: 1500, Badges: 12},
+				{Handle: "Codey", Points: 900, Badges: 9},
+				{Handle: "einstein", Name: "Einstein", Points: 2000, Badges: 20},
+				{Handle: "appy", Name: "Appy", Points: 100},
+			},
+			baselines: map[string]Baseline{
+				"astro":    {Points: 1000, Badges: 10},
+				"codey":    {Points: 100, Badges: 1},
+				"einstein": {Points: 1900, Badges: 19},
+			},
+			opts: opts,
+			wantMovers: []Mover{
+				{Name: "Codey", Handle: "Codey", PointsGained: 800, BadgesGained: 8, Points: 900},
+				{Name: "Astro", Handle: "astro", PointsGained: 500, BadgesGained: 2, Points: 1500},
+			},
+		},
+		{
+			name:      "no movers without points gained",
+			members:   []Member{{Handle: "astro", Points: 1000}},
+			baselines: map[string]Baseline{"astro": {Points: 1000}},
+			opts:      opts,
+		},
+		{
+			name:      "no movers listed",
+			members:   []Member{{Handle: "astro", Points: 1500}},
+			baselines: map[string]Baseline{"astro": {Points: 1000}},
+			opts:      Options{Since: since, Now: now, TopMovers: 0, MaintenanceWindow: opts.MaintenanceWindow},
+		},
+		{
+			name: "certifications earned since the last digest",
+			members: []Member{{Handle: "astro", Name: "Astro", Certifications: []Certification{
+				{Title: "Platform Developer II", DateCompleted: now.AddDate(0, 0, -1)},
+				{Title: "Administrator", DateCompleted: now.AddDate(0, 0, -3)},
+				{Title: "Platform Developer I", DateCompleted: since.AddDate(0, 0, -1)},
+				{Title: "Expired", DateCompleted: now.AddDate(0, 0, -2), Expired: true},
+			}}},
+			baselines: map[string]Baseline{"astro": {}},
+			opts:      opts,
+			wantCerts: []string{"Administrator", "Platform Developer II"},
+		},
+		{
+			name: "maintenance due within the window",
+			members: []Member{{Handle: "astro", Certifications: []Certification{
+				{Title: "Later", DateCompleted: since.AddDate(-1, 0, 0), MaintenanceDue: now.AddDate(0, 0, 20)},
+				{Title: "Sooner", DateCompleted: since.AddDate(-1, 0, 0), MaintenanceDue: now.AddDate(0, 0, 5)},
+				{Title: "Too far", DateCompleted: since.AddDate(-1, 0, 0), MaintenanceDue: now.AddDate(0, 0, 45)},
+				{Title: "Overdue", DateCompleted: since.AddDate(-1, 0, 0), MaintenanceDue: now.AddDate(0, 0, -1)},
+				{Title: "Unknown", DateCompleted: since.AddDate(-1, 0, 0)},
+				{Title: "Expired", DateCompleted: since.AddDate(-1, 0, 0), MaintenanceDue: now.AddDate(0, 0, 5), Expired: true},
+			}}},
+			baselines:       map[string]Baseline{"astro": {}},
+			opts:            opts,
+			wantMaintenance: []string{"Sooner", "Later"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			section := BuildSection("team", tt.members, tt.baselines, tt.opts)
+
+			if section.Leaderboard != "team" || section.Members != len(tt.members) {
+				t.Errorf("section = %q with %d members, want team with %d", section.Leaderboard, section.Members, len(tt.members))
+			}
+			if section.FirstDigest != tt.wantFirst {
+				t.Errorf("FirstDigest = %v, want %v", section.FirstDigest, tt.wantFirst)
+			}
+			if len(section.Movers)+len(tt.wantMovers) > 0 && !reflect.DeepEqual(section.Movers, tt.wantMovers) {
+				t.Errorf("Movers = %+v, want %+v", section.Movers, tt.wantMovers)
+			}
+
+			var certs []string
+			for _, certification := range section.NewCertifications {
+				certs = append(certs, certification.Title)
+			}
+			if !reflect.DeepEqual(certs, tt.wantCerts) {
+				t.Errorf("NewCertifications = %q, want %q", certs, tt.wantCerts)
+			}
+
+			var maintenance []string
+			for _, due := range section.Maintenance {
+				maintenance = append(maintenance, due.Title)
+			}
+			if !reflect.DeepEqual(maintenance, tt.wantMaintenance) {
+				t.Errorf("Maintenance = %q, want %q", maintenance, tt.wantMaintenance)
+			}
+		})
+	}
+}
+
+func TestDigestRender(t *testing.T) {
+	now := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	d := Digest{
+		Since: now.AddDate(0, 0, -7),
+		Until: now,
+		Sections: []Section{
+			{
+				Leaderboard: "team <one>",
+				Members:     2,
+				Movers:      []Mover{{Name: "Astro", Handle: "astro", RankTitle: "Ranger", PointsGained: 1500, BadgesGained: 3, Points: 52000}},
+				NewCertifications: []NewCertification{
+					{Name: "Astro", Handle: "astro", Title: "Administrator", DateCompleted: now.AddDate(0, 0, -1)},
+				},
+			},
+			{Leaderboard: "new", Members: 1, FirstDigest: true},
+		},
+	}
+
+	if got, want := d.Subject(), "Trailhead leaderboard digest for Mar 2, 2026"; got != want {
+		t.Errorf("Subject() = %q, want %q", got, want)
+	}
+
+	text, err := d.Text()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Mon Feb 23, 2026 to Mon Mar 2, 2026",
+		"== team <one> (2 members) ==",
+		"1. Astro +1,500 points, +3 badges (52,000 points, Ranger)",
+		"Astro earned Administrator on Sun Mar 1, 2026",
+		"This is the first digest for this leaderboard",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Text() is missing %q:\n%s", want, text)
+		}
+	}
+
+	html, err := d.HTML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(html, "team &lt;one&gt;") || strings.Contains(html, "team <one>") {
+		t.Errorf("HTML() doesn't escape the leaderboard name:\n%s", html)
+	}
+
+	empty, err := Digest{Until: now}.Text()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(empty, "As of Mon Mar 2, 2026") || !strings.Contains(empty, "There are no leaderboards yet.") {
+		t.Errorf("Text() of an empty digest = %q", empty)
+	}
+}
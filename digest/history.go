@@ -0,0 +1,58 @@
+package digest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/meruff/go-trailhead-leaderboard-api/jsonfile"
+)
+
+// Sent records when a digest was last sent and every leaderboard member's totals at the time, by
+// leaderboard name and then lowercased handle.
+type Sent struct {
+	At        time.Time                      `json:"at"`
+	Baselines map[string]map[string]Baseline `json:"baselines"`
+}
+
+// History keeps the last Sent digest, writing it to a JSON file so movers are measured from the
+// last digest even after a restart.
+type History struct {
+	path string
+
+	mu   sync.RWMutex
+	last Sent
+}
+
+// OpenHistory loads the History saved at path. A missing file is treated as no digest sent yet.
+func OpenHistory(path string) (*History, error) {
+	h := &History{path: path}
+
+	if err := jsonfile.Load(path, &h.last); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+// Last returns the last digest sent, with a zero At if none has been.
+func (h *History) Last() Sent {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.last
+}
+
+// Record saves a digest as the last one sent.
+func (h *History) Record(sent Sent) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.last = sent
+
+	return jsonfile.Save(h.path, h.last)
+}
+
+// Check reports whether the file the History is saved to can still be read and written.
+func (h *History) Check() error {
+	return jsonfile.Check(h.path)
+}
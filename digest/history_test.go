@@ -0,0 +1,40 @@
+package digest
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHistoryRecordSurvivesReopening(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "digest.json")
+
+	history, err := OpenHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := history.Last(); !last.At.IsZero() || last.Baselines != nil {
+		t.Fatalf("Last() = %+v before any digest, want nothing", last)
+	}
+
+	sent := Sent{
+		At:        time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC),
+		Baselines: map[string]map[string]Baseline{"team": {"astro": {Points: 1500, Badges: 12}}},
+	}
+	if err := history.Record(sent); err != nil {
+		t.Fatal(err)
+	}
+	if err := history.Check(); err != nil {
+		t.Errorf("Check() error = %v", err)
+	}
+
+	reopened, err := OpenHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := reopened.Last()
+	if !last.At.Equal(sent.At) || !reflect.DeepEqual(last.Baselines, sent.Baselines) {
+		t.Errorf("Last() after reopening = %+v, want %+v", last, sent)
+	}
+}
@@ -0,0 +1,265 @@
+package digest
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"mime"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// smtpServer is a stand-in SMTP server that accepts one message per connection and records it.
+type smtpServer struct {
+	listener net.Listener
+	tls      *tls.Config
+	startTLS bool
+
+	mu       sync.Mutex
+	auth     string
+	from     string
+	to       []string
+	data     []byte
+	secure   bool
+	received chan struct{}
+}
+
+// newTestCertificate returns a self-signed certificate for 127.0.0.1 and a pool trusting it.
+func newTestCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}
+
+// newSMTPServer starts a stand-in server for the mode. It offers STARTTLS in TLSStartTLS mode and
+// speaks TLS from the start in TLSImplicit mode. offerStartTLS can turn the STARTTLS offer off.
+func newSMTPServer(t *testing.T, mode TLSMode, offerStartTLS bool) (*smtpServer, *x509.CertPool) {
+	t.Helper()
+
+	cert, pool := newTestCertificate(t)
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode == TLSImplicit {
+		listener = tls.NewListener(listener, config)
+	}
+
+	s := &smtpServer{
+		listener: listener,
+		tls:      config,
+		startTLS: mode == TLSStartTLS && offerStartTLS,
+		received: make(chan struct{}, 1),
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	return s, pool
+}
+
+func (s *smtpServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	_, secure := conn.(*tls.Conn)
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 127.0.0.1 ESMTP stand-in")
+
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			if s.startTLS && !secure {
+				text.PrintfLine("250-127.0.0.1\r\n250 STARTTLS")
+			} else {
+				text.PrintfLine("250-127.0.0.1\r\n250 AUTH PLAIN")
+			}
+		case "STARTTLS":
+			text.PrintfLine("220 ready to start TLS")
+			tlsConn := tls.Server(conn, s.tls)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, secure = tlsConn, true
+			text = textproto.NewConn(conn)
+		case "AUTH":
+			credentials, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(arg, "PLAIN "))
+			s.mu.Lock()
+			s.auth = string(credentials)
+			s.mu.Unlock()
+			text.PrintfLine("235 authenticated")
+		case "MAIL":
+			s.mu.Lock()
+			s.from, s.secure = strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>"), secure
+			s.mu.Unlock()
+			text.PrintfLine("250 ok")
+		case "RCPT":
+			s.mu.Lock()
+			s.to = append(s.to, strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>"))
+			s.mu.Unlock()
+			text.PrintfLine("250 ok")
+		case "DATA":
+			text.PrintfLine("354 go ahead")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.data = data
+			s.mu.Unlock()
+			text.PrintfLine("250 queued")
+			s.received <- struct{}{}
+		case "QUIT":
+			text.PrintfLine("221 bye")
+			return
+		default:
+			text.PrintfLine("250 ok")
+		}
+	}
+}
+
+func TestMailerSend(t *testing.T) {
+	tests := []struct {
+		mode       TLSMode
+		wantSecure bool
+	}{
+		{mode: TLSStartTLS, wantSecure: true},
+		{mode: TLSImplicit, wantSecure: true},
+		{mode: TLSNone, wantSecure: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			server, roots := newSMTPServer(t, tt.mode, true)
+			mailer := Mailer{
+				Host:     "127.0.0.1",
+				Port:     server.port(),
+				Username: "digest",
+				Password: "s3cret",
+				From:     "digest@example.com",
+				TLS:      tt.mode,
+				Timeout:  5 * time.Second,
+				RootCAs:  roots,
+			}
+
+			to := []string{"manager@example.com", "lead@example.com"}
+			if err := mailer.Send(to, "Weekly digest ✓", "Hello in text", "<p>Hello in HTML</p>"); err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+
+			select {
+			case <-server.received:
+			case <-time.After(5 * time.Second):
+				t.Fatal("stand-in server never received the message")
+			}
+
+			server.mu.Lock()
+			defer server.mu.Unlock()
+
+			if server.secure != tt.wantSecure {
+				t.Errorf("message sent over TLS: %v, want %v", server.secure, tt.wantSecure)
+			}
+			if server.auth != "\x00digest\x00s3cret" {
+				t.Errorf("AUTH PLAIN credentials = %q", server.auth)
+			}
+			if server.from != "digest@example.com" {
+				t.Errorf("MAIL FROM = %q", server.from)
+			}
+			if strings.Join(server.to, ",") != strings.Join(to, ",") {
+				t.Errorf("RCPT TO = %v, want %v", server.to, to)
+			}
+
+			msg, err := mail.ReadMessage(bufio.NewReader(strings.NewReader(string(server.data))))
+			if err != nil {
+				t.Fatalf("reading message: %v", err)
+			}
+			if subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject")); err != nil || subject != "Weekly digest ✓" {
+				t.Errorf("Subject = %q (%v), want the encoded subject", msg.Header.Get("Subject"), err)
+			}
+			if !strings.HasPrefix(msg.Header.Get("Content-Type"), "multipart/alternative") {
+				t.Errorf("Content-Type = %q, want multipart/alternative", msg.Header.Get("Content-Type"))
+			}
+			body, _ := io.ReadAll(msg.Body)
+			for _, want := range []string{"Hello in text", "<p>Hello in HTML</p>"} {
+				if !strings.Contains(string(body), want) {
+					t.Errorf("body doesn't contain %q:\n%s", want, body)
+				}
+			}
+		})
+	}
+}
+
+func TestMailerSendRequiresStartTLS(t *testing.T) {
+	server, roots := newSMTPServer(t, TLSStartTLS, false)
+	mailer := Mailer{Host: "127.0.0.1", Port: server.port(), From: "digest@example.com", TLS: TLSStartTLS, Timeout: 5 * time.Second, RootCAs: roots}
+
+	err := mailer.Send([]string{"manager@example.com"}, "Digest", "text", "html")
+	if err == nil || !strings.Contains(err.Error(), "doesn't support STARTTLS") {
+		t.Fatalf("Send() error = %v, want a missing STARTTLS error", err)
+	}
+}
+
+func TestMailerSendRejectsUntrustedCertificate(t *testing.T) {
+	server, _ := newSMTPServer(t, TLSImplicit, true)
+	mailer := Mailer{Host: "127.0.0.1", Port: server.port(), From: "digest@example.com", TLS: TLSImplicit, Timeout: 5 * time.Second}
+
+	if err := mailer.Send([]string{"manager@example.com"}, "Digest", "text", "html"); err == nil {
+		t.Fatal("Send() succeeded against a server with an untrusted certificate")
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/meruff/go-trailhead-leaderboard-api/config"
+	"github.com/meruff/go-trailhead-leaderboard-api/cron"
+	"github.com/meruff/go-trailhead-leaderboard-api/digest"
+)
+
+// digestSettings configures the digest emailed to managers.
+type digestSettings struct {
+	Schedule          cron.Schedule
+	Location          *time.Location
+	Recipients        []string
+	Mailer            digest.Mailer
+	TopMovers         int
+	MaintenanceWindow time.Duration
+}
+
+var (
+	// digests holds the digest settings from the environment.
+	digests digestSettings
+	// digestHistory holds when the last digest was sent and the totals it measured movers from.
+	digestHistory *digest.History
+)
+
+// digestsFromConfig reads the digest schedule and recipients, and the SMTP server to send digests
+// through, from the settings.
+func digestsFromConfig(conf config.Digest, smtp config.SMTP) (digestSettings, error) {
+	settings := digestSettings{
+		Location:          time.Local,
+		Recipients:        conf.Recipients,
+		TopMovers:         config.Or(conf.TopMovers, 5),
+		MaintenanceWindow: conf.MaintenanceWindow.Or(30 * 24 * time.Hour),
+		Mailer: digest.Mailer{
+			Host:     smtp.Host,
+			Username: smtp.Username,
+			Password: smtp.Password,
+			From:     smtp.From,
+			TLS:      digest.TLSMode(strings.ToLower(smtp.TLS)),
+			Timeout:  smtp.Timeout.Or(30 * time.Second),
+		},
+	}
+
+	expr := conf.Schedule
+	if expr == "" {
+		expr = "0 8 * * 1"
+	}
+
+	var err error
+	if settings.Schedule, err = cron.Parse(expr); err != nil {
+		return settings, fmt.Errorf("digest.schedule (DIGEST_SCHEDULE): %w", err)
+	}
+
+	if conf.Timezone != "" {
+		if settings.Location, err = time.LoadLocation(conf.Timezone); err != nil {
+			return settings, err
+		}
+	}
+
+	if settings.Mailer.TLS == "" {
+		settings.Mailer.TLS = digest.TLSStartTLS
+	}
+
+	defaultPort := 587
+	if settings.Mailer.TLS == digest.TLSImplicit {
+		defaultPort = 465
+	}
+	settings.Mailer.Port = config.Or(smtp.Port, defaultPort)
+
+	if settings.Mailer.From == "" {
+		settings.Mailer.From = settings.Mailer.Username
+	}
+
+	return settings, nil
+}
+
+// digestHistoryFromConfig opens the digest history saved at the given path, digest.json if empty.
+func digestHistoryFromConfig(path string) (*digest.History, error) {
+	if path == "" {
+		path = "digest.json"
+	}
+
+	history, err := digest.OpenHistory(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening digest state store %s: %w", path, err)
+	}
+
+	return history, nil
+}
+
+// scheduleDigests sends the digest every time the schedule comes around, until ctx is done.
+func scheduleDigests(ctx context.Context) {
+	for {
+		next := digests.Schedule.Next(time.Now().In(digests.Location))
+		if next.IsZero() {
+			slog.Warn("DIGEST_SCHEDULE never matches, no digests will be sent")
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		ctx, span := tracer.Start(ctx, "send digest")
+		if err := sendDigest(ctx); err != nil {
+			slog.ErrorContext(ctx, "sending digest", "error", err)
+		}
+		span.End()
+	}
+}
+
+// digestHandler previews the next digest without sending it. format is html (the default) or text.
+func digestHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdminKey(w, r) {
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "html" && format != "text" {
+		writeErrorToBrowser(w, "Expected format to be html or text.", 400)
+		return
+	}
+
+	d, _ := buildDigest(r.Context(), time.Now().In(digests.Location))
+
+	var body string
+	var err error
+	if format == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		body, err = d.Text()
+	} else {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		body, err = d.HTML()
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "rendering digest", "error", err)
+		writeErrorToBrowser(w, "Problem rendering digest.", 500)
+		return
+	}
+
+	w.Write([]byte(body))
+}
+
+// sendDigestHandler sends the digest right away rather than waiting for the schedule.
+func sendDigestHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdminKey(w, r) {
+		return
+	}
+
+	if len(digests.Recipients) == 0 {
+		writeErrorToBrowser(w, "No digest recipients are configured, set DIGEST_RECIPIENTS.", 400)
+		return
+	}
+
+	if err := sendDigest(r.Context()); err != nil {
+		slog.ErrorContext(r.Context(), "sending digest", "error", err)
+		writeErrorToBrowser(w, "Problem sending digest.", 502)
+		return
+	}
+
+	encodeAndWriteToBrowser(w, map[string]interface{}{
+		"sentAt":     digestHistory.Last().At,
+		"recipients": digests.Recipients,
+	})
+}
+
+// sendDigest emails the digest to the recipients, then records it so the next digest measures
+// movers from now.
+func sendDigest(ctx context.Context) error {
+	if len(digests.Recipients) == 0 {
+		return nil
+	}
+
+	now := time.Now().In(digests.Location)
+	d, baselines := buildDigest(ctx, now)
+
+	html, err := d.HTML()
+	if err != nil {
+		return err
+	}
+	text, err := d.Text()
+	if err != nil {
+		return err
+	}
+
+	if err := digests.Mailer.Send(digests.Recipients, d.Subject(), text, html); err != nil {
+		return err
+	}
+
+	return digestHistory.Record(digest.Sent{At: now, Baselines: baselines})
+}
+
+// buildDigest summarizes every leaderboard since the last digest, or the last week if none has been
+// sent, and returns the digest with every member's current totals to measure the next one from.
+// Members that can't be looked up keep the totals they had at the last digest.
+func buildDigest(ctx context.Context, now time.Time) (digest.Digest, map[string]map[string]digest.Baseline) {
+	last := digestHistory.Last()
+
+	opts := digest.Options{
+		Since:             last.At,
+		Now:               now,
+		TopMovers:         digests.TopMovers,
+		MaintenanceWindow: digests.MaintenanceWindow,
+	}
+	if opts.Since.IsZero() {
+		opts.Since = now.AddDate(0, 0, -7)
+	}
+
+	tracked := trackedMembers()
+	handles := make([]string, len(tracked))
+	for i, member := range tracked {
+		handles[i] = member.Handle
+	}
+
+	found := make([]bool, len(handles))
+	members := make([]digest.Member, len(handles))
+	forEachHandle(handles, func(i int, handle string) {
+		members[i], found[i] = lookupDigestMember(ctx, handle)
+	})
+
+	byHandle := map[string]int{}
+	for i, handle := range handles {
+		byHandle[strings.ToLower(handle)] = i
+	}
+
+	d := digest.Digest{Since: opts.Since, Until: now}
+	baselines := map[string]map[string]digest.Baseline{}
+
+	for _, name := range store.Names() {
+		lb, err := store.Get(name)
+		if err != nil {
+			continue
+		}
+
+		var lbMembers []digest.Member
+		lbBaselines := map[string]digest.Baseline{}
+		for _, member := range lb.Members {
+			key := strings.ToLower(member.Handle)
+			i, ok := byHandle[key]
+			if !ok {
+				continue
+			}
+
+			lbMembers = append(lbMembers, members[i])
+			if found[i] {
+				lbBaselines[key] = digest.Baseline{Points: members[i].Points, Badges: members[i].Badges}
+			} else if baseline, ok := last.Baselines[name][key]; ok {
+				lbBaselines[key] = baseline
+			}
+		}
+
+		d.Sections = append(d.Sections, digest.BuildSection(name, lbMembers, last.Baselines[name], opts))
+		baselines[name] = lbBaselines
+	}
+
+	return d, baselines
+}
+
+// lookupDigestMember gets a leaderboard member's name, totals and certifications. Returns false if
+// their totals couldn't be looked up.
+func lookupDigestMember(ctx context.Context, handle string) (digest.Member, bool) {
+	member := digest.Member{Handle: handle, Name: handle}
+
+	if profile, err := getTrailheadProfile(ctx, handle); err == nil {
+		if name := strings.TrimSpace(profile.FirstName + " " + profile.LastName); name != "" {
+			member.Name = name
+		}
+	} else {
+		slog.WarnContext(ctx, "retrieving profile", "handle", handle, "error", err)
+	}
+
+	if certifications, err := getTrailheadCertifications(ctx, handle); err == nil {
+		for _, certification := range certifications.Data.Profile.Credential.Certifications {
+			completed, err := time.Parse("2006-01-02", certification.DateCompleted)
+			if err != nil {
+				continue
+			}
+
+			due, _ := time.Parse("2006-01-02", certification.MaintenanceDueDate)
+			member.Certifications = append(member.Certifications, digest.Certification{
+				Title:          certification.Title,
+				DateCompleted:  completed,
+				MaintenanceDue: due,
+				Expired:        certification.Status.Expired,
+			})
+		}
+	} else {
+		slog.WarnContext(ctx, "retrieving certifications", "handle", handle, "error", err)
+	}
+
+	stats, err := getTrailheadRankStats(ctx, handle)
+	if err != nil {
+		slog.WarnContext(ctx, "retrieving rank", "handle", handle, "error", err)
+		return member, false
+	}
+
+	member.RankTitle = stats.Rank.Title
+	member.Points = stats.EarnedPointsSum
+	member.Badges = stats.EarnedBadgesCount
+
+	return member, true
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/meruff/go-trailhead-leaderboard-api/cron"
+)
+
+func TestDigestsFromConfigValidation(t *testing.T) {
+	tests := []struct {
+		name, topMovers, window string
+		wantErr                 string
+	}{
+		{name: "defaults"},
+		{name: "no movers", topMovers: "0"},
+		{name: "negative movers", topMovers: "-1", wantErr: "(DIGEST_TOP_MOVERS) must not be negative"},
+		{name: "zero window", window: "0s", wantErr: "(DIGEST_MAINTENANCE_WINDOW) must be positive"},
+		{name: "negative window", window: "-24h", wantErr: "(DIGEST_MAINTENANCE_WINDOW) must not be negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DIGEST_TOP_MOVERS", tt.topMovers)
+			t.Setenv("DIGEST_MAINTENANCE_WINDOW", tt.window)
+
+			conf, err := configFromEnv()
+			if err == nil {
+				_, err = digestsFromConfig(conf.Digest, conf.SMTP)
+			}
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("digestsFromConfig() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("configFromEnv() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestScheduleDigestsStops(t *testing.T) {
+	tests := []struct {
+		name, schedule string
+		cancel         bool
+	}{
+		{name: "never matches", schedule: "0 0 30 2 *"},
+		{name: "cancelled while waiting", schedule: "0 8 * * 1", cancel: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := cron.Parse(tt.schedule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func(previous digestSettings) { digests = previous }(digests)
+			digests = digestSettings{Schedule: schedule, Location: time.UTC}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			done := make(chan struct{})
+			go func() {
+				scheduleDigests(ctx)
+				close(done)
+			}()
+			if tt.cancel {
+				cancel()
+			}
+
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("scheduleDigests() didn't return")
+			}
+		})
+	}
+}
@@ -0,0 +1,318 @@
+package drift
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kind is the way a response differs from the shape it was expected to have.
+type Kind string
+
+const (
+	// UnknownField is a field in the response that the expected shape doesn't have.
+	UnknownField Kind = "unknown_field"
+	// MissingField is a field of the expected shape that's missing from the response.
+	MissingField Kind = "missing_field"
+	// TypeChanged is a field whose JSON type isn't the expected one, i.e. a number sent as a string.
+	TypeChanged Kind = "type_changed"
+	// UnexpectedNull is a field that's null where a value was expected.
+	UnexpectedNull Kind = "unexpected_null"
+)
+
+// Issue is one way a response differs from its expected shape. Path is the dotted path to the
+// field, with [] standing for every element of an array, i.e. "data.profile.earnedSkills[].id".
+type Issue struct {
+	Path     string `json:"path"`
+	Kind     Kind   `json:"kind"`
+	Expected string `json:"expected"`
+	Got      string `json:"got"`
+}
+
+// Check compares a JSON response with the shape of the Go value it's decoded into, going by its
+// json struct tags. Pointer and interface fields may be null, and interface fields may hold
+// anything. Each issue is reported once, even if it appears in every element of an array.
+func Check(body []byte, shape interface{}) ([]Issue, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	c := checker{seen: map[Issue]bool{}}
+	c.compare("", reflect.TypeOf(shape), value)
+
+	return c.issues, nil
+}
+
+// checker collects the issues found while walking a response.
+type checker struct {
+	issues []Issue
+	seen   map[Issue]bool
+}
+
+func (c *checker) add(path string, kind Kind, expected, got string) {
+	issue := Issue{Path: path, Kind: kind, Expected: expected, Got: got}
+	if !c.seen[issue] {
+		c.seen[issue] = true
+		c.issues = append(c.issues, issue)
+	}
+}
+
+// compare checks a decoded JSON value against the Go type it's expected to fit.
+func (c *checker) compare(path string, t reflect.Type, value interface{}) {
+	nullable := false
+	for t.Kind() == reflect.Ptr {
+		nullable = true
+		t = t.Elem()
+	}
+
+	if t.Kind() == reflect.Interface {
+		return
+	}
+
+	if value == nil {
+		if !nullable && t.Kind() != reflect.Slice && t.Kind() != reflect.Map {
+			c.add(path, UnexpectedNull, typeName(t), "null")
+		}
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			c.add(path, TypeChanged, "object", jsonType(value))
+			return
+		}
+
+		fields := jsonFields(t)
+		for name, fieldType := range fields {
+			fieldValue, ok := object[name]
+			if !ok {
+				c.add(join(path, name), MissingField, typeName(fieldType), "nothing")
+				continue
+			}
+
+			c.compare(join(path, name), fieldType, fieldValue)
+		}
+
+		for name, fieldValue := range object {
+			if _, ok := fields[name]; !ok {
+				c.add(join(path, name), UnknownField, "nothing", jsonType(fieldValue))
+			}
+		}
+
+	case reflect.Map:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			c.add(path, TypeChanged, "object", jsonType(value))
+			return
+		}
+
+		for _, fieldValue := range object {
+			c.compare(path+".*", t.Elem(), fieldValue)
+		}
+
+	case reflect.Slice, reflect.Array:
+		array, ok := value.([]interface{})
+		if !ok {
+			c.add(path, TypeChanged, "array", jsonType(value))
+			return
+		}
+
+		for _, element := range array {
+			c.compare(path+"[]", t.Elem(), element)
+		}
+
+	case reflect.String:
+		if _, ok := value.(string); !ok {
+			c.add(path, TypeChanged, "string", jsonType(value))
+		}
+
+	case reflect.Bool:
+		if _, ok := value.(bool); !ok {
+			c.add(path, TypeChanged, "boolean", jsonType(value))
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		number, ok := value.(json.Number)
+		if !ok {
+			c.add(path, TypeChanged, "integer", jsonType(value))
+		} else if _, err := number.Int64(); err != nil {
+			c.add(path, TypeChanged, "integer", "number")
+		}
+
+	case reflect.Float32, reflect.Float64:
+		if _, ok := value.(json.Number); !ok {
+			c.add(path, TypeChanged, "number", jsonType(value))
+		}
+	}
+}
+
+// jsonFields returns the fields of a struct by the JSON name they're decoded from, including the
+// fields of embedded structs.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		name := strings.Split(tag, ",")[0]
+
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			for embeddedName, embeddedType := range jsonFields(field.Type) {
+				fields[embeddedName] = embeddedType
+			}
+			continue
+		}
+
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		fields[name] = field.Type
+	}
+
+	return fields
+}
+
+// typeName describes a Go type as the JSON type it's decoded from.
+func typeName(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map:
+		return "object"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Interface:
+		return "any"
+	default:
+		return "integer"
+	}
+}
+
+// jsonType describes the type of a decoded JSON value.
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	default:
+		return "number"
+	}
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+
+	return path + "." + name
+}
+
+// Entry is an issue seen in the responses to an operation, with how often and when it was seen.
+type Entry struct {
+	Operation string `json:"operation"`
+	Issue
+	Count     int       `json:"count"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+// Report tallies the issues found in responses, per operation.
+type Report struct {
+	mu      sync.Mutex
+	entries map[entryKey]*Entry
+}
+
+type entryKey struct {
+	operation string
+	issue     Issue
+}
+
+// NewReport returns an empty Report.
+func NewReport() *Report {
+	return &Report{entries: map[entryKey]*Entry{}}
+}
+
+// Record counts the issues found in a response to an operation and returns the ones that hadn't
+// been seen before.
+func (r *Report) Record(operation string, issues []Issue, now time.Time) []Issue {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var fresh []Issue
+	for _, issue := range issues {
+		key := entryKey{operation, issue}
+
+		entry, ok := r.entries[key]
+		if !ok {
+			entry = &Entry{Operation: operation, Issue: issue, FirstSeen: now}
+			r.entries[key] = entry
+			fresh = append(fresh, issue)
+		}
+
+		entry.Count++
+		entry.LastSeen = now
+	}
+
+	return fresh
+}
+
+// Entries returns every issue seen, ordered by operation and path.
+func (r *Report) Entries() []Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entries := make([]Entry, 0, len(r.entries))
+	for _, entry := range r.entries {
+		entries = append(entries, *entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Operation != entries[j].Operation {
+			return entries[i].Operation < entries[j].Operation
+		}
+		if entries[i].Path != entries[j].Path {
+			return entries[i].Path < entries[j].Path
+		}
+		return entries[i].Kind < entries[j].Kind
+	})
+
+	return entries
+}
+
+// Reset forgets every issue seen, i.e. once the code has been updated to match Trailhead.
+func (r *Report) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries = map[entryKey]*Entry{}
+}
@@ -0,0 +1,217 @@
+package drift
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// rank is the shape of the response recorded in testdata/rank.json.
+type rank struct {
+	Data struct {
+		Profile struct {
+			Typename       string `json:"__typename"`
+			TrailheadStats struct {
+				Typename            string `json:"__typename"`
+				EarnedPointsSum     int    `json:"earnedPointsSum"`
+				EarnedBadgesCount   int    `json:"earnedBadgesCount"`
+				CompletedTrailCount int    `json:"completedTrailCount"`
+				Rank                struct {
+					Typename            string `json:"__typename"`
+					Title               string `json:"title"`
+					RequiredPointsSum   int    `json:"requiredPointsSum"`
+					RequiredBadgesCount int    `json:"requiredBadgesCount"`
+					ImageURL            string `json:"imageUrl"`
+				} `json:"rank"`
+				NextRank *struct {
+					Title string `json:"title"`
+				} `json:"nextRank"`
+			} `json:"trailheadStats"`
+		} `json:"profile"`
+	} `json:"data"`
+}
+
+// recordedRank returns the recorded response with mutate applied to its trailheadStats object.
+func recordedRank(t *testing.T, mutate func(stats map[string]interface{})) []byte {
+	t.Helper()
+
+	raw, err := os.ReadFile("testdata/rank.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(raw, &response); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := response["data"].(map[string]interface{})["profile"].(map[string]interface{})["trailheadStats"].(map[string]interface{})
+	mutate(stats)
+
+	body, err := json.Marshal(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return body
+}
+
+func TestCheck(t *testing.T) {
+	const stats = "data.profile.trailheadStats"
+
+	tests := []struct {
+		name   string
+		mutate func(stats map[string]interface{})
+		want   []Issue
+	}{
+		{
+			name:   "matches recorded shape",
+			mutate: func(map[string]interface{}) {},
+		},
+		{
+			name: "added field",
+			mutate: func(s map[string]interface{}) {
+				s["earnedStarsSum"] = 12
+			},
+			want: []Issue{{Path: stats + ".earnedStarsSum", Kind: UnknownField, Expected: "nothing", Got: "number"}},
+		},
+		{
+			name: "removed field",
+			mutate: func(s map[string]interface{}) {
+				delete(s, "completedTrailCount")
+			},
+			want: []Issue{{Path: stats + ".completedTrailCount", Kind: MissingField, Expected: "integer", Got: "nothing"}},
+		},
+		{
+			name: "renamed field",
+			mutate: func(s map[string]interface{}) {
+				s["badgesCount"] = s["earnedBadgesCount"]
+				delete(s, "earnedBadgesCount")
+			},
+			want: []Issue{
+				{Path: stats + ".badgesCount", Kind: UnknownField, Expected: "nothing", Got: "number"},
+				{Path: stats + ".earnedBadgesCount", Kind: MissingField, Expected: "integer", Got: "nothing"},
+			},
+		},
+		{
+			name: "number sent as a string",
+			mutate: func(s map[string]interface{}) {
+				s["earnedPointsSum"] = "6200"
+			},
+			want: []Issue{{Path: stats + ".earnedPointsSum", Kind: TypeChanged, Expected: "integer", Got: "string"}},
+		},
+		{
+			name: "fractional number for an integer",
+			mutate: func(s map[string]interface{}) {
+				s["earnedPointsSum"] = 6200.5
+			},
+			want: []Issue{{Path: stats + ".earnedPointsSum", Kind: TypeChanged, Expected: "integer", Got: "number"}},
+		},
+		{
+			name: "object became an array",
+			mutate: func(s map[string]interface{}) {
+				s["rank"] = []interface{}{s["rank"]}
+			},
+			want: []Issue{{Path: stats + ".rank", Kind: TypeChanged, Expected: "object", Got: "array"}},
+		},
+		{
+			name: "null where a value was expected",
+			mutate: func(s map[string]interface{}) {
+				s["rank"] = nil
+			},
+			want: []Issue{{Path: stats + ".rank", Kind: UnexpectedNull, Expected: "object", Got: "null"}},
+		},
+		{
+			name: "nullable field set",
+			mutate: func(s map[string]interface{}) {
+				s["nextRank"] = map[string]interface{}{"title": "Hiker"}
+			},
+		},
+		{
+			name: "nested type change",
+			mutate: func(s map[string]interface{}) {
+				s["rank"].(map[string]interface{})["title"] = false
+			},
+			want: []Issue{{Path: stats + ".rank.title", Kind: TypeChanged, Expected: "string", Got: "boolean"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues, err := Check(recordedRank(t, tt.mutate), rank{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sort.Slice(issues, func(i, j int) bool { return issues[i].Path < issues[j].Path })
+			if len(issues) != len(tt.want) || (len(issues) > 0 && !reflect.DeepEqual(issues, tt.want)) {
+				t.Errorf("Check() = %+v, want %+v", issues, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckReportsArrayIssuesOnce(t *testing.T) {
+	type skills struct {
+		Skills []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"skills"`
+	}
+
+	body := []byte(`{"skills": [{"id": "1", "name": "Apex", "level": 2}, {"id": "2", "name": "Flow", "level": 3}]}`)
+
+	issues, err := Check(body, skills{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Issue{{Path: "skills[].level", Kind: UnknownField, Expected: "nothing", Got: "number"}}
+	if !reflect.DeepEqual(issues, want) {
+		t.Errorf("Check() = %+v, want %+v", issues, want)
+	}
+}
+
+func TestCheckInvalidJSON(t *testing.T) {
+	if _, err := Check([]byte(`{"data": `), rank{}); err == nil {
+		t.Error("Check() of invalid JSON succeeded")
+	}
+}
+
+func TestReportRecord(t *testing.T) {
+	report := NewReport()
+	first := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	later := first.Add(time.Hour)
+
+	added := Issue{Path: "data.profile.extra", Kind: UnknownField, Expected: "nothing", Got: "string"}
+	removed := Issue{Path: "data.profile.id", Kind: MissingField, Expected: "string", Got: "nothing"}
+
+	if fresh := report.Record("GetTrailheadRank", []Issue{added}, first); len(fresh) != 1 {
+		t.Errorf("first Record() = %v, want the issue as new", fresh)
+	}
+	if fresh := report.Record("GetTrailheadRank", []Issue{added, removed}, later); !reflect.DeepEqual(fresh, []Issue{removed}) {
+		t.Errorf("second Record() = %v, want only the new issue", fresh)
+	}
+	if fresh := report.Record("GetEarnedSkills", []Issue{added}, later); len(fresh) != 1 {
+		t.Errorf("Record() for another operation = %v, want the issue as new", fresh)
+	}
+
+	entries := report.Entries()
+	if len(entries) != 3 {
+		t.Fatalf("Entries() = %+v, want 3", entries)
+	}
+	if entries[0].Operation != "GetEarnedSkills" || entries[1].Path != "data.profile.extra" || entries[2].Path != "data.profile.id" {
+		t.Errorf("Entries() aren't ordered by operation and path: %+v", entries)
+	}
+	if entries[1].Count != 2 || !entries[1].FirstSeen.Equal(first) || !entries[1].LastSeen.Equal(later) {
+		t.Errorf("entry = %+v, want seen twice from %v to %v", entries[1], first, later)
+	}
+
+	report.Reset()
+	if entries := report.Entries(); len(entries) != 0 {
+		t.Errorf("Entries() after Reset = %+v, want none", entries)
+	}
+}
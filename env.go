@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/meruff/go-trailhead-leaderboard-api/config"
+	"github.com/meruff/go-trailhead-leaderboard-api/ratelimit"
+	"github.com/meruff/go-trailhead-leaderboard-api/trailhead"
+)
+
+// configFromEnv loads the config file named by CONFIG_FILE, if any, and validates it merged with
+// the environment variables that override it.
+func configFromEnv() (*config.File, error) {
+	conf := &config.File{}
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		var err error
+		if conf, err = config.Load(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := conf.ApplyEnv(os.LookupEnv); err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+
+	return conf, nil
+}
+
+// retryPolicyFromConfig returns the retry policy for Trailhead callouts, starting from the
+// defaults and overridden by the trailhead.retry settings.
+func retryPolicyFromConfig(retry config.Retry) trailhead.RetryPolicy {
+	policy := trailhead.DefaultRetryPolicy()
+
+	policy.MaxAttempts = config.Or(retry.MaxAttempts, policy.MaxAttempts)
+	policy.InitialInterval = retry.InitialInterval.Or(policy.InitialInterval)
+	policy.MaxInterval = retry.MaxInterval.Or(policy.MaxInterval)
+	policy.Multiplier = config.Or(retry.Multiplier, policy.Multiplier)
+	policy.Jitter = config.Or(retry.Jitter, policy.Jitter)
+	policy.MaxElapsedTime = retry.MaxElapsedTime.Or(policy.MaxElapsedTime)
+
+	return policy
+}
+
+// configureUpstream applies the trailhead settings to the Trailhead client's URLs, headers,
+// timeout, retry policy, circuit breakers, outbound rate limit and stale response cache.
+func configureUpstream(client *trailhead.Client, conf config.Trailhead) {
+	if conf.GraphqlURL != "" {
+		client.GraphqlURL = conf.GraphqlURL
+	}
+	if conf.ProfileURL != "" {
+		client.ProfileURL = conf.ProfileURL
+	}
+	if !strings.HasSuffix(client.ProfileURL, "/") {
+		client.ProfileURL += "/"
+	}
+
+	for name, value := range conf.Headers {
+		if value == "" {
+			client.Headers.Del(name)
+		} else {
+			client.Headers.Set(name, value)
+		}
+	}
+
+	client.Retry = retryPolicyFromConfig(conf.Retry)
+	client.HTTPClient.Timeout = conf.Timeout.Or(client.HTTPClient.Timeout)
+
+	for _, breaker := range []*trailhead.Breaker{client.GraphqlBreaker, client.ProfileBreaker} {
+		breaker.FailureThreshold = config.Or(conf.Breaker.Failures, breaker.FailureThreshold)
+		breaker.OpenTimeout = conf.Breaker.OpenTimeout.Or(breaker.OpenTimeout)
+	}
+
+	maxQPS := config.Or(conf.MaxQPS, 10)
+	if maxQPS > 0 {
+		client.Limiter = ratelimit.NewLimiter(maxQPS, config.Or(conf.Burst, int(math.Max(1, math.Ceil(maxQPS)))))
+	} else {
+		client.Limiter = nil
+	}
+
+	client.SetStaleCache(conf.StaleCache.TTL.Or(24*time.Hour), config.Or(conf.StaleCache.Size, 1000))
+}
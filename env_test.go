@@ -0,0 +1,106 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/meruff/go-trailhead-leaderboard-api/trailhead"
+)
+
+func TestConfigureUpstreamRateLimit(t *testing.T) {
+	tests := []struct {
+		name        string
+		qps, burst  string
+		wantErr     string
+		wantLimiter bool
+	}{
+		{name: "defaults", wantLimiter: true},
+		{name: "burst defaults from qps", qps: "2.5", wantLimiter: true},
+		{name: "no limit", qps: "0", wantLimiter: false},
+		{name: "no limit ignores burst", qps: "0", burst: "0", wantLimiter: false},
+		{name: "negative qps", qps: "-1", wantErr: "(TRAILHEAD_MAX_QPS) must not be negative"},
+		{name: "zero burst", qps: "5", burst: "0", wantErr: "(TRAILHEAD_BURST) must be at least 1"},
+		{name: "negative burst", burst: "-3", wantErr: "(TRAILHEAD_BURST) must not be negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TRAILHEAD_MAX_QPS", tt.qps)
+			t.Setenv("TRAILHEAD_BURST", tt.burst)
+
+			conf, err := configFromEnv()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("configFromEnv() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("configFromEnv() error = %v", err)
+			}
+
+			client := trailhead.NewClient("https://example.com/graphql", "https://example.com/id/")
+			configureUpstream(client, conf.Trailhead)
+			if (client.Limiter != nil) != tt.wantLimiter {
+				t.Errorf("Limiter = %v, want set: %v", client.Limiter, tt.wantLimiter)
+			}
+		})
+	}
+}
+
+func TestConfigFromEnvValidatesEnvOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("digest: { topMovers: 5 }\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("DIGEST_TOP_MOVERS", "-1")
+
+	_, err := configFromEnv()
+	if err == nil || !strings.Contains(err.Error(), "DIGEST_TOP_MOVERS") {
+		t.Fatalf("configFromEnv() error = %v, want DIGEST_TOP_MOVERS rejected", err)
+	}
+}
+
+func TestConfigureUpstreamFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	config := `
+trailhead:
+  profileUrl: https://example.com/id
+  timeout: 3s
+  retry: { maxAttempts: 2 }
+  breaker: { failures: 7 }
+  headers: { User-Agent: leaderboard-test }
+`
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", path)
+
+	conf, err := configFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := trailhead.NewClient("https://example.com/graphql", "https://example.com/id/")
+	configureUpstream(client, conf.Trailhead)
+
+	if client.ProfileURL != "https://example.com/id/" {
+		t.Errorf("ProfileURL = %q, want a trailing slash", client.ProfileURL)
+	}
+	if client.HTTPClient.Timeout != 3*time.Second {
+		t.Errorf("Timeout = %s, want 3s", client.HTTPClient.Timeout)
+	}
+	if client.Retry.MaxAttempts != 2 {
+		t.Errorf("Retry.MaxAttempts = %d, want 2", client.Retry.MaxAttempts)
+	}
+	if client.GraphqlBreaker.FailureThreshold != 7 || client.ProfileBreaker.FailureThreshold != 7 {
+		t.Errorf("breaker failure thresholds = %d and %d, want 7", client.GraphqlBreaker.FailureThreshold, client.ProfileBreaker.FailureThreshold)
+	}
+	if got := client.Headers.Get("User-Agent"); got != "leaderboard-test" {
+		t.Errorf("User-Agent = %q, want leaderboard-test", got)
+	}
+}
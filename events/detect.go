@@ -0,0 +1,104 @@
+package events
+
+import "time"
+
+// Snapshot is what a Trailblazer's profile looks like now: their most recently earned badges,
+// every certification and their rank.
+type Snapshot struct {
+	Handle         string
+	Name           string
+	Badges         []Badge
+	Certifications []Certification
+	Rank           Rank
+}
+
+// MemberState is what is remembered about a Trailblazer between checks, enough to tell what
+// changed.
+type MemberState struct {
+	// Badges are the IDs of the most recently earned badges seen on the last check.
+	Badges []string `json:"badges"`
+	// Certifications maps certification titles to whether they had expired.
+	Certifications map[string]bool `json:"certifications"`
+	RankTitle      string          `json:"rankTitle"`
+	RankPoints     int             `json:"rankPoints"`
+	CheckedAt      time.Time       `json:"checkedAt"`
+}
+
+// State returns what to remember about the snapshot.
+func (s Snapshot) State(now time.Time) MemberState {
+	state := MemberState{
+		Badges:         make([]string, 0, len(s.Badges)),
+		Certifications: make(map[string]bool, len(s.Certifications)),
+		RankTitle:      s.Rank.Title,
+		RankPoints:     s.Rank.RequiredPoints,
+		CheckedAt:      now,
+	}
+
+	for _, badge := range s.Badges {
+		state.Badges = append(state.Badges, badge.ID)
+	}
+	for _, certification := range s.Certifications {
+		state.Certifications[certification.Title] = certification.Expired
+	}
+
+	return state
+}
+
+// Detect compares a Trailblazer's snapshot with their state from the last check and returns an
+// event for each badge and certification earned, certification expired and rank gained since.
+// Badges are only compared by ID, since a snapshot holds just the most recent ones. A prev never
+// checked, i.e. the zero MemberState of a Trailblazer's first check, returns no events, so adding
+// someone doesn't announce everything they've ever earned.
+func Detect(prev MemberState, curr Snapshot, now time.Time) []Event {
+	if prev.CheckedAt.IsZero() {
+		return nil
+	}
+
+	var detected []Event
+	newEvent := func(t Type) Event {
+		return Event{ID: NewID(), Type: t, Handle: curr.Handle, Name: curr.Name, OccurredAt: now}
+	}
+
+	seen := make(map[string]bool, len(prev.Badges))
+	for _, id := range prev.Badges {
+		seen[id] = true
+	}
+
+	// Badges come newest first, report them oldest first.
+	for i := len(curr.Badges) - 1; i >= 0; i-- {
+		if badge := curr.Badges[i]; !seen[badge.ID] {
+			event := newEvent(BadgeEarned)
+			event.Badge = &badge
+			if !badge.EarnedAt.IsZero() {
+				event.OccurredAt = badge.EarnedAt
+			}
+			detected = append(detected, event)
+		}
+	}
+
+	for _, certification := range curr.Certifications {
+		certification := certification
+		expired, known := prev.Certifications[certification.Title]
+
+		switch {
+		case !known && !certification.Expired:
+			event := newEvent(CertificationEarned)
+			event.Certification = &certification
+			detected = append(detected, event)
+		case known && !expired && certification.Expired:
+			event := newEvent(CertificationExpired)
+			event.Certification = &certification
+			detected = append(detected, event)
+		}
+	}
+
+	if curr.Rank.Title != "" && curr.Rank.RequiredPoints > prev.RankPoints && prev.RankTitle != "" {
+		rank := curr.Rank
+		rank.Previous = prev.RankTitle
+		event := newEvent(RankUp)
+		event.Rank = &rank
+		detected = append(detected, event)
+	}
+
+	return detected
+}
@@ -0,0 +1,142 @@
+package events
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDetect(t *testing.T) {
+	lastCheck := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	now := lastCheck.Add(time.Hour)
+	earnedAt := lastCheck.Add(30 * time.Minute)
+
+	apex := Badge{ID: "b1", Title: "Apex Basics"}
+	flow := Badge{ID: "b2", Title: "Flow Builder", EarnedAt: earnedAt}
+	lwc := Badge{ID: "b3", Title: "Lightning Web Components"}
+	admin := Certification{Title: "Administrator"}
+	developer := Certification{Title: "Platform Developer I"}
+	expiredAdmin := Certification{Title: "Administrator", Expired: true}
+	ranger := Rank{Title: "Ranger", RequiredPoints: 50000}
+	expeditioner := Rank{Title: "Expeditioner", RequiredPoints: 35000}
+
+	known := MemberState{
+		Badges:         []string{"b1"},
+		Certifications: map[string]bool{"Administrator": false},
+		RankTitle:      "Expeditioner",
+		RankPoints:     35000,
+		CheckedAt:      lastCheck,
+	}
+
+	tests := []struct {
+		name string
+		prev MemberState
+		curr Snapshot
+		want []Event
+	}{
+		{
+			name: "first check",
+			prev: MemberState{},
+			curr: Snapshot{Badges: []Badge{lwc, apex}, Certifications: []Certification{admin}, Rank: ranger},
+		},
+		{
+			name: "nothing changed",
+			prev: known,
+			curr: Snapshot{Badges: []Badge{apex}, Certifications: []Certification{admin}, Rank: expeditioner},
+		},
+		{
+			name: "badges earned, oldest first",
+			prev: known,
+			curr: Snapshot{Badges: []Badge{lwc, flow, apex}, Certifications: []Certification{admin}, Rank: expeditioner},
+			want: []Event{
+				{Type: BadgeEarned, OccurredAt: earnedAt, Badge: &flow},
+				{Type: BadgeEarned, OccurredAt: now, Badge: &lwc},
+			},
+		},
+		{
+			name: "older badges dropping out of the snapshot",
+			prev: MemberState{Badges: []string{"b3", "b2", "b1"}, RankTitle: "Expeditioner", RankPoints: 35000, CheckedAt: lastCheck},
+			curr: Snapshot{Badges: []Badge{lwc}, Rank: expeditioner},
+		},
+		{
+			name: "certification earned",
+			prev: known,
+			curr: Snapshot{Badges: []Badge{apex}, Certifications: []Certification{admin, developer}, Rank: expeditioner},
+			want: []Event{{Type: CertificationEarned, OccurredAt: now, Certification: &developer}},
+		},
+		{
+			name: "expired certification seen for the first time",
+			prev: known,
+			curr: Snapshot{Badges: []Badge{apex}, Certifications: []Certification{admin, {Title: "Sales Cloud", Expired: true}}, Rank: expeditioner},
+		},
+		{
+			name: "certification expired",
+			prev: known,
+			curr: Snapshot{Badges: []Badge{apex}, Certifications: []Certification{expiredAdmin}, Rank: expeditioner},
+			want: []Event{{Type: CertificationExpired, OccurredAt: now, Certification: &expiredAdmin}},
+		},
+		{
+			name: "certification already expired",
+			prev: MemberState{Certifications: map[string]bool{"Administrator": true}, RankTitle: "Expeditioner", RankPoints: 35000, CheckedAt: lastCheck},
+			curr: Snapshot{Certifications: []Certification{expiredAdmin}, Rank: expeditioner},
+		},
+		{
+			name: "rank up",
+			prev: known,
+			curr: Snapshot{Badges: []Badge{apex}, Certifications: []Certification{admin}, Rank: ranger},
+			want: []Event{{Type: RankUp, OccurredAt: now, Rank: &Rank{Title: "Ranger", RequiredPoints: 50000, Previous: "Expeditioner"}}},
+		},
+		{
+			name: "rank unknown",
+			prev: known,
+			curr: Snapshot{Badges: []Badge{apex}, Certifications: []Certification{admin}},
+		},
+		{
+			name: "no previous rank",
+			prev: MemberState{Badges: []string{"b1"}, CheckedAt: lastCheck},
+			curr: Snapshot{Badges: []Badge{apex}, Rank: ranger},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.curr.Handle, tt.curr.Name = "astro", "Astro Nomical"
+
+			got := Detect(tt.prev, tt.curr, now)
+
+			for i := range got {
+				if got[i].ID == "" {
+					t.Errorf("event %d has no ID", i)
+				}
+				if got[i].Handle != "astro" || got[i].Name != "Astro Nomical" {
+					t.Errorf("event %d is for %s (%s), want astro (Astro Nomical)", i, got[i].Handle, got[i].Name)
+				}
+				got[i].ID, got[i].Handle, got[i].Name = "", "", ""
+			}
+			if len(got) != 0 || len(tt.want) != 0 {
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("Detect() = %s, want %s", describe(got), describe(tt.want))
+				}
+			}
+		})
+	}
+}
+
+// describe lists the type and subject of each event, for readable failures.
+func describe(detected []Event) []string {
+	var described []string
+	for _, event := range detected {
+		subject := ""
+		switch {
+		case event.Badge != nil:
+			subject = event.Badge.Title
+		case event.Certification != nil:
+			subject = event.Certification.Title
+		case event.Rank != nil:
+			subject = event.Rank.Previous + " -> " + event.Rank.Title
+		}
+		described = append(described, string(event.Type)+" "+subject+" at "+event.OccurredAt.Format(time.Kitchen))
+	}
+
+	return described
+}
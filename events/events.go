@@ -0,0 +1,82 @@
+package events
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// Type is the kind of change an Event describes.
+type Type string
+
+const (
+	// BadgeEarned is sent when a Trailblazer earns a badge.
+	BadgeEarned Type = "badge.earned"
+	// CertificationEarned is sent when a Trailblazer earns a Salesforce certification.
+	CertificationEarned Type = "certification.earned"
+	// CertificationExpired is sent when one of a Trailblazer's certifications expires.
+	CertificationExpired Type = "certification.expired"
+	// RankUp is sent when a Trailblazer reaches a higher Trailhead rank.
+	RankUp Type = "rank.up"
+	// Ping is sent to test a webhook.
+	Ping Type = "ping"
+)
+
+// Types are the event types a webhook can subscribe to.
+var Types = []Type{BadgeEarned, CertificationEarned, CertificationExpired, RankUp}
+
+// Event is a change detected in a Trailblazer's profile. Exactly one of Badge, Certification and
+// Rank is set, depending on Type.
+type Event struct {
+	ID            string         `json:"id"`
+	Type          Type           `json:"type"`
+	Handle        string         `json:"handle,omitempty"`
+	Name          string         `json:"name,omitempty"`
+	Leaderboards  []string       `json:"leaderboards,omitempty"`
+	OccurredAt    time.Time      `json:"occurredAt"`
+	Badge         *Badge         `json:"badge,omitempty"`
+	Certification *Certification `json:"certification,omitempty"`
+	Rank          *Rank          `json:"rank,omitempty"`
+}
+
+// Badge is a badge a Trailblazer has earned.
+type Badge struct {
+	ID          string    `json:"id"`
+	Title       string    `json:"title"`
+	Type        string    `json:"type"`
+	Icon        string    `json:"icon"`
+	URL         string    `json:"url"`
+	Description string    `json:"description"`
+	EarnedAt    time.Time `json:"earnedAt"`
+}
+
+// Certification is a Salesforce certification a Trailblazer has earned.
+type Certification struct {
+	Title         string `json:"title"`
+	LogoURL       string `json:"logoUrl"`
+	InfoURL       string `json:"infoUrl"`
+	DateCompleted string `json:"dateCompleted"`
+	DateExpired   string `json:"dateExpired,omitempty"`
+	Expired       bool   `json:"expired"`
+}
+
+// Rank is a Trailblazer's Trailhead rank and totals. In a RankUp event, Previous is the rank they
+// moved up from.
+type Rank struct {
+	Title          string `json:"title"`
+	ImageURL       string `json:"imageUrl"`
+	RequiredPoints int    `json:"requiredPoints"`
+	Points         int    `json:"points"`
+	Badges         int    `json:"badges"`
+	Previous       string `json:"previous,omitempty"`
+}
+
+// NewID returns a random ID for an event or delivery.
+func NewID() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(b)
+}
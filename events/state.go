@@ -0,0 +1,55 @@
+package events
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/meruff/go-trailhead-leaderboard-api/jsonfile"
+)
+
+// StateStore keeps the last known state of each tracked Trailblazer, writing it to a JSON file on
+// every change so events aren't sent twice after a restart.
+type StateStore struct {
+	path string
+
+	mu      sync.RWMutex
+	members map[string]MemberState
+}
+
+// OpenStateStore loads the StateStore saved at path. A missing file is treated as an empty store.
+func OpenStateStore(path string) (*StateStore, error) {
+	s := &StateStore{path: path, members: map[string]MemberState{}}
+
+	if err := jsonfile.Load(path, &s.members); err != nil {
+		return nil, err
+	}
+	if s.members == nil {
+		s.members = map[string]MemberState{}
+	}
+
+	return s, nil
+}
+
+// Get returns the last known state of a Trailblazer, and false if they haven't been checked yet.
+func (s *StateStore) Get(handle string) (MemberState, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	state, ok := s.members[strings.ToLower(handle)]
+	return state, ok
+}
+
+// Put records the state of a Trailblazer.
+func (s *StateStore) Put(handle string, state MemberState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.members[strings.ToLower(handle)] = state
+
+	return jsonfile.Save(s.path, s.members)
+}
+
+// Check reports whether the file the StateStore is saved to can still be read and written.
+func (s *StateStore) Check() error {
+	return jsonfile.Check(s.path)
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"log/slog"
+
+	"github.com/meruff/go-trailhead-leaderboard-api/trailheadtest"
+)
+
+// startFakeUpstream starts a fake Trailhead serving the Trailblazers in the fixtures file at path,
+// or the built in ones if path is empty, and points the upstream client at it.
+func startFakeUpstream(path string) (*trailheadtest.Server, error) {
+	trailblazers := trailheadtest.DefaultTrailblazers()
+	if path != "" {
+		var err error
+		if trailblazers, err = trailheadtest.LoadTrailblazers(path); err != nil {
+			return nil, err
+		}
+	}
+
+	fake := trailheadtest.NewServer(trailblazers)
+	upstream.GraphqlURL, upstream.ProfileURL = fake.GraphqlURL, fake.ProfileURL
+
+	handles := make([]string, len(trailblazers))
+	for i, trailblazer := range trailblazers {
+		handles[i] = trailblazer.Handle
+	}
+	slog.Warn("serving fixtures from a fake Trailhead, not calling Trailhead", "url", fake.URL, "handles", handles)
+
+	return fake, nil
+}
@@ -0,0 +1,89 @@
+package feed
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+// Namespace is the XML namespace of Atom documents.
+const Namespace = "http://www.w3.org/2005/Atom"
+
+// Feed is an Atom feed, as defined by RFC 4287.
+type Feed struct {
+	XMLName  xml.Name  `xml:"feed"`
+	Xmlns    string    `xml:"xmlns,attr"`
+	ID       string    `xml:"id"`
+	Title    string    `xml:"title"`
+	Subtitle string    `xml:"subtitle,omitempty"`
+	Updated  time.Time `xml:"updated"`
+	Links    []Link    `xml:"link"`
+	Icon     string    `xml:"icon,omitempty"`
+	Entries  []Entry   `xml:"entry"`
+}
+
+// Entry is a single item in a Feed.
+type Entry struct {
+	ID        string    `xml:"id"`
+	Title     string    `xml:"title"`
+	Updated   time.Time `xml:"updated"`
+	Published time.Time `xml:"published"`
+	Author    *Person   `xml:"author,omitempty"`
+	Links     []Link    `xml:"link"`
+	Category  *Category `xml:"category,omitempty"`
+	Summary   string    `xml:"summary,omitempty"`
+	Content   *Content  `xml:"content,omitempty"`
+}
+
+// Link points from a Feed or Entry to a web page. Rel is "alternate" when unset.
+type Link struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+// Person is the author of an Entry.
+type Person struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri,omitempty"`
+}
+
+// Category classifies an Entry.
+type Category struct {
+	Term string `xml:"term,attr"`
+}
+
+// Content is the body of an Entry. Type is "text", "html" or "xhtml".
+type Content struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// Write writes the feed to w as an Atom document. If Updated isn't set, it's taken from the most
+// recently updated entry.
+func (f Feed) Write(w io.Writer) error {
+	f.Xmlns = Namespace
+	if f.Updated.IsZero() {
+		for _, entry := range f.Entries {
+			if entry.Updated.After(f.Updated) {
+				f.Updated = entry.Updated
+			}
+		}
+	}
+	if f.Updated.IsZero() {
+		f.Updated = time.Now()
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(f); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,116 @@
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWrite(t *testing.T) {
+	older := time.Date(2024, 10, 15, 10, 0, 0, 0, time.UTC)
+	newer := time.Date(2024, 12, 15, 10, 0, 0, 0, time.UTC)
+	set := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		feed        Feed
+		wantUpdated time.Time
+		want        []string
+		dontWant    []string
+	}{
+		{
+			name: "updated from the newest entry",
+			feed: Feed{ID: "urn:feed", Title: "Badges", Entries: []Entry{
+				{ID: "urn:a", Title: "Older", Updated: older},
+				{ID: "urn:b", Title: "Newer", Updated: newer},
+			}},
+			wantUpdated: newer,
+		},
+		{
+			name:        "updated set",
+			feed:        Feed{ID: "urn:feed", Title: "Badges", Updated: set, Entries: []Entry{{ID: "urn:a", Updated: newer}}},
+			wantUpdated: set,
+		},
+		{
+			name: "optional elements left out",
+			feed: Feed{ID: "urn:feed", Title: "Badges", Entries: []Entry{{ID: "urn:a", Title: "Plain", Updated: older}}},
+			dontWant: []string{
+				"<subtitle>", "<icon>", "<author>", "<category", "<summary>", "<content", ` rel=""`, ` type=""`,
+			},
+			wantUpdated: older,
+		},
+		{
+			name: "entry elements and escaping",
+			feed: Feed{ID: "urn:feed", Title: "Q&A <badges>", Links: []Link{{Href: "https://example.com/feed?a=1&b=2", Rel: "self"}}, Entries: []Entry{{
+				ID:       "urn:a",
+				Title:    "astro earned Apex & Triggers",
+				Updated:  older,
+				Author:   &Person{Name: "astro", URI: "https://example.com/astro"},
+				Category: &Category{Term: "MODULE"},
+				Content:  &Content{Type: "html", Body: `<p><img src="icon.png"></p>`},
+			}}},
+			want: []string{
+				"<title>Q&amp;A &lt;badges&gt;</title>",
+				`<link href="https://example.com/feed?a=1&amp;b=2" rel="self"></link>`,
+				"<author>\n      <name>astro</name>\n      <uri>https://example.com/astro</uri>\n    </author>",
+				`<category term="MODULE"></category>`,
+				`<content type="html">&lt;p&gt;&lt;img src=&#34;icon.png&#34;&gt;&lt;/p&gt;</content>`,
+			},
+			wantUpdated: older,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := tt.feed.Write(&out); err != nil {
+				t.Fatal(err)
+			}
+			written := out.String()
+
+			if !strings.HasPrefix(written, xml.Header+`<feed xmlns="`+Namespace+`">`) {
+				t.Errorf("Write() wrote\n%s\nwant an XML declaration and an Atom feed element", written)
+			}
+			for _, s := range tt.want {
+				if !strings.Contains(written, s) {
+					t.Errorf("Write() wrote\n%s\nwant it to contain %s", written, s)
+				}
+			}
+			for _, s := range tt.dontWant {
+				if strings.Contains(written, s) {
+					t.Errorf("Write() wrote\n%s\nwant no %s", written, s)
+				}
+			}
+
+			var parsed Feed
+			if err := xml.Unmarshal(out.Bytes(), &parsed); err != nil {
+				t.Fatalf("Write() wrote invalid XML: %v\n%s", err, written)
+			}
+			if !parsed.Updated.Equal(tt.wantUpdated) {
+				t.Errorf("updated = %s, want %s", parsed.Updated, tt.wantUpdated)
+			}
+			if len(parsed.Entries) != len(tt.feed.Entries) {
+				t.Errorf("wrote %d entries, want %d", len(parsed.Entries), len(tt.feed.Entries))
+			}
+		})
+	}
+}
+
+func TestWriteWithoutEntries(t *testing.T) {
+	before := time.Now().Add(-time.Second)
+
+	var out bytes.Buffer
+	if err := (Feed{ID: "urn:feed", Title: "Badges"}).Write(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	var parsed Feed
+	if err := xml.Unmarshal(out.Bytes(), &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Updated.Before(before) {
+		t.Errorf("updated = %s, want the time of writing", parsed.Updated)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/meruff/go-trailhead-leaderboard-api/feed"
+	"github.com/meruff/go-trailhead-leaderboard-api/trailhead"
+)
+
+const (
+	// feedBadgesPerMember is how many recent badges are read for each Trailblazer in a feed.
+	feedBadgesPerMember = 20
+	// maxFeedEntries is the most entries a leaderboard feed lists.
+	maxFeedEntries = 50
+	// feedCacheControl lets feed readers cache a feed for 15 minutes.
+	feedCacheControl = "public, max-age=900"
+)
+
+// memberAward is a badge earned by a Trailblazer, with the time it was earned.
+type memberAward struct {
+	Handle   string
+	Award    trailhead.EarnedAward
+	EarnedAt time.Time
+}
+
+// badgesFeedHandler returns an Atom feed of the badges a Trailblazer earned most recently.
+func badgesFeedHandler(w http.ResponseWriter, r *http.Request) {
+	userAlias, ok := resolveHandle(r.Context(), w, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	awards, err := getRecentAwards(r.Context(), userAlias)
+	if err != nil {
+		writeCalloutErrorToBrowser(w, err, "No badge data returned from Trailhead.")
+		return
+	}
+
+	writeFeedToBrowser(w, feed.Feed{
+		ID:    requestURL(r),
+		Title: fmt.Sprintf("Badges earned by %s", userAlias),
+		Links: []feed.Link{
+			{Href: requestURL(r), Rel: "self", Type: "application/atom+xml"},
+			{Href: trailblazerUrl + userAlias},
+		},
+		Entries: awardEntries(awards),
+	})
+}
+
+// leaderboardFeedHandler returns an Atom feed of the badges the members of a leaderboard earned most
+// recently. Members whose badges can't be read are left out.
+func leaderboardFeedHandler(w http.ResponseWriter, r *http.Request) {
+	lb, err := store.Get(mux.Vars(r)["name"])
+	if err != nil {
+		writeErrorToBrowser(w, fmt.Sprintf("No leaderboard named %s.", mux.Vars(r)["name"]), 404)
+		return
+	}
+
+	var (
+		mu     sync.Mutex
+		awards []memberAward
+	)
+	forEachHandle(memberHandles(lb), func(i int, handle string) {
+		memberAwards, err := getRecentAwards(r.Context(), handle)
+		if err != nil {
+			slog.WarnContext(r.Context(), "retrieving badges", "handle", handle, "error", err)
+			return
+		}
+
+		mu.Lock()
+		awards = append(awards, memberAwards...)
+		mu.Unlock()
+	})
+
+	sort.SliceStable(awards, func(i, j int) bool {
+		return awards[i].EarnedAt.After(awards[j].EarnedAt)
+	})
+	if len(awards) > maxFeedEntries {
+		awards = awards[:maxFeedEntries]
+	}
+
+	writeFeedToBrowser(w, feed.Feed{
+		ID:    requestURL(r),
+		Title: fmt.Sprintf("Badges earned on the %s leaderboard", lb.Name),
+		Links: []feed.Link{
+			{Href: requestURL(r), Rel: "self", Type: "application/atom+xml"},
+		},
+		Entries: awardEntries(awards),
+	})
+}
+
+// getRecentAwards returns the badges a Trailblazer earned most recently, newest first.
+func getRecentAwards(ctx context.Context, userAlias string) ([]memberAward, error) {
+	badges, err := getTrailheadBadges(ctx, userAlias, trailhead.BadgeRequest{Count: feedBadgesPerMember})
+	if err != nil {
+		return nil, err
+	}
+
+	var awards []memberAward
+	for _, edge := range badges.Data.Profile.EarnedAwards.Edges {
+		earnedAt, err := time.Parse(time.RFC3339, edge.Node.EarnedAt)
+		if err != nil {
+			slog.WarnContext(ctx, "parsing badge earned date", "earned_at", edge.Node.EarnedAt, "badge_id", edge.Node.ID, "error", err)
+			continue
+		}
+
+		awards = append(awards, memberAward{Handle: userAlias, Award: edge.Node, EarnedAt: earnedAt})
+	}
+
+	return awards, nil
+}
+
+// awardEntries turns earned badges into feed entries, showing the badge icon and description.
+func awardEntries(awards []memberAward) []feed.Entry {
+	entries := make([]feed.Entry, 0, len(awards))
+
+	for _, award := range awards {
+		content := fmt.Sprintf("<p>%s</p>", html.EscapeString(award.Award.Award.Content.Description))
+		if award.Award.Award.Icon != "" {
+			content = fmt.Sprintf(
+				`<p><img src="%s" alt="" width="96" height="96"></p>`,
+				html.EscapeString(award.Award.Award.Icon),
+			) + content
+		}
+
+		entry := feed.Entry{
+			ID:        "urn:trailhead:earned-award:" + award.Award.ID,
+			Title:     fmt.Sprintf("%s earned %s", award.Handle, award.Award.Award.Title),
+			Updated:   award.EarnedAt,
+			Published: award.EarnedAt,
+			Author:    &feed.Person{Name: award.Handle, URI: trailblazerUrl + award.Handle},
+			Summary:   award.Award.Award.Content.Description,
+			Content:   &feed.Content{Type: "html", Body: content},
+		}
+		if award.Award.Award.Type != "" {
+			entry.Category = &feed.Category{Term: award.Award.Award.Type}
+		}
+		if award.Award.Award.Content.WebURL != "" {
+			entry.Links = []feed.Link{{Href: award.Award.Award.Content.WebURL}}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+// requestURL returns the absolute URL of a request, using X-Forwarded-Proto when behind a proxy
+// like Heroku's router.
+func requestURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+
+	return scheme + "://" + r.Host + r.URL.Path
+}
+
+// writeFeedToBrowser writes an Atom feed to the browser.
+func writeFeedToBrowser(w http.ResponseWriter, f feed.Feed) {
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		slog.Error("writing feed", "feed_id", f.ID, "error", err)
+		writeErrorToBrowser(w, "Problem writing feed.", 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Header().Set("Cache-Control", feedCacheControl)
+	w.Write(buf.Bytes())
+}
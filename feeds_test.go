@@ -0,0 +1,167 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/xml"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/meruff/go-trailhead-leaderboard-api/feed"
+	"github.com/meruff/go-trailhead-leaderboard-api/leaderboard"
+	"github.com/meruff/go-trailhead-leaderboard-api/trailhead"
+)
+
+func TestAwardEntries(t *testing.T) {
+	earnedAt := time.Date(2024, 12, 15, 10, 0, 0, 0, time.UTC)
+	award := func(icon, badgeType, webURL, description string) memberAward {
+		var a trailhead.EarnedAward
+		a.ID = "0Ep001"
+		a.Award.Title = "Apex Triggers"
+		a.Award.Icon = icon
+		a.Award.Type = badgeType
+		a.Award.Content.WebURL = webURL
+		a.Award.Content.Description = description
+		return memberAward{Handle: "astro", Award: a, EarnedAt: earnedAt}
+	}
+
+	tests := []struct {
+		name         string
+		award        memberAward
+		wantContent  string
+		wantCategory *feed.Category
+		wantLinks    []feed.Link
+	}{
+		{
+			name:         "every detail",
+			award:        award("https://example.com/apex.png", "MODULE", "https://example.com/apex", "Learn about Apex Triggers."),
+			wantContent:  `<p><img src="https://example.com/apex.png" alt="" width="96" height="96"></p><p>Learn about Apex Triggers.</p>`,
+			wantCategory: &feed.Category{Term: "MODULE"},
+			wantLinks:    []feed.Link{{Href: "https://example.com/apex"}},
+		},
+		{
+			name:        "no icon, type or link",
+			award:       award("", "", "", "Learn about Apex Triggers."),
+			wantContent: `<p>Learn about Apex Triggers.</p>`,
+		},
+		{
+			name:        "markup escaped",
+			award:       award(`https://example.com/a.png?x="1"`, "", "", "Use <b> & <i>."),
+			wantContent: `<p><img src="https://example.com/a.png?x=&#34;1&#34;" alt="" width="96" height="96"></p><p>Use &lt;b&gt; &amp; &lt;i&gt;.</p>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries := awardEntries([]memberAward{tt.award})
+			if len(entries) != 1 {
+				t.Fatalf("awardEntries() = %d entries, want 1", len(entries))
+			}
+			entry := entries[0]
+
+			if entry.ID != "urn:trailhead:earned-award:0Ep001" || entry.Title != "astro earned Apex Triggers" {
+				t.Errorf("entry is %q titled %q", entry.ID, entry.Title)
+			}
+			if !entry.Updated.Equal(earnedAt) || !entry.Published.Equal(earnedAt) {
+				t.Errorf("entry updated %s, published %s, want both %s", entry.Updated, entry.Published, earnedAt)
+			}
+			if entry.Author == nil || entry.Author.Name != "astro" || entry.Author.URI != trailblazerUrl+"astro" {
+				t.Errorf("Author = %+v, want astro and their profile", entry.Author)
+			}
+			if entry.Content == nil || entry.Content.Type != "html" || entry.Content.Body != tt.wantContent {
+				t.Errorf("Content = %+v, want html %s", entry.Content, tt.wantContent)
+			}
+			if (entry.Category == nil) != (tt.wantCategory == nil) || (entry.Category != nil && *entry.Category != *tt.wantCategory) {
+				t.Errorf("Category = %+v, want %+v", entry.Category, tt.wantCategory)
+			}
+			if len(entry.Links) != len(tt.wantLinks) || (len(entry.Links) > 0 && entry.Links[0] != tt.wantLinks[0]) {
+				t.Errorf("Links = %+v, want %+v", entry.Links, tt.wantLinks)
+			}
+		})
+	}
+}
+
+func TestRequestURL(t *testing.T) {
+	tests := []struct {
+		name           string
+		tls            bool
+		forwardedProto string
+		want           string
+	}{
+		{name: "http", want: "http://api.example.com/leaderboards/team/feed.atom"},
+		{name: "tls", tls: true, want: "https://api.example.com/leaderboards/team/feed.atom"},
+		{name: "behind a proxy", forwardedProto: "https", want: "https://api.example.com/leaderboards/team/feed.atom"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "http://api.example.com/leaderboards/team/feed.atom?api_key=s3cret", nil)
+			if tt.tls {
+				r.TLS = &tls.ConnectionState{}
+			}
+			if tt.forwardedProto != "" {
+				r.Header.Set("X-Forwarded-Proto", tt.forwardedProto)
+			}
+
+			if got := requestURL(r); got != tt.want {
+				t.Errorf("requestURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLeaderboardFeedHandler(t *testing.T) {
+	useFakeUpstream(t)
+	useStore(t)
+
+	err := store.Put(leaderboard.Leaderboard{Name: "team", Members: []leaderboard.Member{
+		{Handle: "astro"}, {Handle: "hidden"}, {Handle: "matruff"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := mux.NewRouter()
+	r.HandleFunc("/leaderboards/{name}/feed.atom", leaderboardFeedHandler)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "http://api.example.com/leaderboards/team/feed.atom", nil))
+	if w.Code != 200 {
+		t.Fatalf("GET /leaderboards/team/feed.atom = %d: %s", w.Code, w.Body)
+	}
+	if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "application/atom+xml") {
+		t.Errorf("Content-Type = %q, want application/atom+xml", got)
+	}
+	if got := w.Header().Get("Cache-Control"); got != feedCacheControl {
+		t.Errorf("Cache-Control = %q, want %q", got, feedCacheControl)
+	}
+
+	var written feed.Feed
+	if err := xml.Unmarshal(w.Body.Bytes(), &written); err != nil {
+		t.Fatalf("body isn't valid XML: %v\n%s", err, w.Body)
+	}
+
+	if written.ID != "http://api.example.com/leaderboards/team/feed.atom" || written.Title != "Badges earned on the team leaderboard" {
+		t.Errorf("feed is %q titled %q", written.ID, written.Title)
+	}
+	// matruff's 12 badges and astro's 3, the hidden profile having none to show.
+	if len(written.Entries) != 15 {
+		t.Fatalf("feed has %d entries, want 15", len(written.Entries))
+	}
+	for i := 1; i < len(written.Entries); i++ {
+		if written.Entries[i].Updated.After(written.Entries[i-1].Updated) {
+			t.Errorf("entry %d (%s) is newer than entry %d (%s)", i, written.Entries[i].Updated, i-1, written.Entries[i-1].Updated)
+		}
+	}
+	if !written.Updated.Equal(written.Entries[0].Updated) {
+		t.Errorf("feed updated %s, want the newest entry's %s", written.Updated, written.Entries[0].Updated)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/leaderboards/nope/feed.atom", nil))
+	if w.Code != 404 {
+		t.Errorf("GET /leaderboards/nope/feed.atom = %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/meruff/go-trailhead-leaderboard-api/config"
+	"github.com/meruff/go-trailhead-leaderboard-api/trailhead"
+)
+
+// ready is set once the configuration has been loaded and the server is about to serve requests.
+var ready atomic.Bool
+
+// upstreamProbes checks that Trailhead still answers the way the API expects.
+var upstreamProbes *upstreamProber
+
+// check is the outcome of one readiness check.
+type check struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// probeResult is the outcome of one probe of Trailhead.
+type probeResult struct {
+	Name      string  `json:"name"`
+	Operation string  `json:"operation"`
+	Handle    string  `json:"handle"`
+	OK        bool    `json:"ok"`
+	Parsed    bool    `json:"parsed"`
+	LatencyMs float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// upstreamStatus is the report returned by /status/upstream.
+type upstreamStatus struct {
+	OK        bool                      `json:"ok"`
+	CheckedAt time.Time                 `json:"checkedAt"`
+	Probes    []probeResult             `json:"probes"`
+	Breakers  []trailhead.BreakerStatus `json:"breakers"`
+}
+
+// upstreamProber probes Trailhead with a client of its own that makes a single attempt and never
+// serves stale responses, so the report reflects Trailhead right now. Reports are reused for
+// maxAge so frequent checks by a load balancer don't add to the load on Trailhead.
+type upstreamProber struct {
+	client  *trailhead.Client
+	handle  string
+	timeout time.Duration
+	maxAge  time.Duration
+
+	mu   sync.Mutex
+	last upstreamStatus
+}
+
+// upstreamProberFromConfig builds the prober for the upstream client. Probes look up the probe
+// handle, time out after the probe timeout, and are reused for the probe cache duration.
+func upstreamProberFromConfig(upstream *trailhead.Client, conf config.Probe) *upstreamProber {
+	prober := &upstreamProber{
+		handle:  conf.Handle,
+		timeout: conf.Timeout.Or(10 * time.Second),
+		maxAge:  conf.Cache.Or(30 * time.Second),
+	}
+	if prober.handle == "" {
+		prober.handle = "matruff"
+	}
+
+	prober.client = trailhead.NewClient(upstream.GraphqlURL, upstream.ProfileURL)
+	prober.client.Headers = upstream.Headers
+	prober.client.HTTPClient = upstream.HTTPClient
+	prober.client.Limiter = upstream.Limiter
+	prober.client.Observer = upstream.Observer
+	prober.client.Retry.MaxAttempts = 1
+	prober.client.SetStaleCache(0, 0)
+
+	return prober
+}
+
+// Status returns the last report if it's recent enough, otherwise probes Trailhead again.
+func (p *upstreamProber) Status(ctx context.Context) upstreamStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if time.Since(p.last.CheckedAt) < p.maxAge {
+		return p.last
+	}
+
+	// Finish probing even if the caller gives up, the report is reused.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), p.timeout)
+	defer cancel()
+
+	probes := []func(context.Context) probeResult{p.probeGraphql, p.probeProfilePage}
+	status := upstreamStatus{OK: true, CheckedAt: time.Now(), Probes: make([]probeResult, len(probes))}
+
+	var wg sync.WaitGroup
+	for i, probe := range probes {
+		wg.Add(1)
+		go func(i int, probe func(context.Context) probeResult) {
+			defer wg.Done()
+			status.Probes[i] = probe(ctx)
+		}(i, probe)
+	}
+	wg.Wait()
+
+	for _, result := range status.Probes {
+		status.OK = status.OK && result.OK && result.Parsed
+	}
+
+	p.last = status
+
+	return status
+}
+
+// probeGraphql runs a rank query, one of the cheapest, and checks the response has rank data.
+func (p *upstreamProber) probeGraphql(ctx context.Context) probeResult {
+	result := probeResult{Name: "graphql", Operation: "GetTrailheadRank", Handle: p.handle}
+
+	start := time.Now()
+	body, err := p.client.Query(ctx, result.Operation, trailhead.GetGraphqlPayload(result.Operation, p.handle, "", trailhead.GetRankQuery()))
+	result.LatencyMs = milliseconds(time.Since(start))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.OK = true
+
+	var rank trailhead.Rank
+	if err := json.Unmarshal(body, &rank); err != nil {
+		result.Error = "parsing response: " + err.Error()
+		return result
+	}
+
+	if rank.Data.Profile.Typename != "PublicProfile" || rank.Data.Profile.TrailheadStats.Rank.Title == "" {
+		result.Error = "response has no rank data"
+		return result
+	}
+	result.Parsed = true
+
+	return result
+}
+
+// probeProfilePage fetches a profile page and checks profile data can still be found on it.
+func (p *upstreamProber) probeProfilePage(ctx context.Context) probeResult {
+	result := probeResult{Name: "profile", Operation: "ProfilePage", Handle: p.handle}
+
+	start := time.Now()
+	page, err := p.client.ProfilePage(ctx, p.handle)
+	result.LatencyMs = milliseconds(time.Since(start))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.OK = true
+
+	if _, err := trailhead.ParseProfilePage(page); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Parsed = true
+
+	return result
+}
+
+// healthzHandler reports that the process is alive.
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	encodeAndWriteToBrowser(w, map[string]string{"status": "ok"})
+}
+
+// readyzHandler reports whether the app is ready to serve requests: its configuration is loaded and
+// every file it saves to can be read and written. Responds 503 if not.
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	checks := []check{{Name: "config", OK: ready.Load()}}
+	if !ready.Load() {
+		checks[0].Error = "configuration not loaded"
+	}
+
+	for _, file := range []struct {
+		name  string
+		check func() error
+	}{
+		{"leaderboards", store.Check},
+		{"webhooks", webhooks.Store.Check},
+		{"events", memberStates.Check},
+		{"digest", digestHistory.Check},
+	} {
+		c := check{Name: file.name, OK: true}
+		if err := file.check(); err != nil {
+			c.OK, c.Error = false, err.Error()
+		}
+		checks = append(checks, c)
+	}
+
+	status := http.StatusOK
+	for _, c := range checks {
+		if !c.OK {
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	writeStatusToBrowser(w, status, map[string]interface{}{"ready": status == http.StatusOK, "checks": checks})
+}
+
+// upstreamStatusHandler probes Trailhead's GraphQL API and profile pages and reports how long they
+// took and whether their responses could still be parsed. Responds 503 if any probe failed.
+func upstreamStatusHandler(w http.ResponseWriter, r *http.Request) {
+	status := upstreamProbes.Status(r.Context())
+	status.Breakers = upstream.Breakers()
+
+	code := http.StatusOK
+	if !status.OK {
+		code = http.StatusServiceUnavailable
+	}
+
+	writeStatusToBrowser(w, code, status)
+}
+
+// writeStatusToBrowser writes v as JSON with the given status code.
+func writeStatusToBrowser(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// milliseconds returns a duration in milliseconds, to the microsecond.
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
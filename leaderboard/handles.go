@@ -0,0 +1,126 @@
+package leaderboard
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// ErrHandleNotFound is returned by a ProfileIDLookup when no Trailblazer has the handle.
+var ErrHandleNotFound = errors.New("handle not found")
+
+// ProfileIDLookup returns the stable profile ID of the Trailblazer currently using a handle.
+type ProfileIDLookup func(ctx context.Context, handle string) (string, error)
+
+// HandleLookup returns the current handle of the Trailblazer with the given profile ID.
+type HandleLookup func(ctx context.Context, profileID string) (string, error)
+
+// HandleChange describes a member whose handle no longer points at their profile.
+type HandleChange struct {
+	ProfileID string `json:"profileId"`
+	OldHandle string `json:"oldHandle"`
+	// NewHandle is empty if the Trailblazer's new handle couldn't be found.
+	NewHandle string `json:"newHandle,omitempty"`
+	// Reclaimed is true when OldHandle now belongs to a different Trailblazer.
+	Reclaimed bool `json:"reclaimed"`
+}
+
+// CheckHandles looks up every member of every leaderboard and detects Trailblazers who have
+// renamed their handle. Members are updated to their new handle, and an alias is added from the
+// old handle unless another Trailblazer has since claimed it. Members without a profile ID have
+// it filled in. Lookups finding no profile ID and failures other than ErrHandleNotFound skip the
+// member, and the last failure is returned alongside the changes that were found. ctx is passed
+// to the lookups.
+func (s *Store) CheckHandles(ctx context.Context, lookupProfileID ProfileIDLookup, lookupHandle HandleLookup) ([]HandleChange, error) {
+	var changes []HandleChange
+	var lastErr error
+
+	for _, member := range s.members() {
+		profileID, err := lookupProfileID(ctx, member.Handle)
+		reclaimed := false
+
+		switch {
+		case err == nil && profileID == "":
+			// The lookup found the handle but not whose it is, i.e. a scraped profile page without
+			// an ID, so it says nothing about a rename.
+			continue
+		case err == nil && member.ProfileID == "":
+			if err := s.setProfileID(member.Handle, profileID); err != nil {
+				lastErr = err
+			}
+			continue
+		case err == nil && profileID == member.ProfileID:
+			continue
+		case err == nil:
+			reclaimed = true
+		case !errors.Is(err, ErrHandleNotFound):
+			lastErr = err
+			continue
+		}
+
+		change := HandleChange{ProfileID: member.ProfileID, OldHandle: member.Handle, Reclaimed: reclaimed}
+
+		if member.ProfileID != "" {
+			newHandle, err := lookupHandle(ctx, member.ProfileID)
+			if err != nil && !errors.Is(err, ErrHandleNotFound) {
+				lastErr = err
+			}
+			change.NewHandle = newHandle
+		}
+
+		if change.NewHandle != "" && !strings.EqualFold(change.NewHandle, member.Handle) {
+			if err := s.rename(change); err != nil {
+				lastErr = err
+			}
+		}
+
+		changes = append(changes, change)
+	}
+
+	return changes, lastErr
+}
+
+// members returns each distinct member across all leaderboards.
+func (s *Store) members() []Member {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seen := map[string]bool{}
+	var members []Member
+
+	for _, lb := range s.data.Leaderboards {
+		for _, member := range lb.Members {
+			key := strings.ToLower(member.Handle)
+			if !seen[key] {
+				seen[key] = true
+				members = append(members, member)
+			}
+		}
+	}
+
+	return members
+}
+
+// setProfileID records the profile ID of every member with the given handle.
+func (s *Store) setProfileID(handle, profileID string) error {
+	return s.UpdateMember(handle, func(m *Member) {
+		m.ProfileID = profileID
+	})
+}
+
+// rename moves members to their new handle and updates the alias table.
+func (s *Store) rename(change HandleChange) error {
+	var err error
+	if change.Reclaimed {
+		err = s.RemoveAlias(change.OldHandle)
+	} else {
+		err = s.AddAlias(change.OldHandle, change.NewHandle)
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.UpdateMember(change.OldHandle, func(m *Member) {
+		m.Handle = change.NewHandle
+	})
+}
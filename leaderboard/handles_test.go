@@ -0,0 +1,110 @@
+package leaderboard
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+type ctxKey struct{}
+
+func TestCheckHandlesRename(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "leaderboards.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Put(Leaderboard{Name: "team", Members: []Member{
+		{Handle: "astro", ProfileID: "005A"},
+		{Handle: "codey", ProfileID: "005C"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "check")
+	checkCtx := func(got context.Context) {
+		t.Helper()
+		if got.Value(ctxKey{}) != "check" {
+			t.Error("lookup wasn't passed the CheckHandles context")
+		}
+	}
+
+	lookupProfileID := func(ctx context.Context, handle string) (string, error) {
+		checkCtx(ctx)
+		if handle == "astro" {
+			return "", ErrHandleNotFound
+		}
+		return "005C", nil
+	}
+	lookupHandle := func(ctx context.Context, profileID string) (string, error) {
+		checkCtx(ctx)
+		return "astro-nomer", nil
+	}
+
+	changes, err := store.CheckHandles(ctx, lookupProfileID, lookupHandle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := HandleChange{ProfileID: "005A", OldHandle: "astro", NewHandle: "astro-nomer"}
+	if len(changes) != 1 || changes[0] != want {
+		t.Fatalf("CheckHandles() = %+v, want [%+v]", changes, want)
+	}
+
+	if newHandle, ok := store.Alias("astro"); !ok || newHandle != "astro-nomer" {
+		t.Errorf("Alias(astro) = %q, %v, want astro-nomer", newHandle, ok)
+	}
+
+	lb, err := store.Get("team")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lb.Members[0].Handle != "astro-nomer" {
+		t.Errorf("member handle = %q, want astro-nomer", lb.Members[0].Handle)
+	}
+}
+
+func TestCheckHandlesSkipsUnknownProfileIDs(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "leaderboards.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Put(Leaderboard{Name: "team", Members: []Member{
+		{Handle: "astro", ProfileID: "005A"},
+		{Handle: "codey"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A scraped profile page can find the handle without finding its profile ID.
+	lookupProfileID := func(ctx context.Context, handle string) (string, error) {
+		return "", nil
+	}
+	lookupHandle := func(ctx context.Context, profileID string) (string, error) {
+		t.Errorf("lookupHandle(%q) called for a member that wasn't renamed", profileID)
+		return "", nil
+	}
+
+	changes, err := store.CheckHandles(context.Background(), lookupProfileID, lookupHandle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("CheckHandles() = %+v, want no changes", changes)
+	}
+
+	if _, ok := store.Alias("astro"); ok {
+		t.Error("Alias(astro) was added for a member that wasn't renamed")
+	}
+	lb, err := store.Get("team")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lb.Members[0].Handle != "astro" || lb.Members[0].ProfileID != "005A" {
+		t.Errorf("members[0] = %+v, want astro unchanged", lb.Members[0])
+	}
+	if lb.Members[1].ProfileID != "" {
+		t.Errorf("members[1].ProfileID = %q, want it left empty", lb.Members[1].ProfileID)
+	}
+}
@@ -0,0 +1,201 @@
+package leaderboard
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/meruff/go-trailhead-leaderboard-api/jsonfile"
+)
+
+// ErrNotFound is returned when a leaderboard doesn't exist in the Store.
+var ErrNotFound = errors.New("leaderboard not found")
+
+// Member is a Trailblazer on a leaderboard. ProfileID is the stable ID Trailhead returns for the
+// profile, which doesn't change when the Trailblazer renames their handle.
+type Member struct {
+	Handle    string `json:"handle"`
+	ProfileID string `json:"profileId,omitempty"`
+}
+
+// Leaderboard is a named group of Trailblazers to rank against each other.
+type Leaderboard struct {
+	Name    string   `json:"name"`
+	Members []Member `json:"members"`
+}
+
+// storeData is the on-disk format of the Store.
+type storeData struct {
+	Leaderboards map[string]Leaderboard `json:"leaderboards"`
+	// Aliases maps old, lower cased handles to the handle the Trailblazer renamed to.
+	Aliases map[string]string `json:"aliases"`
+}
+
+// Store keeps leaderboards and handle aliases in memory, writing them to a JSON file on every
+// change.
+type Store struct {
+	path string
+
+	mu   sync.RWMutex
+	data storeData
+}
+
+// Open loads the Store saved at path. A missing file is treated as an empty store and is created
+// on the first change.
+func Open(path string) (*Store, error) {
+	s := &Store{
+		path: path,
+		data: storeData{Leaderboards: map[string]Leaderboard{}, Aliases: map[string]string{}},
+	}
+
+	if err := jsonfile.Load(path, &s.data); err != nil {
+		return nil, err
+	}
+
+	if s.data.Leaderboards == nil {
+		s.data.Leaderboards = map[string]Leaderboard{}
+	}
+	if s.data.Aliases == nil {
+		s.data.Aliases = map[string]string{}
+	}
+
+	return s, nil
+}
+
+// Names returns the names of all leaderboards, sorted.
+func (s *Store) Names() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.data.Leaderboards))
+	for name := range s.data.Leaderboards {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Get returns the leaderboard with the given name.
+func (s *Store) Get(name string) (Leaderboard, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	lb, ok := s.data.Leaderboards[name]
+	if !ok {
+		return Leaderboard{}, ErrNotFound
+	}
+
+	return copyLeaderboard(lb), nil
+}
+
+// Put creates or replaces a leaderboard.
+func (s *Store) Put(lb Leaderboard) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Leaderboards[lb.Name] = copyLeaderboard(lb)
+
+	return s.save()
+}
+
+// Delete removes a leaderboard.
+func (s *Store) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.data.Leaderboards[name]; !ok {
+		return ErrNotFound
+	}
+	delete(s.data.Leaderboards, name)
+
+	return s.save()
+}
+
+// UpdateMember applies a change to every member with the given handle across all leaderboards.
+func (s *Store) UpdateMember(handle string, update func(*Member)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name, lb := range s.data.Leaderboards {
+		for i := range lb.Members {
+			if strings.EqualFold(lb.Members[i].Handle, handle) {
+				update(&lb.Members[i])
+			}
+		}
+		s.data.Leaderboards[name] = lb
+	}
+
+	return s.save()
+}
+
+// Alias returns the current handle for a Trailblazer who has renamed from oldHandle, following
+// chains of renames.
+func (s *Store) Alias(oldHandle string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	handle, found := oldHandle, false
+	// Bound the walk by the table size so a cycle can't loop forever.
+	for i := 0; i <= len(s.data.Aliases); i++ {
+		next, ok := s.data.Aliases[strings.ToLower(handle)]
+		if !ok {
+			break
+		}
+		handle, found = next, true
+	}
+
+	return handle, found
+}
+
+// Aliases returns a copy of the alias table, keyed by lower cased old handle.
+func (s *Store) Aliases() map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	aliases := make(map[string]string, len(s.data.Aliases))
+	for oldHandle, newHandle := range s.data.Aliases {
+		aliases[oldHandle] = newHandle
+	}
+
+	return aliases
+}
+
+// AddAlias records that oldHandle has been renamed to newHandle.
+func (s *Store) AddAlias(oldHandle, newHandle string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Aliases[strings.ToLower(oldHandle)] = newHandle
+	// The new handle is live again, so it shouldn't redirect anywhere.
+	delete(s.data.Aliases, strings.ToLower(newHandle))
+
+	return s.save()
+}
+
+// RemoveAlias stops redirecting oldHandle, i.e. because another Trailblazer has claimed it.
+func (s *Store) RemoveAlias(oldHandle string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.data.Aliases, strings.ToLower(oldHandle))
+
+	return s.save()
+}
+
+// save writes the store to disk. Callers must hold the write lock.
+func (s *Store) save() error {
+	return jsonfile.Save(s.path, s.data)
+}
+
+// copyLeaderboard returns a leaderboard that doesn't share its member slice with lb.
+func copyLeaderboard(lb Leaderboard) Leaderboard {
+	lb.Members = append([]Member(nil), lb.Members...)
+	return lb
+}
+
+// Check reports whether the file the Store is saved to can still be read and written.
+func (s *Store) Check() error {
+	return jsonfile.Check(s.path)
+}
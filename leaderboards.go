@@ -0,0 +1,398 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/meruff/go-trailhead-leaderboard-api/leaderboard"
+	"github.com/meruff/go-trailhead-leaderboard-api/snapshot"
+	"github.com/meruff/go-trailhead-leaderboard-api/trailhead"
+)
+
+// leaderboardRequest is the body accepted when creating or replacing a leaderboard. Members can be
+// Trailblazer handles or user IDs.
+type leaderboardRequest struct {
+	Members []string `json:"members"`
+}
+
+// leaderboardsHandler lists the names of all stored leaderboards.
+func leaderboardsHandler(w http.ResponseWriter, r *http.Request) {
+	encodeAndWriteToBrowser(w, store.Names())
+}
+
+// leaderboardHandler returns a stored leaderboard and its members.
+func leaderboardHandler(w http.ResponseWriter, r *http.Request) {
+	lb, err := store.Get(mux.Vars(r)["name"])
+	if err != nil {
+		writeErrorToBrowser(w, fmt.Sprintf("No leaderboard named %s.", mux.Vars(r)["name"]), 404)
+		return
+	}
+
+	encodeAndWriteToBrowser(w, lb)
+}
+
+// leaderboardStandingsHandler returns a leaderboard's members with their current totals, ranked by
+// points, so a page can show the whole leaderboard with one request.
+func leaderboardStandingsHandler(w http.ResponseWriter, r *http.Request) {
+	lb, err := store.Get(mux.Vars(r)["name"])
+	if err != nil {
+		writeErrorToBrowser(w, fmt.Sprintf("No leaderboard named %s.", mux.Vars(r)["name"]), 404)
+		return
+	}
+
+	encodeAndWriteToBrowser(w, rankLeaderboard(r.Context(), lb))
+}
+
+// putLeaderboardHandler creates or replaces a leaderboard. Each member's profile ID is recorded so
+// the member can be followed if they rename their handle.
+func putLeaderboardHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdminKey(w, r) {
+		return
+	}
+
+	var body leaderboardRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeErrorToBrowser(w, `Expected a JSON body like {"members": ["handle", "005..."]}.`, 400)
+		return
+	}
+
+	lb := leaderboard.Leaderboard{Name: mux.Vars(r)["name"]}
+
+	for _, handleOrID := range body.Members {
+		identity, err := identities.Resolve(r.Context(), handleOrID)
+		if err != nil {
+			writeErrorToBrowser(w, fmt.Sprintf("Cannot find a trailblazer with the user ID %s.", handleOrID), 400)
+			return
+		}
+
+		member := leaderboard.Member{Handle: identity.Handle}
+
+		// A failed lookup isn't fatal, the profile ID is filled in on the next handle check.
+		if profileID, err := lookupProfileID(r.Context(), identity.Handle); err == nil {
+			member.ProfileID = profileID
+		} else {
+			slog.WarnContext(r.Context(), "looking up profile ID", "handle", identity.Handle, "error", err)
+		}
+
+		lb.Members = append(lb.Members, member)
+	}
+
+	if err := store.Put(lb); err != nil {
+		slog.ErrorContext(r.Context(), "saving leaderboard", "leaderboard", lb.Name, "error", err)
+		writeErrorToBrowser(w, "Problem saving leaderboard.", 500)
+		return
+	}
+
+	encodeAndWriteToBrowser(w, lb)
+}
+
+// deleteLeaderboardHandler removes a stored leaderboard.
+func deleteLeaderboardHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdminKey(w, r) {
+		return
+	}
+
+	err := store.Delete(mux.Vars(r)["name"])
+	if errors.Is(err, leaderboard.ErrNotFound) {
+		writeErrorToBrowser(w, fmt.Sprintf("No leaderboard named %s.", mux.Vars(r)["name"]), 404)
+		return
+	}
+
+	if err != nil {
+		slog.ErrorContext(r.Context(), "deleting leaderboard", "leaderboard", mux.Vars(r)["name"], "error", err)
+		writeErrorToBrowser(w, "Problem deleting leaderboard.", 500)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// maxImageMembers is the most members a leaderboard image can show.
+const maxImageMembers = 50
+
+// leaderboardImageHandler returns a PNG of the top members of a leaderboard, for posting to chat.
+// The number of members, image width and theme are set with the top, width and theme query
+// parameters.
+func leaderboardImageHandler(w http.ResponseWriter, r *http.Request) {
+	lb, err := store.Get(mux.Vars(r)["name"])
+	if err != nil {
+		writeErrorToBrowser(w, fmt.Sprintf("No leaderboard named %s.", mux.Vars(r)["name"]), 404)
+		return
+	}
+
+	query := r.URL.Query()
+	opts := snapshot.Options{Title: lb.Name, Width: snapshot.DefaultWidth, Updated: time.Now()}
+
+	top := 10
+	if value := query.Get("top"); value != "" {
+		if top, err = strconv.Atoi(value); err != nil || top < 1 || top > maxImageMembers {
+			writeErrorToBrowser(w, fmt.Sprintf("Expected top to be between 1 and %d.", maxImageMembers), 400)
+			return
+		}
+	}
+
+	if value := query.Get("width"); value != "" {
+		if opts.Width, err = strconv.Atoi(value); err != nil || opts.Width < snapshot.MinWidth || opts.Width > snapshot.MaxWidth {
+			writeErrorToBrowser(
+				w,
+				fmt.Sprintf("Expected width to be between %d and %d.", snapshot.MinWidth, snapshot.MaxWidth),
+				400,
+			)
+			return
+		}
+	}
+
+	themeName := query.Get("theme")
+	if themeName == "" {
+		themeName = "light"
+	}
+	theme, ok := snapshot.Themes[themeName]
+	if !ok {
+		writeErrorToBrowser(w, "Expected theme to be one of: light, dark.", 400)
+		return
+	}
+	opts.Theme = theme
+
+	ranked := rankLeaderboard(r.Context(), lb)
+	if len(ranked) > top {
+		ranked = ranked[:top]
+	}
+
+	rows := make([]snapshot.Row, len(ranked))
+	for i, member := range ranked {
+		rows[i] = snapshot.Row{
+			Place:     i + 1,
+			Name:      member.Name,
+			RankTitle: member.RankTitle,
+			Badges:    member.Badges,
+			Points:    member.Points,
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := snapshot.Render(&buf, rows, opts); err != nil {
+		slog.ErrorContext(r.Context(), "rendering leaderboard image", "leaderboard", lb.Name, "error", err)
+		writeErrorToBrowser(w, "Problem rendering leaderboard image.", 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.Write(buf.Bytes())
+}
+
+// rankedMember is a leaderboard member with their current totals from Trailhead. Unavailable is
+// set when their rank couldn't be looked up, so their totals are zero.
+type rankedMember struct {
+	Handle       string `json:"handle"`
+	Name         string `json:"name"`
+	PhotoURL     string `json:"photoUrl"`
+	Company      string `json:"company"`
+	RankTitle    string `json:"rankTitle"`
+	RankImageURL string `json:"rankImageUrl"`
+	Points       int    `json:"points"`
+	Badges       int    `json:"badges"`
+	Trails       int    `json:"trails"`
+	Unavailable  bool   `json:"unavailable"`
+}
+
+// rankLeaderboard looks up every member of a leaderboard and sorts them by points, then badges.
+// Members that can't be looked up are ranked with no points rather than left out.
+func rankLeaderboard(ctx context.Context, lb leaderboard.Leaderboard) []rankedMember {
+	ranked := make([]rankedMember, len(lb.Members))
+	forEachHandle(memberHandles(lb), func(i int, handle string) {
+		ranked[i] = lookupRankedMember(ctx, handle)
+	})
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Points != ranked[j].Points {
+			return ranked[i].Points > ranked[j].Points
+		}
+		if ranked[i].Badges != ranked[j].Badges {
+			return ranked[i].Badges > ranked[j].Badges
+		}
+
+		return strings.ToLower(ranked[i].Name) < strings.ToLower(ranked[j].Name)
+	})
+
+	return ranked
+}
+
+// forEachHandle calls fn for every handle, a few at a time, and waits for them all to finish.
+func forEachHandle(handles []string, fn func(i int, handle string)) {
+	limit := make(chan struct{}, 4)
+
+	var wg sync.WaitGroup
+	for i, handle := range handles {
+		wg.Add(1)
+		go func(i int, handle string) {
+			defer wg.Done()
+			limit <- struct{}{}
+			defer func() { <-limit }()
+
+			fn(i, handle)
+		}(i, handle)
+	}
+	wg.Wait()
+}
+
+// memberHandles returns the handles of a leaderboard's members.
+func memberHandles(lb leaderboard.Leaderboard) []string {
+	handles := make([]string, len(lb.Members))
+	for i, member := range lb.Members {
+		handles[i] = member.Handle
+	}
+
+	return handles
+}
+
+// lookupRankedMember gets the name, photo, rank and totals of a leaderboard member.
+func lookupRankedMember(ctx context.Context, handle string) rankedMember {
+	member := rankedMember{Handle: handle, Name: handle}
+
+	if profile, err := getTrailheadProfile(ctx, handle); err == nil {
+		if name := strings.TrimSpace(profile.FirstName + " " + profile.LastName); name != "" {
+			member.Name = name
+		}
+		member.PhotoURL = profile.PhotoURL
+		member.Company = profile.Company.Name
+	} else {
+		slog.WarnContext(ctx, "retrieving profile", "handle", handle, "error", err)
+	}
+
+	if stats, err := getTrailheadRankStats(ctx, handle); err == nil {
+		member.RankTitle = stats.Rank.Title
+		member.RankImageURL = stats.Rank.ImageURL
+		member.Points = stats.EarnedPointsSum
+		member.Badges = stats.EarnedBadgesCount
+		member.Trails = stats.CompletedTrailCount
+	} else {
+		member.Unavailable = true
+		slog.WarnContext(ctx, "retrieving rank", "handle", handle, "error", err)
+	}
+
+	return member
+}
+
+// aliasesHandler returns the table of renamed handles, old handle to new.
+func aliasesHandler(w http.ResponseWriter, r *http.Request) {
+	encodeAndWriteToBrowser(w, store.Aliases())
+}
+
+// checkHandlesHandler checks every leaderboard member for a renamed handle right away rather than
+// waiting for the next scheduled check, and returns the changes found.
+func checkHandlesHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdminKey(w, r) {
+		return
+	}
+
+	changes, err := checkHandles(r.Context())
+	if err != nil {
+		slog.WarnContext(r.Context(), "checking handles", "error", err)
+	}
+
+	if changes == nil {
+		changes = []leaderboard.HandleChange{}
+	}
+
+	encodeAndWriteToBrowser(w, changes)
+}
+
+// aliasRedirectHandler permanently redirects /trailblazer/{id} requests for a renamed handle to the
+// same path under the Trailblazer's new handle.
+func aliasRedirectHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		oldPrefix := "/trailblazer/" + mux.Vars(r)["id"]
+
+		if strings.HasPrefix(r.URL.Path, oldPrefix) {
+			if newHandle, renamed := store.Alias(mux.Vars(r)["id"]); renamed {
+				target := url.URL{
+					Path:     "/trailblazer/" + newHandle + strings.TrimPrefix(r.URL.Path, oldPrefix),
+					RawQuery: r.URL.RawQuery,
+				}
+				http.Redirect(w, r, target.String(), http.StatusMovedPermanently)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// watchHandles checks leaderboard members for renamed handles every interval until ctx is done.
+func watchHandles(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		changes, err := checkHandles(ctx)
+		if err != nil {
+			slog.WarnContext(ctx, "checking handles", "error", err)
+		}
+
+		for _, change := range changes {
+			slog.Info("trailblazer changed handle", "profile_id", change.ProfileID, "old_handle", change.OldHandle, "new_handle", change.NewHandle)
+		}
+	}
+}
+
+// checkHandles checks every leaderboard member for a renamed handle, and forgets the cached
+// identity of each renamed handle so it stops resolving to the Trailblazer's user ID. A reclaimed
+// handle has already been cached for its new owner by lookupProfileID.
+func checkHandles(ctx context.Context) ([]leaderboard.HandleChange, error) {
+	changes, err := store.CheckHandles(ctx, lookupProfileID, lookupCurrentHandle)
+
+	for _, change := range changes {
+		if change.NewHandle != "" && !change.Reclaimed {
+			identities.Forget(change.OldHandle)
+		}
+	}
+
+	return changes, err
+}
+
+// lookupProfileID returns the stable profile ID of the Trailblazer using a handle.
+func lookupProfileID(ctx context.Context, handle string) (string, error) {
+	profile, err := getTrailheadProfile(ctx, handle)
+	if errors.Is(err, errProfileNotFound) {
+		return "", leaderboard.ErrHandleNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	identities.Remember(trailhead.Identity{Handle: handle, UserID: profile.ID})
+
+	return profile.ID, nil
+}
+
+// lookupCurrentHandle returns the handle a Trailblazer uses now, skipping the identity cache since
+// it may still hold the old handle.
+func lookupCurrentHandle(ctx context.Context, profileID string) (string, error) {
+	identity, err := lookupIdentity(ctx, profileID)
+	if err != nil {
+		return "", err
+	}
+
+	identities.Remember(identity)
+
+	return identity.Handle, nil
+}
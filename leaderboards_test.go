@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/meruff/go-trailhead-leaderboard-api/leaderboard"
+	"github.com/meruff/go-trailhead-leaderboard-api/trailhead"
+	"github.com/meruff/go-trailhead-leaderboard-api/trailheadtest"
+)
+
+// useFakeUpstream points the app's Trailhead client, and a fresh identity cache, at a fake Trailhead
+// until the test ends.
+func useFakeUpstream(t *testing.T) *trailheadtest.Server {
+	t.Helper()
+
+	fake := trailheadtest.NewServer(trailheadtest.DefaultTrailblazers())
+	t.Cleanup(fake.Close)
+
+	previous := upstream
+	upstream = fake.Client()
+	upstream.Limiter = nil
+	t.Cleanup(func() { upstream = previous })
+
+	previousIdentities := identities
+	identities = trailhead.NewResolver(lookupIdentity, time.Hour)
+	t.Cleanup(func() { identities = previousIdentities })
+
+	return fake
+}
+
+// useStore gives the app an empty leaderboard store until the test ends.
+func useStore(t *testing.T) *leaderboard.Store {
+	t.Helper()
+
+	previous := store
+	var err error
+	if store, err = leaderboard.Open(filepath.Join(t.TempDir(), "leaderboards.json")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store = previous })
+
+	return store
+}
+
+func TestLeaderboardStandingsHandler(t *testing.T) {
+	useFakeUpstream(t)
+	useStore(t)
+	defer func(previous *trailhead.Resolver) { identities = previous }(identities)
+	identities = trailhead.NewResolver(lookupIdentity, time.Hour)
+
+	err := store.Put(leaderboard.Leaderboard{Name: "team", Members: []leaderboard.Member{
+		{Handle: "hidden"}, {Handle: "astro"}, {Handle: "matruff"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := mux.NewRouter()
+	r.HandleFunc("/leaderboards/{name}/standings", leaderboardStandingsHandler)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/leaderboards/team/standings", nil))
+	if w.Code != 200 {
+		t.Fatalf("GET /leaderboards/team/standings = %d: %s", w.Code, w.Body)
+	}
+
+	var standings []rankedMember
+	if err := json.Unmarshal(w.Body.Bytes(), &standings); err != nil {
+		t.Fatalf("body isn't valid JSON: %v\n%s", err, w.Body)
+	}
+	if len(standings) != 3 {
+		t.Fatalf("got %d members, want 3: %+v", len(standings), standings)
+	}
+
+	for i, member := range standings[:2] {
+		if member.Unavailable || member.Points == 0 || member.RankTitle == "" {
+			t.Errorf("standings[%d] = %+v, want a ranked member", i, member)
+		}
+	}
+	if standings[0].Points < standings[1].Points {
+		t.Errorf("standings aren't ranked by points: %d then %d", standings[0].Points, standings[1].Points)
+	}
+	if hidden := standings[2]; hidden.Handle != "hidden" || !hidden.Unavailable || hidden.Points != 0 {
+		t.Errorf("standings[2] = %+v, want hidden last and unavailable", hidden)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/leaderboards/nope/standings", nil))
+	if w.Code != 404 {
+		t.Errorf("GET /leaderboards/nope/standings = %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/meruff/go-trailhead-leaderboard-api/config"
+	"github.com/meruff/go-trailhead-leaderboard-api/requestid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// loggerFromConfig builds the logger from the log level (debug, info, warn or error) and format
+// (text or json).
+func loggerFromConfig(conf config.Log) *slog.Logger {
+	var level slog.Level
+	if conf.Level != "" {
+		// Checked by config to be a level slog knows, so this can't fail.
+		level.UnmarshalText([]byte(conf.Level))
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler = slog.NewTextHandler(os.Stderr, opts)
+	if strings.EqualFold(conf.Format, "json") {
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	}
+
+	return slog.New(contextHandler{handler})
+}
+
+// contextHandler adds the ID of the request being handled, and the trace it's part of, to every
+// record logged with its context.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := requestid.FromContext(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		record.AddAttrs(slog.String("trace_id", span.TraceID().String()), slog.String("span_id", span.SpanID().String()))
+	}
+
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// upstreamTally adds up the Trailhead callouts made while handling a request, in total and by
+// operation, in the order each operation was first called.
+type upstreamTally struct {
+	mu         sync.Mutex
+	calls      int
+	duration   time.Duration
+	operations []operationTally
+}
+
+// operationTally adds up the callouts for one operation, i.e. GetTrailheadRank or ProfilePage.
+type operationTally struct {
+	name     string
+	calls    int
+	duration time.Duration
+}
+
+// add counts a callout for an operation.
+func (t *upstreamTally) add(operation string, duration time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.calls++
+	t.duration += duration
+
+	for i := range t.operations {
+		if t.operations[i].name == operation {
+			t.operations[i].calls++
+			t.operations[i].duration += duration
+			return
+		}
+	}
+
+	t.operations = append(t.operations, operationTally{name: operation, calls: 1, duration: duration})
+}
+
+// attrs returns the totals, then a group holding each operation's calls and duration.
+func (t *upstreamTally) attrs() []slog.Attr {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	operations := make([]any, len(t.operations))
+	for i, op := range t.operations {
+		operations[i] = slog.Group(op.name, slog.Int("calls", op.calls), slog.Duration("duration", op.duration))
+	}
+
+	return []slog.Attr{
+		slog.Int("upstream_calls", t.calls),
+		slog.Duration("upstream_duration", t.duration),
+		slog.Group("upstream_operations", operations...),
+	}
+}
+
+type upstreamTallyKey struct{}
+
+// loggingHandler gives every request an ID, reusing the client's X-Request-ID if it sent a usable
+// one, and returns it in the response. Once the request is handled it logs the route, status, size
+// and time taken, along with how many callouts to Trailhead it needed and how long they took, in
+// total and for each operation.
+func loggingHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		id := r.Header.Get(requestid.Header)
+		if !requestid.Valid(id) {
+			id = requestid.New()
+		}
+		w.Header().Set(requestid.Header, id)
+
+		tally := &upstreamTally{}
+		ctx := requestid.NewContext(r.Context(), id)
+		ctx = context.WithValue(ctx, upstreamTallyKey{}, tally)
+		r = r.WithContext(ctx)
+
+		recorder := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+
+		level := slog.LevelInfo
+		if recorder.Status() >= 500 {
+			level = slog.LevelWarn
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("route", routeTemplate(r)),
+			slog.Int("status", recorder.Status()),
+			slog.Int("bytes", recorder.bytes),
+			slog.Duration("duration", time.Since(start)),
+		}
+		slog.LogAttrs(ctx, level, "request", append(attrs, tally.attrs()...)...)
+	})
+}
+
+// logObserver logs every Trailhead callout at debug level, or warn level if it failed, and adds it
+// to the tally of the request it was made for.
+type logObserver struct{}
+
+func (logObserver) ObserveCallout(ctx context.Context, upstream, operation string, duration time.Duration, err error) {
+	if tally, ok := ctx.Value(upstreamTallyKey{}).(*upstreamTally); ok {
+		tally.add(operation, duration)
+	}
+
+	attrs := []slog.Attr{
+		slog.String("upstream", upstream),
+		slog.String("operation", operation),
+		slog.Duration("duration", duration),
+	}
+	if err != nil {
+		slog.LogAttrs(ctx, slog.LevelWarn, "trailhead callout failed", append(attrs, slog.Any("error", err))...)
+		return
+	}
+
+	slog.LogAttrs(ctx, slog.LevelDebug, "trailhead callout", attrs...)
+}
+
+func (logObserver) ObserveCacheLookup(ctx context.Context, cache string, hit bool) {
+	slog.DebugContext(ctx, "cache lookup", "cache", cache, "hit", hit)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLoggingHandlerTalliesUpstreamOperations(t *testing.T) {
+	var logs bytes.Buffer
+	defer func(previous *slog.Logger) { slog.SetDefault(previous) }(slog.Default())
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+
+	handler := loggingHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var observer logObserver
+		observer.ObserveCallout(r.Context(), "graphql", "GetTrailheadRank", 100*time.Millisecond, nil)
+		observer.ObserveCallout(r.Context(), "profile", "ProfilePage", 50*time.Millisecond, nil)
+		observer.ObserveCallout(r.Context(), "graphql", "GetTrailheadRank", 200*time.Millisecond, nil)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/leaderboards/team", nil))
+
+	var line struct {
+		Msg                string                    `json:"msg"`
+		UpstreamCalls      int                       `json:"upstream_calls"`
+		UpstreamDuration   time.Duration             `json:"upstream_duration"`
+		UpstreamOperations map[string]map[string]int `json:"upstream_operations"`
+	}
+	if err := json.Unmarshal(logs.Bytes(), &line); err != nil {
+		t.Fatalf("parsing log line: %v\n%s", err, logs.Bytes())
+	}
+
+	if line.Msg != "request" || line.UpstreamCalls != 3 || line.UpstreamDuration != 350*time.Millisecond {
+		t.Errorf("logged %q with %d calls taking %v, want request with 3 calls taking 350ms", line.Msg, line.UpstreamCalls, line.UpstreamDuration)
+	}
+
+	want := map[string]map[string]int{
+		"GetTrailheadRank": {"calls": 2, "duration": int(300 * time.Millisecond)},
+		"ProfilePage":      {"calls": 1, "duration": int(50 * time.Millisecond)},
+	}
+	for operation, fields := range want {
+		for field, value := range fields {
+			if got := line.UpstreamOperations[operation][field]; got != value {
+				t.Errorf("upstream_operations.%s.%s = %d, want %d", operation, field, got, value)
+			}
+		}
+	}
+}
+
+func TestLoggingHandlerWithoutUpstreamCalls(t *testing.T) {
+	var logs bytes.Buffer
+	defer func(previous *slog.Logger) { slog.SetDefault(previous) }(slog.Default())
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+
+	loggingHandler(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/nowhere", nil))
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(logs.Bytes(), &line); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := line["upstream_operations"]; ok {
+		t.Errorf("logged upstream_operations for a request without callouts: %s", logs.Bytes())
+	}
+	if line["upstream_calls"] != float64(0) {
+		t.Errorf("upstream_calls = %v, want 0", line["upstream_calls"])
+	}
+}
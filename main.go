@@ -1,19 +1,32 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"io"
 	"log"
+	"log/slog"
+	"math"
+	"net"
 	"net/http"
-	"os"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/meruff/go-trailhead-leaderboard-api/apikey"
+	"github.com/meruff/go-trailhead-leaderboard-api/config"
+	"github.com/meruff/go-trailhead-leaderboard-api/leaderboard"
 	"github.com/meruff/go-trailhead-leaderboard-api/trailhead"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+var (
+	errProfileNotFound = errors.New("trailblazer profile not found")
+	errProfilePrivate  = errors.New("trailblazer profile is private")
 )
 
 const (
@@ -21,120 +34,362 @@ const (
 	trailblazerUrl  = "https://www.salesforce.com/trailblazer/"
 )
 
+// upstream makes all callouts to Trailhead.
+var upstream = trailhead.NewClient(trailheadApiUrl, trailblazerUrl)
+
+// identities caches the mapping between Trailblazer handles and user IDs.
+var identities *trailhead.Resolver
+
+// store holds saved leaderboards and the aliases of renamed handles.
+var store *leaderboard.Store
+
+// apiKeys holds the API keys clients must send, if any are configured.
+var apiKeys *apikey.Registry
+
 func main() {
+	fakeUpstream := flag.Bool("fake-upstream", false, "serve fixture data from a fake Trailhead instead of calling Trailhead")
+	fakeUpstreamFixtures := flag.String("fake-upstream-fixtures", "", "JSON file of Trailblazers for -fake-upstream to serve, the built in ones if empty")
+	flag.Parse()
+
+	conf, err := configFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	slog.SetDefault(loggerFromConfig(conf.Log))
+
+	tracerProvider, err := tracerProviderFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("Configuring tracing: %v", err)
+	}
+	if tracerProvider != nil {
+		otel.SetTracerProvider(tracerProvider)
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+	}
+
+	storePath := conf.Storage.Leaderboards
+	if storePath == "" {
+		storePath = "leaderboards.json"
+	}
+
+	store, err = leaderboard.Open(storePath)
+	if err != nil {
+		log.Fatalf("Opening leaderboard store %s: %v", storePath, err)
+	}
+
+	configureUpstream(upstream, conf.Trailhead)
+
+	if *fakeUpstream {
+		fake, err := startFakeUpstream(*fakeUpstreamFixtures)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer fake.Close()
+	}
+
+	configureBadges(conf.Badges)
+	upstream.Observer = upstreamObserver
+	identities = trailhead.NewResolver(lookupIdentity, conf.Trailhead.Identities.TTL.Or(time.Hour))
+	identities.Observer = upstreamObserver
+
+	upstreamProbes = upstreamProberFromConfig(upstream, conf.Trailhead.Probe)
+
+	apiKeys, err = apiKeysFromConfig(conf.APIKeys)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cors = corsFromConfig(conf.CORS)
+
+	pollers := newBackground()
+	pollers.Go(func(ctx context.Context) { watchHandles(ctx, conf.Poll.Handles.Or(24*time.Hour)) })
+
+	memberStates, err = memberStatesFromConfig(conf.Storage.Events)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	webhooks, err = webhooksFromConfig(conf.Storage.Webhooks, conf.Webhooks)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	pollers.Go(func(ctx context.Context) { watchMembers(ctx, conf.Poll.Events.Or(time.Hour)) })
+
+	digests, err = digestsFromConfig(conf.Digest, conf.SMTP)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	digestHistory, err = digestHistoryFromConfig(conf.Storage.Digest)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(digests.Recipients) > 0 {
+		pollers.Go(scheduleDigests)
+	}
+
 	r := mux.NewRouter()
 	r.HandleFunc("/trailblazer/{id}", profileHandler)
 	r.HandleFunc("/trailblazer/{id}/profile", profileHandler)
 	r.HandleFunc("/trailblazer/{id}/rank", rankHandler)
 	r.HandleFunc("/trailblazer/{id}/skills", skillsHandler)
 	r.HandleFunc("/trailblazer/{id}/certifications", certificationsHandler)
+	r.HandleFunc("/trailblazer/{id}/badge.svg", shieldHandler)
+	r.HandleFunc("/trailblazer/{id}/card.svg", cardHandler)
+	r.HandleFunc("/trailblazer/{id}/badges.atom", badgesFeedHandler)
 	r.HandleFunc("/trailblazer/{id}/badges", badgesHandler)
 	r.HandleFunc("/trailblazer/{id}/badges/{filter}", badgesHandler)
 	r.HandleFunc("/trailblazer/{id}/badges/{filter}/{count}", badgesHandler)
 	r.HandleFunc("/trailblazer/{id}/badges/{filter}/{count}/{after}", badgesHandler)
+	r.HandleFunc("/leaderboards", leaderboardsHandler).Methods("GET")
+	r.HandleFunc("/leaderboards/{name}", leaderboardHandler).Methods("GET")
+	r.HandleFunc("/leaderboards/{name}", putLeaderboardHandler).Methods("PUT")
+	r.HandleFunc("/leaderboards/{name}", deleteLeaderboardHandler).Methods("DELETE")
+	r.HandleFunc("/leaderboards/{name}/standings", leaderboardStandingsHandler).Methods("GET")
+	r.HandleFunc("/leaderboards/{name}/view", leaderboardViewHandler).Methods("GET")
+	r.HandleFunc("/leaderboards/{name}/image.png", leaderboardImageHandler).Methods("GET")
+	r.HandleFunc("/leaderboards/{name}/feed.atom", leaderboardFeedHandler).Methods("GET")
+	r.HandleFunc("/aliases", aliasesHandler).Methods("GET")
+	r.HandleFunc("/aliases/check", checkHandlesHandler).Methods("POST")
+	r.HandleFunc("/events/poll", pollMembersHandler).Methods("POST")
+	r.HandleFunc("/webhooks", webhooksHandler).Methods("GET")
+	r.HandleFunc("/webhooks", postWebhookHandler).Methods("POST")
+	r.HandleFunc("/webhooks/{id}", webhookHandler).Methods("GET")
+	r.HandleFunc("/webhooks/{id}", deleteWebhookHandler).Methods("DELETE")
+	r.HandleFunc("/webhooks/{id}/deliveries", webhookDeliveriesHandler).Methods("GET")
+	r.HandleFunc("/webhooks/{id}/ping", pingWebhookHandler).Methods("POST")
+	r.HandleFunc("/digest", digestHandler).Methods("GET")
+	r.HandleFunc("/digest/send", sendDigestHandler).Methods("POST")
+	r.HandleFunc("/metrics", metricsHandler).Methods("GET")
+	r.HandleFunc("/healthz", healthzHandler).Methods("GET")
+	r.HandleFunc("/readyz", readyzHandler).Methods("GET")
+	r.HandleFunc("/status/upstream", upstreamStatusHandler).Methods("GET")
+	r.HandleFunc("/status/drift", schemaDriftHandler).Methods("GET")
+	r.HandleFunc("/status/drift", resetSchemaDriftHandler).Methods("DELETE")
+	r.HandleFunc("/status/breakers", breakersHandler).Methods("GET")
+	r.HandleFunc("/admin/usage", usageHandler).Methods("GET")
+	r.HandleFunc("/", indexHandler).Methods("GET")
 	r.PathPrefix("/").HandlerFunc(catchAllHandler)
-	r.Use(loggingHandler)
-	http.Handle("/", r)
+	r.Use(tracingHandler, loggingHandler, instrumentHandler, apiKeyHandler, aliasRedirectHandler)
 
-	port := os.Getenv("PORT")
+	srv, err := serverFromConfig(corsHandler(r), conf.Server)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	if port == "" {
-		http.ListenAndServe(":8000", nil)
-	} else {
-		http.ListenAndServe(":"+os.Getenv("PORT"), nil)
+	certificates, certificateCheckInterval, err := certificatesFromConfig(conf.Server.TLS)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if certificates != nil {
+		srv.TLSConfig = certificates.TLSConfig()
+		pollers.Go(func(ctx context.Context) { watchCertificates(ctx, certificates, certificateCheckInterval) })
+	}
+
+	shutdownTimeout := conf.Server.ShutdownTimeout.Or(25 * time.Second)
+
+	listener, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		log.Fatalf("Listening on %s: %v", srv.Addr, err)
+	}
+
+	ready.Store(true)
+
+	if err := serve(srv, listener, shutdownTimeout, pollers, tracerProvider); err != nil {
+		log.Fatal(err)
 	}
 }
 
-// profileHandler gets profile information of the Trailblazer i.e. Name, Company, Title etc. Uses a
-// Trailblazer handle only, not an ID.
+// profileHandler gets profile information of the Trailblazer i.e. Name, Company, Title etc. Accepts
+// either a Trailblazer handle or user ID.
 func profileHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	userAlias := vars["id"]
+	userAlias, ok := resolveHandle(r.Context(), w, vars["id"])
+	if !ok {
+		return
+	}
 
-	if strings.HasPrefix(userAlias, "005") {
-		writeErrorToBrowser(w, "/profile requires a trailblazer handle, not an ID as a parameter.", 503)
+	trailheadProfileData, err := getTrailheadProfile(r.Context(), userAlias)
+	if err != nil {
+		slog.WarnContext(r.Context(), "retrieving profile", "handle", userAlias, "error", err)
+
+		switch {
+		case errors.Is(err, errProfileNotFound):
+			writeErrorToBrowser(
+				w,
+				fmt.Sprintf("Cannot find profile data for %s. Does this trailblazer exist?", userAlias),
+				503,
+			)
+		case errors.Is(err, errProfilePrivate):
+			writeErrorToBrowser(w, fmt.Sprintf("The profile for %s is private.", userAlias), 503)
+		case errors.Is(err, trailhead.ErrProfileLayoutChanged):
+			writeErrorToBrowser(w, "Trailhead profile page layout changed, unable to read profile data.", 503)
+		default:
+			writeCalloutErrorToBrowser(w, err, "Problem retrieving profile data.")
+		}
 		return
 	}
 
-	res, err := http.Get(trailblazerUrl + userAlias)
-	if res != nil {
-		defer res.Body.Close()
+	identities.Remember(trailhead.Identity{Handle: userAlias, UserID: trailheadProfileData.ID})
+
+	profileDataForUi := trailhead.ProfileReturn{}
+	profileDataForUi.ProfilePhotoUrl = trailheadProfileData.PhotoURL
+	profileDataForUi.ProfileUser.TBID_Role = trailheadProfileData.Role
+	profileDataForUi.ProfileUser.CompanyName = trailheadProfileData.Company.Name
+	profileDataForUi.ProfileUser.TrailblazerId = userAlias
+	profileDataForUi.ProfileUser.Title = trailheadProfileData.Title
+	profileDataForUi.ProfileUser.FirstName = trailheadProfileData.FirstName
+	profileDataForUi.ProfileUser.LastName = trailheadProfileData.LastName
+	profileDataForUi.ProfileUser.Id = trailheadProfileData.ID
+	encodeAndWriteToBrowser(w, profileDataForUi)
+}
+
+// resolveHandle resolves a Trailblazer handle or user ID from the URL to the handle used to query
+// Trailhead. Writes an error to the browser and returns false if a user ID can't be resolved.
+func resolveHandle(ctx context.Context, w http.ResponseWriter, handleOrID string) (string, bool) {
+	identity, err := identities.Resolve(ctx, handleOrID)
+	if err != nil {
+		slog.WarnContext(ctx, "resolving trailblazer", "id", handleOrID, "error", err)
+		writeCalloutErrorToBrowser(
+			w,
+			err,
+			fmt.Sprintf("Cannot find a trailblazer with the user ID %s. Is their profile public?", handleOrID),
+		)
+		return "", false
 	}
 
+	return identity.Handle, true
+}
+
+// lookupIdentity finds the handle and user ID of a Trailblazer from their profile.
+func lookupIdentity(ctx context.Context, handleOrID string) (trailhead.Identity, error) {
+	profile, err := queryTrailheadProfile(ctx, handleOrID)
 	if err != nil {
-		log.Println(err)
-		writeErrorToBrowser(w, "Problem retrieving profile data.", 503)
-		return
+		return trailhead.Identity{}, err
+	}
+
+	if profile.Handle() == "" {
+		return trailhead.Identity{}, errors.New("profile has no handle")
+	}
+
+	return trailhead.Identity{Handle: profile.Handle(), UserID: profile.ID}, nil
+}
+
+// getTrailheadProfile returns profile data for the Trailblazer from the GraphQL API, falling back to
+// scraping their profile page if the query fails.
+func getTrailheadProfile(ctx context.Context, userAlias string) (trailhead.Profile, error) {
+	profile, err := queryTrailheadProfile(ctx, userAlias)
+	if err == nil || errors.Is(err, errProfilePrivate) {
+		return profile, err
+	}
+
+	slog.InfoContext(ctx, "graphql profile lookup failed, falling back to profile page", "handle", userAlias, "error", err)
+
+	return scrapeTrailheadProfile(ctx, userAlias)
+}
+
+// queryTrailheadProfile gets profile data from the Trailhead GraphQL API by handle or user ID.
+func queryTrailheadProfile(ctx context.Context, userAlias string) (trailhead.Profile, error) {
+	var variables string
+	if trailhead.IsUserID(userAlias) {
+		variables = trailhead.GetUserIDPayload(userAlias)
 	}
 
-	body, err := io.ReadAll(res.Body)
+	responseBody, err := doTrailheadCallout(
+		ctx,
+		"GetTrailheadProfile",
+		trailhead.GetGraphqlPayload("GetTrailheadProfile", userAlias, variables, trailhead.GetProfileQuery()),
+	)
 	if err != nil {
-		log.Println(err)
-		writeErrorToBrowser(w, "Problem reading profile body.", 503)
-		return
+		return trailhead.Profile{}, err
 	}
 
-	if !strings.Contains(string(body), "var profile = ") {
-		writeErrorToBrowser(
-			w,
-			fmt.Sprintf("Cannot find profile data for %s. Does this trailblazer exist?", vars["id"]),
-			503,
-		)
-		return
+	var trailheadProfileData trailhead.PublicProfile
+	if err := json.Unmarshal([]byte(responseBody), &trailheadProfileData); err != nil {
+		return trailhead.Profile{}, err
 	}
 
-	re := regexp.MustCompile(`var profile = (.*);`)
-	match := re.FindStringSubmatch(string(body))
+	switch trailheadProfileData.Data.Profile.Typename {
+	case "PublicProfile":
+		return trailheadProfileData.Data.Profile.Profile, nil
+	case "PrivateProfile":
+		return trailhead.Profile{}, errProfilePrivate
+	default:
+		return trailhead.Profile{}, errors.New("no profile data returned from Trailhead")
+	}
+}
 
-	if len(match) > 1 {
-		var trailheadProfileData trailhead.Profile
-		json.Unmarshal([]byte(match[1]), &trailheadProfileData)
+// scrapeTrailheadProfile gets profile data by parsing the Trailblazer's public profile page.
+func scrapeTrailheadProfile(ctx context.Context, userAlias string) (trailhead.Profile, error) {
+	body, err := upstream.ProfilePage(ctx, userAlias)
+	if errors.Is(err, trailhead.ErrProfilePageNotFound) {
+		return trailhead.Profile{}, errProfileNotFound
+	}
 
-		profileDataForUi := trailhead.ProfileReturn{}
-		profileDataForUi.ProfilePhotoUrl = trailheadProfileData.PhotoURL
-		profileDataForUi.ProfileUser.TBID_Role = trailheadProfileData.Role
-		profileDataForUi.ProfileUser.CompanyName = trailheadProfileData.Company.Name
-		profileDataForUi.ProfileUser.TrailblazerId = vars["id"]
-		profileDataForUi.ProfileUser.Title = trailheadProfileData.Title
-		profileDataForUi.ProfileUser.FirstName = trailheadProfileData.FirstName
-		profileDataForUi.ProfileUser.LastName = trailheadProfileData.LastName
-		profileDataForUi.ProfileUser.Id = trailheadProfileData.ID
-		encodeAndWriteToBrowser(w, profileDataForUi)
-	} else {
-		writeErrorToBrowser(w, "No profile data found.", 503)
+	if err != nil {
+		return trailhead.Profile{}, err
 	}
+
+	return trailhead.ParseProfilePage(body)
 }
 
 // rankHandler returns information about a Trailblazer's rank and overall points
 func rankHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
+	userAlias, ok := resolveHandle(r.Context(), w, vars["id"])
+	if !ok {
+		return
+	}
+
+	trailheadRankData, err := getTrailheadRank(r.Context(), userAlias)
+	if err != nil {
+		writeCalloutErrorToBrowser(w, err, "No rank data returned from Trailhead.")
+		return
+	}
 
+	encodeAndWriteToBrowser(w, trailheadRankData.Data)
+}
+
+// getTrailheadRank gets a Trailblazer's rank and overall points from Trailhead.
+func getTrailheadRank(ctx context.Context, userAlias string) (trailhead.Rank, error) {
 	responseBody, err := doTrailheadCallout(
-		trailhead.GetGraphqlPayload("GetTrailheadRank", vars["id"], "", trailhead.GetRankQuery()),
+		ctx,
+		"GetTrailheadRank",
+		trailhead.GetGraphqlPayload("GetTrailheadRank", userAlias, "", trailhead.GetRankQuery()),
 	)
 	if err != nil {
-		writeErrorToBrowser(w, "No rank data returned from Trailhead.", 503)
+		return trailhead.Rank{}, err
 	}
 
 	var trailheadRankData trailhead.Rank
 	json.Unmarshal([]byte(responseBody), &trailheadRankData)
-	encodeAndWriteToBrowser(w, trailheadRankData.Data)
+
+	return trailheadRankData, nil
 }
 
 // skillsHandler returns information about a Trailblazer's skills
 func skillsHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
+	userAlias, ok := resolveHandle(r.Context(), w, vars["id"])
+	if !ok {
+		return
+	}
 
 	responseBody, err := doTrailheadCallout(
+		r.Context(),
+		"GetEarnedSkills",
 		trailhead.GetGraphqlPayload(
 			"GetEarnedSkills",
-			vars["id"],
+			userAlias,
 			"",
 			trailhead.GetSkillsQuery(),
 		),
 	)
 	if err != nil {
-		writeErrorToBrowser(w, "No skills data returned from Trailhead.", 503)
+		writeCalloutErrorToBrowser(w, err, "No skills data returned from Trailhead.")
+		return
 	}
 
 	var trailheadSkillsData trailhead.Skills
@@ -145,21 +400,17 @@ func skillsHandler(w http.ResponseWriter, r *http.Request) {
 // certificationsHandler gets Salesforce certifications the Trailblazer has earned.
 func certificationsHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
+	userAlias, ok := resolveHandle(r.Context(), w, vars["id"])
+	if !ok {
+		return
+	}
 
-	responseBody, err := doTrailheadCallout(
-		trailhead.GetGraphqlPayload(
-			"GetUserCertifications",
-			vars["id"],
-			"",
-			trailhead.GetCertificationsQuery(),
-		),
-	)
+	trailheadCertificationsData, err := getTrailheadCertifications(r.Context(), userAlias)
 	if err != nil {
-		writeErrorToBrowser(w, "No certification data returned from Trailhead.", 503)
+		writeCalloutErrorToBrowser(w, err, "No certification data returned from Trailhead.")
+		return
 	}
 
-	var trailheadCertificationsData trailhead.Certifications
-	json.Unmarshal([]byte(responseBody), &trailheadCertificationsData)
 	certificationReturnData := trailhead.CertificationsReturn{}
 
 	for _, certification := range trailheadCertificationsData.Data.Profile.Credential.Certifications {
@@ -185,11 +436,39 @@ func certificationsHandler(w http.ResponseWriter, r *http.Request) {
 	encodeAndWriteToBrowser(w, certificationReturnData)
 }
 
-// badgeshandler gets badges the Trailblazer has earned. Returns first 8. Optionally can
+// getTrailheadCertifications gets the Salesforce certifications a Trailblazer has earned from
+// Trailhead.
+func getTrailheadCertifications(ctx context.Context, userAlias string) (trailhead.Certifications, error) {
+	responseBody, err := doTrailheadCallout(
+		ctx,
+		"GetUserCertifications",
+		trailhead.GetGraphqlPayload(
+			"GetUserCertifications",
+			userAlias,
+			"",
+			trailhead.GetCertificationsQuery(),
+		),
+	)
+	if err != nil {
+		return trailhead.Certifications{}, err
+	}
+
+	var trailheadCertificationsData trailhead.Certifications
+	json.Unmarshal([]byte(responseBody), &trailheadCertificationsData)
+
+	return trailheadCertificationsData, nil
+}
+
+// badgeshandler gets badges the Trailblazer has earned. Returns first 8 by default. Optionally can
 // provide filter criteria, or additional return count. i.e. "event" type badges, count by 30.
 func badgesHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	filter, after, count := vars["filter"], vars["after"], vars["count"]
+	userAlias, ok := resolveHandle(r.Context(), w, vars["id"])
+	if !ok {
+		return
+	}
+
 	badgeRequestStruct := trailhead.BadgeRequest{}
 
 	// Set filter
@@ -209,12 +488,12 @@ func badgesHandler(w http.ResponseWriter, r *http.Request) {
 	if count != "" {
 		countConvert, err := strconv.Atoi(count)
 		if err != nil {
-			log.Println("Error parsing badge count from params.")
+			slog.InfoContext(r.Context(), "parsing badge count", "count", count, "error", err)
 		}
 
 		badgeRequestStruct.Count = countConvert
 	} else {
-		badgeRequestStruct.Count = 8
+		badgeRequestStruct.Count = defaultBadgeCount
 	}
 
 	// Set after
@@ -222,31 +501,36 @@ func badgesHandler(w http.ResponseWriter, r *http.Request) {
 		badgeRequestStruct.After = after
 	}
 
+	trailheadBadgeData, err := getTrailheadBadges(r.Context(), userAlias, badgeRequestStruct)
+	if err != nil {
+		writeCalloutErrorToBrowser(w, err, "No badge data returned from Trailhead.")
+		return
+	}
+
+	encodeAndWriteToBrowser(w, trailheadBadgeData.Data)
+}
+
+// getTrailheadBadges gets a page of the badges a Trailblazer has earned from Trailhead, most
+// recently earned first.
+func getTrailheadBadges(ctx context.Context, userAlias string, badgeRequest trailhead.BadgeRequest) (trailhead.Badges, error) {
 	responseBody, err := doTrailheadCallout(
+		ctx,
+		"GetTrailheadBadges",
 		trailhead.GetGraphqlPayload(
 			"GetTrailheadBadges",
-			vars["id"],
-			trailhead.GetBadgesFilterPayload(vars["id"], badgeRequestStruct),
+			userAlias,
+			trailhead.GetBadgesFilterPayload(userAlias, badgeRequest),
 			trailhead.GetBadgesQuery(),
 		),
 	)
 	if err != nil {
-		writeErrorToBrowser(w, "No badge data returned from Trailhead.", 503)
+		return trailhead.Badges{}, err
 	}
 
 	var trailheadBadgeData trailhead.Badges
 	json.Unmarshal([]byte(responseBody), &trailheadBadgeData)
-	encodeAndWriteToBrowser(w, trailheadBadgeData.Data)
-}
 
-// loggingHandler logs time spent to access each request/what page was requested.
-func loggingHandler(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		t1 := time.Now()
-		next.ServeHTTP(w, r)
-		t2 := time.Now()
-		log.Printf("[%s] %q %v\n", r.Method, r.URL.String(), t2.Sub(t1))
-	})
+	return trailheadBadgeData, nil
 }
 
 // catchAllHandler is the default message if no Trailblazer Id or handle is provided,
@@ -259,38 +543,39 @@ func catchAllHandler(w http.ResponseWriter, r *http.Request) {
 	)
 }
 
-// doTrailheadCallout makes a callout to the given URL using the given
-func doTrailheadCallout(payload string) (string, error) {
-	client := &http.Client{}
-	req, err := http.NewRequest("POST", trailheadApiUrl, strings.NewReader(payload))
-
-	if err != nil {
-		log.Println(err)
+// doTrailheadCallout posts a GraphQL payload to Trailhead and returns the response body.
+func doTrailheadCallout(ctx context.Context, operationName string, payload string) (string, error) {
+	body, err := upstream.Query(ctx, operationName, payload)
+	if err == nil {
+		checkSchemaDrift(ctx, operationName, body)
 	}
 
-	req.Header.Add("Accept", "*/*")
-	req.Header.Add("Accept-Language", "en-US,en;q=0.5")
-	req.Header.Add("Content-Type", "application/json")
+	return string(body), err
+}
 
-	res, err := client.Do(req)
-	if res != nil {
-		defer res.Body.Close()
-	}
+// breakersHandler reports the state of the circuit breaker for each Trailhead upstream.
+func breakersHandler(w http.ResponseWriter, r *http.Request) {
+	encodeAndWriteToBrowser(w, upstream.Breakers())
+}
 
-	if err != nil {
-		log.Println(err)
+// writeCalloutErrorToBrowser writes a 503 for a failed Trailhead callout. If the upstream's circuit
+// breaker is open, the error says so and a Retry-After header tells the client when to try again.
+func writeCalloutErrorToBrowser(w http.ResponseWriter, err error, errorMsg string) {
+	var openErr *trailhead.CircuitOpenError
+	if errors.As(err, &openErr) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(openErr.RetryAfter.Seconds()))))
+		errorMsg = "Trailhead is currently unavailable, please try again later."
 	}
 
-	body, err := io.ReadAll(res.Body)
-
-	return string(body), err
+	writeErrorToBrowser(w, errorMsg, 503)
 }
 
 // writeErrorToBrowser writes an HTTP error to the broswer in JSON.
 func writeErrorToBrowser(w http.ResponseWriter, errorMsg string, code int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
-	w.Write([]byte(fmt.Sprintf(`{"error":"%s"`, errorMsg)))
+	body, _ := json.Marshal(map[string]string{"error": errorMsg})
+	w.Write(body)
 }
 
 // encodeAndWriteToBrowser encodes a given interface and writes it to the browser as JSON.
@@ -310,7 +595,26 @@ func contains(s []string, str string) bool {
 	return false
 }
 
+// badgeFilters are the badge types badges can be filtered by, and defaultBadgeCount is how many
+// badges are returned when no count is asked for.
+var (
+	badgeFilters      = []string{"module", "project", "superbadge", "event", "standalone"}
+	defaultBadgeCount = 8
+)
+
+// configureBadges applies the badge filters and default count.
+func configureBadges(conf config.Badges) {
+	if len(conf.Filters) > 0 {
+		badgeFilters = make([]string, len(conf.Filters))
+		for i, filter := range conf.Filters {
+			badgeFilters[i] = strings.ToLower(filter)
+		}
+	}
+
+	defaultBadgeCount = config.Or(conf.DefaultCount, defaultBadgeCount)
+}
+
 // getValidBadgeFilters returns a slice containing valid filters for Trailblazer badges.
 func getValidBadgeFilters() []string {
-	return []string{"module", "project", "superbadge", "event", "standalone"}
+	return badgeFilters
 }
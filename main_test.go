@@ -0,0 +1,26 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteErrorToBrowser(t *testing.T) {
+	msg := `Expected a JSON body like {"members": ["handle"]}.`
+
+	w := httptest.NewRecorder()
+	writeErrorToBrowser(w, msg, 400)
+
+	if w.Code != 400 {
+		t.Errorf("status = %d, want 400", w.Code)
+	}
+
+	var body map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("body isn't valid JSON: %v\n%s", err, w.Body)
+	}
+	if body["error"] != msg {
+		t.Errorf("error = %q, want %q", body["error"], msg)
+	}
+}
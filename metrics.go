@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/meruff/go-trailhead-leaderboard-api/events"
+	"github.com/meruff/go-trailhead-leaderboard-api/metrics"
+	"github.com/meruff/go-trailhead-leaderboard-api/trailhead"
+)
+
+var (
+	// registry holds every metric exported at /metrics.
+	registry = metrics.NewRegistry()
+
+	httpRequests = registry.NewCounter(
+		"http_requests_total", "Requests handled, by route template, method and status code.",
+		"route", "method", "status",
+	)
+	httpRequestDuration = registry.NewHistogram(
+		"http_request_duration_seconds", "Time taken to handle requests, by route template and method.",
+		metrics.DefaultBuckets, "route", "method",
+	)
+	upstreamCallouts = registry.NewCounter(
+		"trailhead_callouts_total", "Callouts made to Trailhead, by upstream and operation name.",
+		"upstream", "operation",
+	)
+	upstreamErrors = registry.NewCounter(
+		"trailhead_callout_errors_total", "Callouts to Trailhead that failed, by upstream and operation name.",
+		"upstream", "operation",
+	)
+	upstreamDuration = registry.NewHistogram(
+		"trailhead_callout_duration_seconds", "Time taken by callouts to Trailhead, retries included, by upstream and operation name.",
+		metrics.DefaultBuckets, "upstream", "operation",
+	)
+	cacheLookups = registry.NewCounter(
+		"trailhead_cache_lookups_total", "Lookups in the stale response and identity caches, by cache and whether they hit.",
+		"cache", "result",
+	)
+	memberPoints = registry.NewGauge(
+		"trailblazer_points", "Points of each leaderboard member as of the last check.",
+		"handle",
+	)
+	memberBadges = registry.NewGauge(
+		"trailblazer_badges", "Badges earned by each leaderboard member as of the last check.",
+		"handle",
+	)
+)
+
+// upstreamObserver is told about every Trailhead callout and cache lookup, and records them both in
+// the metrics and in the logs.
+var upstreamObserver = trailhead.Observers{metricsObserver{}, logObserver{}}
+
+// metricsObserver records Trailhead callouts and cache lookups in the registry.
+type metricsObserver struct{}
+
+func (metricsObserver) ObserveCallout(ctx context.Context, upstream, operation string, duration time.Duration, err error) {
+	upstreamCallouts.Inc(upstream, operation)
+	upstreamDuration.Observe(duration.Seconds(), upstream, operation)
+	if err != nil {
+		upstreamErrors.Inc(upstream, operation)
+	}
+}
+
+func (metricsObserver) ObserveCacheLookup(ctx context.Context, cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+
+	cacheLookups.Inc(cache, result)
+}
+
+// metricsHandler exports every metric in the Prometheus text format.
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	registry.Write(w)
+}
+
+// instrumentHandler counts requests and how long they took by their route template, so requests
+// for different Trailblazers are counted together.
+func instrumentHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+
+		route := routeTemplate(r)
+		httpRequests.Inc(route, r.Method, strconv.Itoa(recorder.Status()))
+		httpRequestDuration.Observe(time.Since(start).Seconds(), route, r.Method)
+	})
+}
+
+// recordMemberRanks updates the points and badges gauges of the leaderboard members just checked,
+// and drops members that are no longer on any leaderboard.
+func recordMemberRanks(ranks map[string]events.Rank) {
+	for handle, rank := range ranks {
+		memberPoints.Set(float64(rank.Points), handle)
+		memberBadges.Set(float64(rank.Badges), handle)
+	}
+
+	tracked := map[string]bool{}
+	for _, member := range trackedMembers() {
+		tracked[strings.ToLower(member.Handle)] = true
+	}
+
+	keep := func(labelValues []string) bool { return tracked[strings.ToLower(labelValues[0])] }
+	memberPoints.Keep(keep)
+	memberBadges.Keep(keep)
+}
+
+// routeTemplate returns the template of the route that matched the request, i.e.
+// "/trailblazer/{id}/rank", or "unmatched".
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+
+	return "unmatched"
+}
+
+// responseRecorder remembers the status code and size of the response written through it.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// Status returns the status code written, 200 if the handler didn't write one.
+func (r *responseRecorder) Status() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+
+	return r.status
+}
@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	tests := []struct {
+		name   string
+		record func(r *Registry)
+		want   string
+	}{
+		{
+			name: "counter",
+			record: func(r *Registry) {
+				c := r.NewCounter("requests_total", "Requests handled.", "route", "status")
+				c.Inc("/b", "200")
+				c.Inc("/a", "404")
+				c.Add(2, "/b", "200")
+			},
+			want: `# HELP requests_total Requests handled.
+# TYPE requests_total counter
+requests_total{route="/a",status="404"} 1
+requests_total{route="/b",status="200"} 3
+`,
+		},
+		{
+			name: "gauge kept and escaped",
+			record: func(r *Registry) {
+				g := r.NewGauge("points", "Points by\nhandle.", "handle")
+				g.Set(100, "astro")
+				g.Set(5, `we"ird\`)
+				g.Set(7, "gone")
+				g.Set(250, "astro")
+				g.Keep(func(labelValues []string) bool { return labelValues[0] != "gone" })
+			},
+			want: `# HELP points Points by\nhandle.
+# TYPE points gauge
+points{handle="astro"} 250
+points{handle="we\"ird\\"} 5
+`,
+		},
+		{
+			name: "unlabelled gauge",
+			record: func(r *Registry) {
+				r.NewGauge("up", "Whether the app is up.").Set(math.Inf(1))
+			},
+			want: `# HELP up Whether the app is up.
+# TYPE up gauge
+up +Inf
+`,
+		},
+		{
+			name: "histogram",
+			record: func(r *Registry) {
+				h := r.NewHistogram("duration_seconds", "Time taken.", []float64{0.1, 1}, "route")
+				h.Observe(0.05, "/a")
+				h.Observe(0.1, "/a")
+				h.Observe(0.5, "/a")
+				h.Observe(3, "/a")
+			},
+			want: `# HELP duration_seconds Time taken.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{route="/a",le="0.1"} 2
+duration_seconds_bucket{route="/a",le="1"} 3
+duration_seconds_bucket{route="/a",le="+Inf"} 4
+duration_seconds_sum{route="/a"} 3.65
+duration_seconds_count{route="/a"} 4
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry()
+			tt.record(r)
+
+			var out bytes.Buffer
+			if err := r.Write(&out); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("Write() wrote\n%s\nwant\n%s", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistryWritesInRegistrationOrder(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("zeta_total", "Last.")
+	r.NewCounter("alpha_total", "First.")
+
+	var out bytes.Buffer
+	r.Write(&out)
+
+	if zeta, alpha := strings.Index(out.String(), "zeta_total"), strings.Index(out.String(), "alpha_total"); zeta > alpha {
+		t.Errorf("Write() wrote alpha_total before zeta_total:\n%s", out.String())
+	}
+}
+
+func TestWrongLabelCountPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Inc() with too few label values didn't panic")
+		}
+	}()
+
+	NewRegistry().NewCounter("requests_total", "Requests handled.", "route", "status").Inc("/a")
+}
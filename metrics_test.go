@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// sample returns the value of the series exported at /metrics, written like
+// `trailhead_callouts_total{upstream="graphql",operation="GetTrailheadRank"}`, or 0 if there's no
+// such series yet.
+func sample(t *testing.T, series string) float64 {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	metricsHandler(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), series+" "); ok {
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				t.Fatalf("parsing %s: %v", scanner.Text(), err)
+			}
+			return v
+		}
+	}
+
+	return 0
+}
+
+func TestMetricsObserver(t *testing.T) {
+	const (
+		callouts = `trailhead_callouts_total{upstream="graphql",operation="MetricsTest"}`
+		failures = `trailhead_callout_errors_total{upstream="graphql",operation="MetricsTest"}`
+		observed = `trailhead_callout_duration_seconds_count{upstream="graphql",operation="MetricsTest"}`
+		slow     = `trailhead_callout_duration_seconds_bucket{upstream="graphql",operation="MetricsTest",le="0.25"}`
+		hits     = `trailhead_cache_lookups_total{cache="metrics-test",result="hit"}`
+		misses   = `trailhead_cache_lookups_total{cache="metrics-test",result="miss"}`
+	)
+
+	tests := []struct {
+		name    string
+		observe func(ctx context.Context, observer metricsObserver)
+		want    map[string]float64
+	}{
+		{
+			name: "successful callout",
+			observe: func(ctx context.Context, observer metricsObserver) {
+				observer.ObserveCallout(ctx, "graphql", "MetricsTest", 100*time.Millisecond, nil)
+			},
+			want: map[string]float64{callouts: 1, observed: 1, slow: 1},
+		},
+		{
+			name: "failed callout",
+			observe: func(ctx context.Context, observer metricsObserver) {
+				observer.ObserveCallout(ctx, "graphql", "MetricsTest", time.Second, errors.New("bad gateway"))
+			},
+			want: map[string]float64{callouts: 1, failures: 1, observed: 1},
+		},
+		{
+			name: "cache lookups",
+			observe: func(ctx context.Context, observer metricsObserver) {
+				observer.ObserveCacheLookup(ctx, "metrics-test", true)
+				observer.ObserveCacheLookup(ctx, "metrics-test", false)
+				observer.ObserveCacheLookup(ctx, "metrics-test", false)
+			},
+			want: map[string]float64{hits: 1, misses: 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := map[string]float64{}
+			for _, series := range []string{callouts, failures, observed, slow, hits, misses} {
+				before[series] = sample(t, series)
+			}
+
+			tt.observe(context.Background(), metricsObserver{})
+
+			for series, previous := range before {
+				if got := sample(t, series) - previous; got != tt.want[series] {
+					t.Errorf("%s went up by %g, want %g", series, got, tt.want[series])
+				}
+			}
+		})
+	}
+}
+
+func TestUpstreamObserverRecordsCalloutsAndIdentityLookups(t *testing.T) {
+	useFakeUpstream(t)
+	upstream.Observer = upstreamObserver
+	identities.Observer = upstreamObserver
+
+	const (
+		callouts = `trailhead_callouts_total{upstream="graphql",operation="GetTrailheadProfile"}`
+		hits     = `trailhead_cache_lookups_total{cache="identity",result="hit"}`
+		misses   = `trailhead_cache_lookups_total{cache="identity",result="miss"}`
+	)
+	calloutsBefore, hitsBefore, missesBefore := sample(t, callouts), sample(t, hits), sample(t, misses)
+
+	for i := 0; i < 2; i++ {
+		if _, err := identities.Resolve(context.Background(), "0053k00000AstroQAA"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := sample(t, callouts) - calloutsBefore; got != 1 {
+		t.Errorf("GetTrailheadProfile callouts went up by %g, want 1", got)
+	}
+	if got := sample(t, misses) - missesBefore; got != 1 {
+		t.Errorf("identity cache misses went up by %g, want 1", got)
+	}
+	if got := sample(t, hits) - hitsBefore; got != 1 {
+		t.Errorf("identity cache hits went up by %g, want 1", got)
+	}
+}
+
+func TestInstrumentHandler(t *testing.T) {
+	r := mux.NewRouter()
+	r.HandleFunc("/metrics-test/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["id"] == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	r.Use(instrumentHandler)
+
+	tests := []struct {
+		path   string
+		series string
+	}{
+		{path: "/metrics-test/astro", series: `http_requests_total{route="/metrics-test/{id}",method="GET",status="200"}`},
+		{path: "/metrics-test/missing", series: `http_requests_total{route="/metrics-test/{id}",method="GET",status="404"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			const durations = `http_request_duration_seconds_count{route="/metrics-test/{id}",method="GET"}`
+			before, durationsBefore := sample(t, tt.series), sample(t, durations)
+
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if got := sample(t, tt.series) - before; got != 1 {
+				t.Errorf("%s went up by %g, want 1", tt.series, got)
+			}
+			if got := sample(t, durations) - durationsBefore; got != 1 {
+				t.Errorf("%s went up by %g, want 1", durations, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/meruff/go-trailhead-leaderboard-api/events"
+)
+
+// memberStates holds the last known badges, certifications and rank of every leaderboard member.
+var memberStates *events.StateStore
+
+// trackedMember is a Trailblazer on one or more leaderboards.
+type trackedMember struct {
+	Handle       string
+	Leaderboards []string
+}
+
+// watchMembers checks leaderboard members for new badges, certifications and ranks every
+// interval until ctx is done, and delivers what changed to webhooks.
+func watchMembers(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		ctx, span := tracer.Start(ctx, "poll members")
+		for _, event := range pollMembers(ctx) {
+			webhooks.Dispatch(event)
+		}
+		span.End()
+	}
+}
+
+// pollMembersHandler checks every leaderboard member for changes right away rather than waiting
+// for the next scheduled check, delivers them to webhooks, and returns the events found.
+func pollMembersHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdminKey(w, r) {
+		return
+	}
+
+	detected := pollMembers(r.Context())
+	for _, event := range detected {
+		webhooks.Dispatch(event)
+	}
+
+	if detected == nil {
+		detected = []events.Event{}
+	}
+
+	encodeAndWriteToBrowser(w, detected)
+}
+
+// pollMembers compares every leaderboard member's profile with their state from the last check
+// and returns the events for what changed. Members seen for the first time are recorded without
+// any events, so adding someone doesn't announce every badge they've ever earned. Members that
+// can't be looked up are skipped until the next check.
+func pollMembers(ctx context.Context) []events.Event {
+	members := trackedMembers()
+	handles := make([]string, len(members))
+	for i, member := range members {
+		handles[i] = member.Handle
+	}
+
+	var (
+		mu       sync.Mutex
+		detected []events.Event
+		ranks    = map[string]events.Rank{}
+	)
+	forEachHandle(handles, func(i int, handle string) {
+		snapshot, err := takeSnapshot(ctx, handle)
+		if err != nil {
+			slog.WarnContext(ctx, "checking for new badges", "handle", handle, "error", err)
+			return
+		}
+
+		mu.Lock()
+		ranks[handle] = snapshot.Rank
+		mu.Unlock()
+
+		now := time.Now()
+		prev, _ := memberStates.Get(handle)
+		memberEvents := events.Detect(prev, snapshot, now)
+		for j := range memberEvents {
+			memberEvents[j].Leaderboards = members[i].Leaderboards
+		}
+
+		mu.Lock()
+		detected = append(detected, memberEvents...)
+		mu.Unlock()
+
+		if err := memberStates.Put(handle, snapshot.State(now)); err != nil {
+			slog.ErrorContext(ctx, "saving member state", "handle", handle, "error", err)
+		}
+	})
+
+	recordMemberRanks(ranks)
+
+	sort.SliceStable(detected, func(i, j int) bool {
+		return detected[i].OccurredAt.Before(detected[j].OccurredAt)
+	})
+
+	return detected
+}
+
+// trackedMembers returns every Trailblazer on a leaderboard, with the leaderboards they're on.
+func trackedMembers() []trackedMember {
+	var members []trackedMember
+	index := map[string]int{}
+
+	for _, name := range store.Names() {
+		lb, err := store.Get(name)
+		if err != nil {
+			continue
+		}
+
+		for _, member := range lb.Members {
+			key := strings.ToLower(member.Handle)
+			if i, ok := index[key]; ok {
+				members[i].Leaderboards = append(members[i].Leaderboards, name)
+				continue
+			}
+
+			index[key] = len(members)
+			members = append(members, trackedMember{Handle: member.Handle, Leaderboards: []string{name}})
+		}
+	}
+
+	return members
+}
+
+// takeSnapshot reads a Trailblazer's recent badges, certifications and rank from Trailhead.
+func takeSnapshot(ctx context.Context, handle string) (events.Snapshot, error) {
+	snapshot := events.Snapshot{Handle: handle}
+
+	profile, err := getTrailheadProfile(ctx, handle)
+	if err != nil {
+		return snapshot, err
+	}
+	snapshot.Name = strings.TrimSpace(profile.FirstName + " " + profile.LastName)
+
+	awards, err := getRecentAwards(ctx, handle)
+	if err != nil {
+		return snapshot, err
+	}
+	for _, award := range awards {
+		snapshot.Badges = append(snapshot.Badges, events.Badge{
+			ID:          award.Award.ID,
+			Title:       award.Award.Award.Title,
+			Type:        award.Award.Award.Type,
+			Icon:        award.Award.Award.Icon,
+			URL:         award.Award.Award.Content.WebURL,
+			Description: award.Award.Award.Content.Description,
+			EarnedAt:    award.EarnedAt,
+		})
+	}
+
+	certifications, err := getTrailheadCertifications(ctx, handle)
+	if err != nil {
+		return snapshot, err
+	}
+	if certifications.Data.Profile.Typename != "PublicProfile" {
+		return snapshot, errors.New("no certification data returned from Trailhead")
+	}
+	for _, certification := range certifications.Data.Profile.Credential.Certifications {
+		dateExpired, _ := certification.DateExpired.(string)
+		snapshot.Certifications = append(snapshot.Certifications, events.Certification{
+			Title:         certification.Title,
+			LogoURL:       certification.LogoURL,
+			InfoURL:       certification.InfoURL,
+			DateCompleted: certification.DateCompleted,
+			DateExpired:   dateExpired,
+			Expired:       certification.Status.Expired,
+		})
+	}
+
+	stats, err := getTrailheadRankStats(ctx, handle)
+	if err != nil {
+		return snapshot, err
+	}
+	snapshot.Rank = events.Rank{
+		Title:          stats.Rank.Title,
+		ImageURL:       stats.Rank.ImageURL,
+		RequiredPoints: stats.Rank.RequiredPointsSum,
+		Points:         stats.EarnedPointsSum,
+		Badges:         stats.EarnedBadgesCount,
+	}
+
+	return snapshot, nil
+}
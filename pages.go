@@ -0,0 +1,44 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// pages holds the HTML pages served by the API, built into the binary.
+//
+//go:embed index.html
+var pages embed.FS
+
+// indexHandler serves the leaderboard page, which lists the stored leaderboards.
+func indexHandler(w http.ResponseWriter, r *http.Request) {
+	writePageToBrowser(w, "index.html")
+}
+
+// leaderboardViewHandler serves the leaderboard page for a single leaderboard. The page ranks its
+// members in the browser using the profile and rank endpoints.
+func leaderboardViewHandler(w http.ResponseWriter, r *http.Request) {
+	if _, err := store.Get(mux.Vars(r)["name"]); err != nil {
+		writeErrorToBrowser(w, fmt.Sprintf("No leaderboard named %s.", mux.Vars(r)["name"]), 404)
+		return
+	}
+
+	writePageToBrowser(w, "index.html")
+}
+
+// writePageToBrowser writes an embedded HTML page to the browser.
+func writePageToBrowser(w http.ResponseWriter, name string) {
+	page, err := pages.ReadFile(name)
+	if err != nil {
+		slog.Error("reading page", "page", name, "error", err)
+		writeErrorToBrowser(w, "Problem loading page.", 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(page)
+}
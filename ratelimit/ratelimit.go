@@ -0,0 +1,90 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket rate limiter. The bucket holds up to burst tokens and refills at rate
+// tokens per second, and each event takes one token.
+type Limiter struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter returns a Limiter allowing rate events per second with bursts of up to burst events.
+// The bucket starts full.
+func NewLimiter(rate float64, burst int) *Limiter {
+	return &Limiter{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// Wait blocks until an event is allowed or ctx is done.
+func (l *Limiter) Wait(ctx context.Context) error {
+	for {
+		wait := l.take()
+		if wait == 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Allow takes a token if one is available without waiting. If not, it returns false and how long
+// until a token will be available.
+func (l *Limiter) Allow() (bool, time.Duration) {
+	wait := l.take()
+	return wait == 0, wait
+}
+
+// Burst returns the most events allowed at once.
+func (l *Limiter) Burst() int {
+	return int(l.burst)
+}
+
+// Status returns the number of whole tokens left and how long until the bucket is full again.
+func (l *Limiter) Status() (int, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refill(time.Now())
+
+	untilFull := time.Duration((l.burst - l.tokens) / l.rate * float64(time.Second))
+
+	return int(l.tokens), untilFull
+}
+
+// take removes a token if one is available, otherwise returns how long until one will be.
+func (l *Limiter) take() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refill(time.Now())
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+
+	return time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+}
+
+// refill adds the tokens earned since the last refill. Callers must hold the lock.
+func (l *Limiter) refill(now time.Time) {
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+}
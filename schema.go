@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/meruff/go-trailhead-leaderboard-api/drift"
+	"github.com/meruff/go-trailhead-leaderboard-api/trailhead"
+)
+
+// expectedShapes are the types GraphQL responses are decoded into, by operation name. Responses
+// to these operations are checked for drift from them.
+var expectedShapes = map[string]interface{}{
+	"GetTrailheadRank":      trailhead.Rank{},
+	"GetEarnedSkills":       trailhead.Skills{},
+	"GetUserCertifications": trailhead.Certifications{},
+	"GetTrailheadBadges":    trailhead.Badges{},
+}
+
+// schemaDrift tallies the ways Trailhead's responses have drifted from their expected shapes.
+var schemaDrift = drift.NewReport()
+
+var schemaDriftCount = registry.NewCounter(
+	"trailhead_schema_drift_total", "Differences found between Trailhead responses and their expected shapes, by operation and kind.",
+	"operation", "kind",
+)
+
+// checkSchemaDrift compares a response to a GraphQL operation with the shape it's expected to have,
+// counting every difference and logging the ones not seen before. Responses for private or missing
+// profiles are skipped, since they're expected to be mostly empty.
+func checkSchemaDrift(ctx context.Context, operationName string, body []byte) {
+	shape, ok := expectedShapes[operationName]
+	if !ok {
+		return
+	}
+
+	var profile struct {
+		Data struct {
+			Profile struct {
+				Typename string `json:"__typename"`
+			} `json:"profile"`
+		} `json:"data"`
+	}
+	if json.Unmarshal(body, &profile) == nil && profile.Data.Profile.Typename != "PublicProfile" {
+		return
+	}
+
+	issues, err := drift.Check(body, shape)
+	if err != nil {
+		slog.WarnContext(ctx, "trailhead response isn't JSON", "operation", operationName, "error", err)
+		issues = []drift.Issue{{Kind: drift.TypeChanged, Expected: "object", Got: "invalid JSON"}}
+	}
+
+	for _, issue := range issues {
+		schemaDriftCount.Inc(operationName, string(issue.Kind))
+	}
+
+	for _, issue := range schemaDrift.Record(operationName, issues, time.Now()) {
+		slog.WarnContext(ctx, "trailhead response drifted from expected shape",
+			"operation", operationName, "path", issue.Path, "kind", issue.Kind,
+			"expected", issue.Expected, "got", issue.Got)
+	}
+}
+
+// schemaDriftHandler reports every difference found between Trailhead's responses and their
+// expected shapes, with how often and when each was seen.
+func schemaDriftHandler(w http.ResponseWriter, r *http.Request) {
+	encodeAndWriteToBrowser(w, schemaDrift.Entries())
+}
+
+// resetSchemaDriftHandler clears the drift report, i.e. once the code has caught up with Trailhead.
+func resetSchemaDriftHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdminKey(w, r) {
+		return
+	}
+
+	schemaDrift.Reset()
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/meruff/go-trailhead-leaderboard-api/apikey"
+	"github.com/meruff/go-trailhead-leaderboard-api/drift"
+)
+
+// rankBody is a GetTrailheadRank response in its expected shape, with its earnedPointsSum member
+// replaced by extra unless extra is "".
+func rankBody(extra string) string {
+	stats := `"__typename": "TrailheadProfileStats", "earnedPointsSum": 1200, "earnedBadgesCount": 4,
+		"completedTrailCount": 1, "nextRank": null,
+		"rank": {"__typename": "TrailheadRank", "title": "Hiker", "requiredPointsSum": 200, "requiredBadgesCount": 1, "imageUrl": "https://example.com/hiker.png"}`
+	if extra != "" {
+		stats = strings.Replace(stats, `"earnedPointsSum": 1200`, extra, 1)
+	}
+
+	return `{"data": {"profile": {"__typename": "PublicProfile", "trailheadStats": {` + stats + `}}}}`
+}
+
+// useSchemaDrift gives the app an empty drift report until the test ends.
+func useSchemaDrift(t *testing.T) {
+	t.Helper()
+
+	previous := schemaDrift
+	schemaDrift = drift.NewReport()
+	t.Cleanup(func() { schemaDrift = previous })
+}
+
+func TestCheckSchemaDrift(t *testing.T) {
+	tests := []struct {
+		name      string
+		operation string
+		body      string
+		want      []drift.Issue
+	}{
+		{
+			name:      "expected shape",
+			operation: "GetTrailheadRank",
+			body:      rankBody(""),
+		},
+		{
+			name:      "operation without a shape",
+			operation: "GetTrailheadProfile",
+			body:      `{"data": {"profile": {"__typename": "PublicProfile", "surprise": true}}}`,
+		},
+		{
+			name:      "private profile",
+			operation: "GetTrailheadRank",
+			body:      `{"data": {"profile": {"__typename": "PrivateProfile"}}}`,
+		},
+		{
+			name:      "type changed",
+			operation: "GetTrailheadRank",
+			body:      rankBody(`"earnedPointsSum": "1200"`),
+			want: []drift.Issue{{
+				Path: "data.profile.trailheadStats.earnedPointsSum", Kind: drift.TypeChanged, Expected: "integer", Got: "string",
+			}},
+		},
+		{
+			name:      "field added",
+			operation: "GetTrailheadRank",
+			body:      rankBody(`"earnedPointsSum": 1200, "streak": 3`),
+			want: []drift.Issue{{
+				Path: "data.profile.trailheadStats.streak", Kind: drift.UnknownField, Expected: "nothing", Got: "number",
+			}},
+		},
+		{
+			name:      "invalid JSON",
+			operation: "GetTrailheadRank",
+			body:      `<html>Maintenance</html>`,
+			want:      []drift.Issue{{Kind: drift.TypeChanged, Expected: "object", Got: "invalid JSON"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useSchemaDrift(t)
+
+			counted := map[drift.Kind]float64{}
+			for _, issue := range tt.want {
+				series := `trailhead_schema_drift_total{operation="` + tt.operation + `",kind="` + string(issue.Kind) + `"}`
+				counted[issue.Kind] = sample(t, series)
+			}
+
+			checkSchemaDrift(context.Background(), tt.operation, []byte(tt.body))
+
+			entries := schemaDrift.Entries()
+			if len(entries) != len(tt.want) {
+				t.Fatalf("drift report = %+v, want %+v", entries, tt.want)
+			}
+			for i, entry := range entries {
+				if entry.Operation != tt.operation || entry.Issue != tt.want[i] || entry.Count != 1 {
+					t.Errorf("entry %d = %+v, want %s %+v seen once", i, entry, tt.operation, tt.want[i])
+				}
+
+				series := `trailhead_schema_drift_total{operation="` + tt.operation + `",kind="` + string(entry.Kind) + `"}`
+				if got := sample(t, series) - counted[entry.Kind]; got != 1 {
+					t.Errorf("%s went up by %g, want 1", series, got)
+				}
+			}
+		})
+	}
+}
+
+func TestSchemaDriftHandlers(t *testing.T) {
+	useSchemaDrift(t)
+	checkSchemaDrift(context.Background(), "GetTrailheadRank", []byte(rankBody(`"earnedPointsSum": "1200"`)))
+	checkSchemaDrift(context.Background(), "GetTrailheadRank", []byte(rankBody(`"earnedPointsSum": "1300"`)))
+
+	w := httptest.NewRecorder()
+	schemaDriftHandler(w, httptest.NewRequest(http.MethodGet, "/status/drift", nil))
+
+	var entries []drift.Entry
+	if err := json.NewDecoder(w.Body).Decode(&entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Operation != "GetTrailheadRank" || entries[0].Kind != drift.TypeChanged || entries[0].Count != 2 {
+		t.Fatalf("GET /status/drift = %+v, want one type change seen twice", entries)
+	}
+
+	registry, err := apikey.NewRegistry([]apikey.Key{{Name: "dashboard", Key: "s3cret"}, {Name: "ops", Key: "t0psecret", Admin: true}}, 60, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(previous *apikey.Registry) { apiKeys = previous }(apiKeys)
+	apiKeys = registry
+
+	tests := []struct {
+		key         string
+		wantStatus  int
+		wantEntries int
+	}{
+		{key: "s3cret", wantStatus: http.StatusForbidden, wantEntries: 1},
+		{key: "t0psecret", wantStatus: http.StatusNoContent, wantEntries: 0},
+	}
+
+	for _, tt := range tests {
+		client, _ := registry.Lookup(tt.key)
+		req := httptest.NewRequest(http.MethodDelete, "/status/drift", nil)
+		req = req.WithContext(context.WithValue(req.Context(), apiClientKey{}, client))
+		w := httptest.NewRecorder()
+
+		resetSchemaDriftHandler(w, req)
+
+		if w.Code != tt.wantStatus {
+			t.Errorf("DELETE /status/drift as %s = %d, want %d", client.Key.Name, w.Code, tt.wantStatus)
+		}
+		if got := len(schemaDrift.Entries()); got != tt.wantEntries {
+			t.Errorf("after DELETE /status/drift as %s the report has %d entries, want %d", client.Key.Name, got, tt.wantEntries)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/meruff/go-trailhead-leaderboard-api/config"
+	"github.com/meruff/go-trailhead-leaderboard-api/tlsreload"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// serverFromConfig builds the HTTP server, listening on LISTEN_ADDR, or on PORT as Heroku sets it,
+// falling back to the config file's listen address and then :8000. The write timeout is generous
+// since leaderboards fan out to Trailhead for every member.
+func serverFromConfig(handler http.Handler, conf config.Server) (*http.Server, error) {
+	addr := os.Getenv("LISTEN_ADDR")
+	if port := os.Getenv("PORT"); addr == "" && port != "" {
+		addr = ":" + port
+	}
+	if addr == "" {
+		addr = conf.Listen
+	}
+	if addr == "" {
+		addr = ":8000"
+	}
+
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return nil, fmt.Errorf("server.listen (LISTEN_ADDR) must be a host:port or :port address, got %q", addr)
+	}
+
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadTimeout:       conf.ReadTimeout.Or(15 * time.Second),
+		ReadHeaderTimeout: conf.ReadHeaderTimeout.Or(5 * time.Second),
+		WriteTimeout:      conf.WriteTimeout.Or(60 * time.Second),
+		IdleTimeout:       conf.IdleTimeout.Or(120 * time.Second),
+		MaxHeaderBytes:    config.Or(conf.MaxHeaderBytes, 1<<20),
+	}, nil
+}
+
+// certificatesFromConfig loads the TLS certificate and key, if set, for the server to terminate
+// TLS itself. Client certificates are verified against the client CAs, if set, and the client auth
+// setting decides whether clients must send one (require, the default) or may (optional). Also
+// returns how often to check the files for changes.
+func certificatesFromConfig(conf config.TLS) (*tlsreload.Reloader, time.Duration, error) {
+	if conf.CertFile == "" {
+		return nil, 0, nil
+	}
+
+	clientAuth := tls.RequireAndVerifyClientCert
+	if strings.EqualFold(conf.ClientAuth, "optional") {
+		clientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	certificates, err := tlsreload.New(conf.CertFile, conf.KeyFile, conf.ClientCAFile, clientAuth)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return certificates, conf.ReloadInterval.Or(time.Minute), nil
+}
+
+// watchCertificates reloads the TLS certificate, key and client CAs every interval if their files
+// changed, until ctx is done.
+func watchCertificates(ctx context.Context, certificates *tlsreload.Reloader, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		reloaded, err := certificates.Reload()
+		if err != nil {
+			slog.Error("reloading TLS certificate, still serving the last one loaded", "error", err)
+		} else if reloaded {
+			slog.Info("reloaded TLS certificate", "cert_file", certificates.CertFile)
+		}
+	}
+}
+
+// serve serves requests on listener, over TLS if srv has a TLS config, until SIGTERM or SIGINT,
+// then shuts down within timeout: it stops accepting connections and waits for in-flight requests,
+// stops the pollers, waits for queued webhook deliveries and flushes traces. Returns an error if
+// the server fails or shutdown runs out of time.
+func serve(srv *http.Server, listener net.Listener, timeout time.Duration, pollers *background, tracerProvider *sdktrace.TracerProvider) error {
+	signals, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	served := make(chan error, 1)
+	go func() {
+		if srv.TLSConfig != nil {
+			served <- srv.ServeTLS(listener, "", "")
+		} else {
+			served <- srv.Serve(listener)
+		}
+	}()
+	slog.Info("listening", "addr", listener.Addr().String(), "tls", srv.TLSConfig != nil)
+
+	select {
+	case err := <-served:
+		return fmt.Errorf("serving: %w", err)
+	case <-signals.Done():
+	}
+	stop()
+
+	slog.Info("shutting down", "timeout", timeout.String())
+	ready.Store(false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var errs []error
+	if err := srv.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("draining requests: %w", err))
+	}
+	if err := pollers.Stop(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("stopping pollers: %w", err))
+	}
+	if err := webhooks.Drain(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("delivering queued webhooks: %w", err))
+	}
+	if tracerProvider != nil {
+		if err := tracerProvider.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("flushing traces: %w", err))
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	slog.Info("shut down")
+
+	return nil
+}
+
+// background runs the pollers and schedules that work alongside the server, so they can be
+// stopped together on shutdown.
+type background struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newBackground() *background {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &background{ctx: ctx, cancel: cancel}
+}
+
+// Go runs f in its own goroutine with a context that's done once Stop is called.
+func (b *background) Go(f func(ctx context.Context)) {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		f(b.ctx)
+	}()
+}
+
+// Stop cancels every goroutine started with Go and waits for them to return, or for ctx to be
+// done, whichever comes first.
+func (b *background) Stop(ctx context.Context) error {
+	b.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/meruff/go-trailhead-leaderboard-api/badge"
+	"github.com/meruff/go-trailhead-leaderboard-api/trailhead"
+)
+
+// svgCacheControl lets browsers and image proxies like GitHub's cache badges and cards for a while,
+// rather than calling Trailhead every time a README is viewed.
+const svgCacheControl = "public, max-age=1800"
+
+// shieldLabels are the labels of the metrics a shield can show.
+var shieldLabels = map[string]string{
+	"points": "trailhead points",
+	"badges": "trailhead badges",
+	"rank":   "trailhead rank",
+	"certs":  "certifications",
+}
+
+// shieldHandler returns a shields.io style SVG badge showing one of a Trailblazer's points, badges,
+// rank or certifications, picked with the metric query parameter. Errors are drawn as a badge too,
+// since the badge is usually shown in an <img> tag.
+func shieldHandler(w http.ResponseWriter, r *http.Request) {
+	metric := r.URL.Query().Get("metric")
+	if metric == "" {
+		metric = "points"
+	}
+
+	label, ok := shieldLabels[metric]
+	if !ok {
+		writeSvgToBrowser(w, badge.Shield{Label: "metric", Value: "invalid", Color: badge.ErrorColor}.SVG(), 400)
+		return
+	}
+
+	userAlias, err := resolveSvgHandle(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeShieldErrorToBrowser(w, label, err)
+		return
+	}
+
+	var value string
+	if metric == "certs" {
+		certifications, err := getTrailheadCertifications(r.Context(), userAlias)
+		if err != nil {
+			writeShieldErrorToBrowser(w, label, err)
+			return
+		}
+
+		active := 0
+		for _, certification := range certifications.Data.Profile.Credential.Certifications {
+			if !certification.Status.Expired {
+				active++
+			}
+		}
+		value = badge.FormatCount(active)
+	} else {
+		rank, err := getTrailheadRankStats(r.Context(), userAlias)
+		if err != nil {
+			writeShieldErrorToBrowser(w, label, err)
+			return
+		}
+
+		switch metric {
+		case "points":
+			value = badge.FormatCount(rank.EarnedPointsSum)
+		case "badges":
+			value = badge.FormatCount(rank.EarnedBadgesCount)
+		case "rank":
+			value = rank.Rank.Title
+		}
+	}
+
+	w.Header().Set("Cache-Control", svgCacheControl)
+	writeSvgToBrowser(w, badge.Shield{Label: label, Value: value}.SVG(), 200)
+}
+
+// cardHandler returns an SVG profile card for a Trailblazer with their photo, name, rank and
+// totals.
+func cardHandler(w http.ResponseWriter, r *http.Request) {
+	userAlias, err := resolveSvgHandle(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeShieldErrorToBrowser(w, "trailblazer", err)
+		return
+	}
+
+	profile, err := getTrailheadProfile(r.Context(), userAlias)
+	if err != nil {
+		writeShieldErrorToBrowser(w, "trailblazer", err)
+		return
+	}
+
+	rank, err := getTrailheadRankStats(r.Context(), userAlias)
+	if err != nil {
+		writeShieldErrorToBrowser(w, "trailblazer", err)
+		return
+	}
+
+	card := badge.Card{
+		Name:         strings.TrimSpace(profile.FirstName + " " + profile.LastName),
+		Handle:       userAlias,
+		Company:      profile.Company.Name,
+		PhotoURL:     profile.PhotoURL,
+		RankTitle:    rank.Rank.Title,
+		RankImageURL: rank.Rank.ImageURL,
+		Points:       rank.EarnedPointsSum,
+		Badges:       rank.EarnedBadgesCount,
+		Trails:       rank.CompletedTrailCount,
+	}
+	if card.Name == "" {
+		card.Name = userAlias
+	}
+
+	w.Header().Set("Cache-Control", svgCacheControl)
+	writeSvgToBrowser(w, card.SVG(), 200)
+}
+
+// resolveSvgHandle resolves a Trailblazer handle or user ID to their handle, like resolveHandle,
+// but leaves reporting the error to the caller.
+func resolveSvgHandle(ctx context.Context, handleOrID string) (string, error) {
+	identity, err := identities.Resolve(ctx, handleOrID)
+	if err != nil {
+		slog.WarnContext(ctx, "resolving trailblazer", "id", handleOrID, "error", err)
+		return "", errProfileNotFound
+	}
+
+	return identity.Handle, nil
+}
+
+// getTrailheadRankStats returns a Trailblazer's rank and totals, or errProfilePrivate if their
+// profile is private.
+func getTrailheadRankStats(ctx context.Context, userAlias string) (trailhead.TrailheadStats, error) {
+	rank, err := getTrailheadRank(ctx, userAlias)
+	if err != nil {
+		return trailhead.TrailheadStats{}, err
+	}
+
+	switch rank.Data.Profile.Typename {
+	case "PublicProfile":
+		return rank.Data.Profile.TrailheadStats, nil
+	case "PrivateProfile":
+		return trailhead.TrailheadStats{}, errProfilePrivate
+	default:
+		return trailhead.TrailheadStats{}, errProfileNotFound
+	}
+}
+
+// writeShieldErrorToBrowser writes an error as a shield, with the same 503 status as the JSON
+// endpoints whatever went wrong, an unknown Trailblazer included. Like writeCalloutErrorToBrowser,
+// an open circuit breaker sets Retry-After.
+func writeShieldErrorToBrowser(w http.ResponseWriter, label string, err error) {
+	var openErr *trailhead.CircuitOpenError
+
+	value := "unavailable"
+	switch {
+	case errors.Is(err, errProfileNotFound):
+		value = "not found"
+	case errors.Is(err, errProfilePrivate):
+		value = "private"
+	case errors.As(err, &openErr):
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(openErr.RetryAfter.Seconds()))))
+	}
+
+	w.Header().Set("Cache-Control", "no-cache")
+	writeSvgToBrowser(w, badge.Shield{Label: label, Value: value, Color: badge.ErrorColor}.SVG(), 503)
+}
+
+// writeSvgToBrowser writes an SVG image to the browser.
+func writeSvgToBrowser(w http.ResponseWriter, svg []byte, code int) {
+	w.Header().Set("Content-Type", "image/svg+xml; charset=utf-8")
+	w.WriteHeader(code)
+	w.Write(svg)
+}
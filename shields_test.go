@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/meruff/go-trailhead-leaderboard-api/trailhead"
+)
+
+func TestWriteShieldErrorToBrowser(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		wantValue      string
+		wantRetryAfter string
+	}{
+		{name: "not found", err: errProfileNotFound, wantValue: "not found"},
+		{name: "private", err: fmt.Errorf("rank: %w", errProfilePrivate), wantValue: "private"},
+		{name: "breaker open", err: &trailhead.CircuitOpenError{Upstream: "graphql", RetryAfter: 1500 * time.Millisecond}, wantValue: "unavailable", wantRetryAfter: "2"},
+		{name: "callout failed", err: &trailhead.StatusError{StatusCode: 502}, wantValue: "unavailable"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			writeShieldErrorToBrowser(w, "points", tt.err)
+
+			// Same as the JSON endpoints.
+			if w.Code != 503 {
+				t.Errorf("status = %d, want 503", w.Code)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantValue) {
+				t.Errorf("shield doesn't show %q:\n%s", tt.wantValue, w.Body)
+			}
+			if got := w.Header().Get("Retry-After"); got != tt.wantRetryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.wantRetryAfter)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package snapshot
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/meruff/go-trailhead-leaderboard-api/badge"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	// DefaultWidth is the width in pixels of an image when Options.Width isn't set.
+	DefaultWidth = 800
+	// MinWidth and MaxWidth bound Options.Width.
+	MinWidth = 400
+	MaxWidth = 2000
+)
+
+// Row is a Trailblazer's line in the table.
+type Row struct {
+	Place     int
+	Name      string
+	RankTitle string
+	Badges    int
+	Points    int
+}
+
+// Theme holds the colors an image is drawn with.
+type Theme struct {
+	Background color.RGBA
+	Title      color.RGBA
+	TitleText  color.RGBA
+	Text       color.RGBA
+	Muted      color.RGBA
+	Stripe     color.RGBA
+	Border     color.RGBA
+	Podium     color.RGBA
+}
+
+// Themes are the themes an image can be drawn with, by name.
+var Themes = map[string]Theme{
+	"light": {
+		Background: color.RGBA{0xff, 0xff, 0xff, 0xff},
+		Title:      color.RGBA{0x03, 0x2d, 0x60, 0xff},
+		TitleText:  color.RGBA{0xff, 0xff, 0xff, 0xff},
+		Text:       color.RGBA{0x18, 0x18, 0x18, 0xff},
+		Muted:      color.RGBA{0x5c, 0x6b, 0x7a, 0xff},
+		Stripe:     color.RGBA{0xf3, 0xf6, 0xf9, 0xff},
+		Border:     color.RGBA{0xdd, 0xe3, 0xea, 0xff},
+		Podium:     color.RGBA{0x01, 0x76, 0xd3, 0xff},
+	},
+	"dark": {
+		Background: color.RGBA{0x16, 0x1b, 0x22, 0xff},
+		Title:      color.RGBA{0x03, 0x2d, 0x60, 0xff},
+		TitleText:  color.RGBA{0xff, 0xff, 0xff, 0xff},
+		Text:       color.RGBA{0xe6, 0xed, 0xf3, 0xff},
+		Muted:      color.RGBA{0x8b, 0x94, 0x9e, 0xff},
+		Stripe:     color.RGBA{0x1f, 0x26, 0x2e, 0xff},
+		Border:     color.RGBA{0x30, 0x36, 0x3d, 0xff},
+		Podium:     color.RGBA{0x58, 0xa6, 0xff, 0xff},
+	},
+}
+
+// Options controls how an image is drawn.
+type Options struct {
+	Title string
+	// Width is the width of the image in pixels. Everything is scaled to fit it.
+	Width int
+	Theme Theme
+	// Updated is shown in the footer, omitted if zero.
+	Updated time.Time
+}
+
+var regularFont, boldFont *opentype.Font
+
+func init() {
+	var err error
+	if regularFont, err = opentype.Parse(goregular.TTF); err != nil {
+		panic(err)
+	}
+	if boldFont, err = opentype.Parse(gobold.TTF); err != nil {
+		panic(err)
+	}
+}
+
+// column is a column of the table. X is the left edge, or the right edge when Right is set.
+type column struct {
+	Title string
+	X     int
+	Width int
+	Right bool
+}
+
+// Render draws rows as a table and writes it to w as a PNG.
+func Render(w io.Writer, rows []Row, opts Options) error {
+	if opts.Width == 0 {
+		opts.Width = DefaultWidth
+	}
+	if opts.Width < MinWidth || opts.Width > MaxWidth {
+		return fmt.Errorf("width must be between %d and %d, got %d", MinWidth, MaxWidth, opts.Width)
+	}
+	if opts.Theme == (Theme{}) {
+		opts.Theme = Themes["light"]
+	}
+
+	scale := float64(opts.Width) / DefaultWidth
+	px := func(n float64) int { return int(n*scale + 0.5) }
+
+	titleFace, err := newFace(boldFont, 22*scale)
+	if err != nil {
+		return err
+	}
+	defer titleFace.Close()
+	boldFace, err := newFace(boldFont, 15*scale)
+	if err != nil {
+		return err
+	}
+	defer boldFace.Close()
+	textFace, err := newFace(regularFont, 15*scale)
+	if err != nil {
+		return err
+	}
+	defer textFace.Close()
+	smallFace, err := newFace(regularFont, 12*scale)
+	if err != nil {
+		return err
+	}
+	defer smallFace.Close()
+
+	padding, titleHeight, headerHeight, rowHeight, footerHeight := px(24), px(64), px(36), px(44), px(36)
+	if opts.Updated.IsZero() {
+		footerHeight = px(12)
+	}
+
+	width := opts.Width
+	height := titleHeight + headerHeight + rowHeight*len(rows) + footerHeight
+	if len(rows) == 0 {
+		height += rowHeight
+	}
+
+	// Fixed width columns are laid out from the right, the Trailblazer's name gets what's left.
+	inner := width - 2*padding
+	place := column{Title: "#", X: padding, Width: px(40)}
+	points := column{Title: "POINTS", X: width - padding, Width: px(100), Right: true}
+	badges := column{Title: "BADGES", X: points.X - points.Width - px(16), Width: px(80), Right: true}
+	rank := column{Title: "RANK", X: badges.X - badges.Width - px(16) - inner/4, Width: inner / 4}
+	name := column{Title: "TRAILBLAZER", X: place.X + place.Width}
+	name.Width = rank.X - name.X - px(16)
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	fill(img, img.Bounds(), opts.Theme.Background)
+	fill(img, image.Rect(0, 0, width, titleHeight), opts.Theme.Title)
+	drawText(img, titleFace, opts.Theme.TitleText, padding, baseline(titleFace, 0, titleHeight), truncate(titleFace, opts.Title, inner))
+
+	y := titleHeight
+	for _, c := range []column{place, name, rank, badges, points} {
+		drawCell(img, smallFace, opts.Theme.Muted, c, baseline(smallFace, y, headerHeight), c.Title)
+	}
+	y += headerHeight
+	fill(img, image.Rect(0, y-1, width, y), opts.Theme.Border)
+
+	if len(rows) == 0 {
+		drawText(img, textFace, opts.Theme.Muted, padding, baseline(textFace, y, rowHeight), "No members yet.")
+	}
+
+	for i, row := range rows {
+		if i%2 == 1 {
+			fill(img, image.Rect(0, y, width, y+rowHeight), opts.Theme.Stripe)
+		}
+
+		placeColor := opts.Theme.Text
+		if row.Place <= 3 {
+			placeColor = opts.Theme.Podium
+		}
+
+		line := baseline(textFace, y, rowHeight)
+		drawCell(img, boldFace, placeColor, place, line, strconv.Itoa(row.Place))
+		drawCell(img, boldFace, opts.Theme.Text, name, line, row.Name)
+		drawCell(img, textFace, opts.Theme.Muted, rank, line, row.RankTitle)
+		drawCell(img, textFace, opts.Theme.Text, badges, line, badge.FormatCount(row.Badges))
+		drawCell(img, boldFace, opts.Theme.Text, points, line, badge.FormatCount(row.Points))
+		y += rowHeight
+	}
+
+	if len(rows) == 0 {
+		y += rowHeight
+	}
+
+	if !opts.Updated.IsZero() {
+		fill(img, image.Rect(0, y, width, y+1), opts.Theme.Border)
+		updated := "Updated " + opts.Updated.UTC().Format("Jan 2, 2006 15:04 MST")
+		drawText(img, smallFace, opts.Theme.Muted, padding, baseline(smallFace, y, footerHeight), updated)
+	}
+
+	return png.Encode(w, img)
+}
+
+// newFace returns a face for the font at size, in pixels.
+func newFace(f *opentype.Font, size float64) (font.Face, error) {
+	return opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+}
+
+// fill paints a rectangle of img.
+func fill(img draw.Image, r image.Rectangle, c color.Color) {
+	draw.Draw(img, r, image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+// baseline returns the y of the baseline that vertically centers a line of text in a band of
+// height starting at top.
+func baseline(face font.Face, top, height int) int {
+	metrics := face.Metrics()
+	textHeight := (metrics.Ascent + metrics.Descent).Ceil()
+
+	return top + (height-textHeight)/2 + metrics.Ascent.Ceil()
+}
+
+// drawCell draws text in a column, truncated to the column's width.
+func drawCell(img draw.Image, face font.Face, c color.Color, col column, y int, text string) {
+	text = truncate(face, text, col.Width)
+	x := col.X
+	if col.Right {
+		x -= font.MeasureString(face, text).Ceil()
+	}
+
+	drawText(img, face, c, x, y, text)
+}
+
+// drawText draws text with its baseline starting at x, y.
+func drawText(img draw.Image, face font.Face, c color.Color, x, y int, text string) {
+	d := font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(c),
+		Face: face,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(text)
+}
+
+// truncate shortens text with an ellipsis until it fits in width pixels.
+func truncate(face font.Face, text string, width int) string {
+	if font.MeasureString(face, text).Ceil() <= width {
+		return text
+	}
+
+	runes := []rune(text)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		if shortened := string(runes) + "…"; font.MeasureString(face, shortened).Ceil() <= width {
+			return shortened
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,171 @@
+package tlsreload
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCertificate writes a self-signed certificate for commonName, and its key, to cert.pem and
+// key.pem in dir, dated modTime so Reload sees them as changed.
+func writeCertificate(t *testing.T, dir, commonName string, modTime time.Time) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeFile(t, certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), modTime)
+	writeFile(t, keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), modTime)
+
+	return certFile, keyFile
+}
+
+func writeFile(t *testing.T, path string, content []byte, modTime time.Time) {
+	t.Helper()
+
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// served returns the common name of the certificate handed to a handshake.
+func served(t *testing.T, r *Reloader) string {
+	t.Helper()
+
+	config, err := r.TLSConfig().GetConfigForClient(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Certificates) != 1 {
+		t.Fatalf("config has %d certificates, want 1", len(config.Certificates))
+	}
+	leaf, err := x509.ParseCertificate(config.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return leaf.Subject.CommonName
+}
+
+func TestGetConfigForClient(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCertificate(t, dir, "first", time.Now().Add(-time.Hour))
+
+	t.Run("without client CAs", func(t *testing.T) {
+		r, err := New(certFile, keyFile, "", tls.RequireAndVerifyClientCert)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := served(t, r); got != "first" {
+			t.Errorf("served %q, want first", got)
+		}
+		config, _ := r.TLSConfig().GetConfigForClient(&tls.ClientHelloInfo{})
+		if config.ClientCAs != nil || config.ClientAuth != tls.NoClientCert {
+			t.Errorf("config asks for client certificates (%v) without client CAs", config.ClientAuth)
+		}
+	})
+
+	t.Run("with client CAs", func(t *testing.T) {
+		r, err := New(certFile, keyFile, certFile, tls.VerifyClientCertIfGiven)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		config, _ := r.TLSConfig().GetConfigForClient(&tls.ClientHelloInfo{})
+		if config.ClientCAs == nil || config.ClientAuth != tls.VerifyClientCertIfGiven {
+			t.Errorf("ClientAuth = %v with CAs %v, want VerifyClientCertIfGiven with the CA file", config.ClientAuth, config.ClientCAs)
+		}
+	})
+
+	t.Run("missing files", func(t *testing.T) {
+		if _, err := New(filepath.Join(dir, "missing.pem"), keyFile, "", tls.NoClientCert); err == nil {
+			t.Error("New() with a missing certificate succeeded")
+		}
+	})
+}
+
+func TestReloadServesReplacedCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCertificate(t, dir, "first", time.Now().Add(-time.Hour))
+
+	r, err := New(certFile, keyFile, "", tls.NoClientCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if changed, err := r.Reload(); changed || err != nil {
+		t.Errorf("Reload() of unchanged files = %v, %v, want false, nil", changed, err)
+	}
+
+	writeCertificate(t, dir, "second", time.Now())
+
+	if changed, err := r.Reload(); !changed || err != nil {
+		t.Fatalf("Reload() of replaced files = %v, %v, want true, nil", changed, err)
+	}
+	if got := served(t, r); got != "second" {
+		t.Errorf("served %q after the files were replaced, want second", got)
+	}
+}
+
+func TestReloadKeepsCertificateWhenReplacementIsBroken(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCertificate(t, dir, "first", time.Now().Add(-time.Hour))
+
+	r, err := New(certFile, keyFile, "", tls.NoClientCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, certFile, []byte("not a certificate"), time.Now())
+
+	if changed, err := r.Reload(); changed || err == nil {
+		t.Fatalf("Reload() of a broken certificate = %v, %v, want false and an error", changed, err)
+	}
+	if got := served(t, r); got != "first" {
+		t.Errorf("served %q after a broken replacement, want first", got)
+	}
+
+	// The broken files are tried again on every Reload until they're fixed.
+	writeCertificate(t, dir, "fixed", time.Now().Add(time.Minute))
+
+	if changed, err := r.Reload(); !changed || err != nil {
+		t.Fatalf("Reload() of fixed files = %v, %v, want true, nil", changed, err)
+	}
+	if got := served(t, r); got != "fixed" {
+		t.Errorf("served %q after the files were fixed, want fixed", got)
+	}
+}
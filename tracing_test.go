@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	spanRecorderOnce sync.Once
+	spanRecorder     *tracetest.SpanRecorder
+)
+
+// recordSpans installs a global tracer provider that records every span in memory. The tracers
+// made at package init only delegate to the first provider set, so it's shared by every test.
+func recordSpans() *tracetest.SpanRecorder {
+	spanRecorderOnce.Do(func() {
+		spanRecorder = tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder)))
+	})
+
+	return spanRecorder
+}
+
+// spanAttribute returns the value of a span's attribute, and whether it's set.
+func spanAttribute(span sdktrace.ReadOnlySpan, key attribute.Key) (attribute.Value, bool) {
+	for _, attr := range span.Attributes() {
+		if attr.Key == key {
+			return attr.Value, true
+		}
+	}
+
+	return attribute.Value{}, false
+}
+
+func TestTracingCalloutSpanIsChildOfRequestSpan(t *testing.T) {
+	recorder := recordSpans()
+
+	useFakeUpstream(t)
+
+	r := mux.NewRouter()
+	r.Use(tracingHandler)
+	r.HandleFunc("/trailblazer/{id}/rank", rankHandler)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/trailblazer/astro/rank", nil))
+	if w.Code != 200 {
+		t.Fatalf("GET /trailblazer/astro/rank = %d: %s", w.Code, w.Body)
+	}
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+
+	request, ok := spans["GET /trailblazer/{id}/rank"]
+	if !ok {
+		t.Fatalf("no request span among %v", spanNames(recorder.Ended()))
+	}
+	if request.SpanKind() != trace.SpanKindServer {
+		t.Errorf("request span kind = %v, want server", request.SpanKind())
+	}
+
+	callout, ok := spans["trailhead GetTrailheadRank"]
+	if !ok {
+		t.Fatalf("no callout span among %v", spanNames(recorder.Ended()))
+	}
+	if callout.Parent().SpanID() != request.SpanContext().SpanID() || callout.SpanContext().TraceID() != request.SpanContext().TraceID() {
+		t.Errorf("callout span's parent is %v, want the request span %v", callout.Parent().SpanID(), request.SpanContext().SpanID())
+	}
+	if operation, _ := spanAttribute(callout, "trailhead.operation"); operation.AsString() != "GetTrailheadRank" {
+		t.Errorf("trailhead.operation = %q, want GetTrailheadRank", operation.AsString())
+	}
+	if slug, _ := spanAttribute(callout, "trailhead.slug"); slug.AsString() != "astro" {
+		t.Errorf("trailhead.slug = %q, want astro", slug.AsString())
+	}
+
+	attempt, ok := spans["POST"]
+	if !ok {
+		t.Fatalf("no attempt span among %v", spanNames(recorder.Ended()))
+	}
+	if attempt.Parent().SpanID() != callout.SpanContext().SpanID() {
+		t.Errorf("attempt span's parent is %v, want the callout span %v", attempt.Parent().SpanID(), callout.SpanContext().SpanID())
+	}
+	if status, _ := spanAttribute(attempt, "http.response.status_code"); status.AsInt64() != 200 {
+		t.Errorf("attempt http.response.status_code = %d, want 200", status.AsInt64())
+	}
+}
+
+func spanNames(spans []sdktrace.ReadOnlySpan) []string {
+	var names []string
+	for _, span := range spans {
+		names = append(names, span.Name())
+	}
+
+	return names
+}
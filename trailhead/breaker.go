@@ -0,0 +1,160 @@
+package trailhead
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is matched by errors returned while an upstream's circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// CircuitOpenError is returned instead of calling an upstream whose breaker is open and for which
+// no cached response is available. RetryAfter is how long until the breaker lets a trial request
+// through.
+type CircuitOpenError struct {
+	Upstream   string
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%s: %v, retry in %v", e.Upstream, ErrCircuitOpen, e.RetryAfter.Round(time.Millisecond))
+}
+
+// Is lets errors.Is match a CircuitOpenError against ErrCircuitOpen.
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// BreakerState is the state of a circuit breaker.
+type BreakerState int
+
+const (
+	// BreakerClosed lets every request through.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen rejects every request until OpenTimeout has passed.
+	BreakerOpen
+	// BreakerHalfOpen lets a single trial request through to decide whether to close again.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// MarshalText encodes the state by name in JSON status output.
+func (s BreakerState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// BreakerStatus is a snapshot of a Breaker for status reporting.
+type BreakerStatus struct {
+	Upstream            string       `json:"upstream"`
+	State               BreakerState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutiveFailures"`
+	OpenedAt            *time.Time   `json:"openedAt,omitempty"`
+	RetryAfterSeconds   int          `json:"retryAfterSeconds,omitempty"`
+}
+
+// Breaker is a circuit breaker for one upstream. It opens after FailureThreshold consecutive
+// failures, rejects requests for OpenTimeout, then lets one trial request through: success closes
+// the breaker again and failure reopens it.
+type Breaker struct {
+	Upstream         string
+	FailureThreshold int
+	OpenTimeout      time.Duration
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	trialing bool
+}
+
+// NewBreaker returns a closed Breaker for the named upstream.
+func NewBreaker(upstream string, failureThreshold int, openTimeout time.Duration) *Breaker {
+	return &Breaker{Upstream: upstream, FailureThreshold: failureThreshold, OpenTimeout: openTimeout}
+}
+
+// Allow reports whether a request may be sent upstream. If not, the returned error is a
+// *CircuitOpenError. Every allowed request must be followed by a call to Record.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if wait := b.OpenTimeout - time.Since(b.openedAt); wait > 0 {
+			return &CircuitOpenError{Upstream: b.Upstream, RetryAfter: wait}
+		}
+		b.state = BreakerHalfOpen
+		b.trialing = true
+		return nil
+	case BreakerHalfOpen:
+		if b.trialing {
+			return &CircuitOpenError{Upstream: b.Upstream, RetryAfter: time.Second}
+		}
+		b.trialing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// Record reports the outcome of an allowed request.
+func (b *Breaker) Record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trialing = false
+
+	if success {
+		b.state = BreakerClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.FailureThreshold {
+		b.state = BreakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// abandon ends an allowed request without a verdict, i.e. because the caller gave up on it.
+func (b *Breaker) abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trialing = false
+}
+
+// Status returns the current state of the breaker.
+func (b *Breaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BreakerStatus{Upstream: b.Upstream, State: b.state, ConsecutiveFailures: b.failures}
+
+	if b.state != BreakerClosed {
+		openedAt := b.openedAt
+		status.OpenedAt = &openedAt
+	}
+
+	if b.state == BreakerOpen {
+		if wait := b.OpenTimeout - time.Since(b.openedAt); wait > 0 {
+			status.RetryAfterSeconds = int(math.Ceil(wait.Seconds()))
+		}
+	}
+
+	return status
+}
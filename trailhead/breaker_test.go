@@ -0,0 +1,171 @@
+package trailhead_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/meruff/go-trailhead-leaderboard-api/trailhead"
+	"github.com/meruff/go-trailhead-leaderboard-api/trailheadtest"
+)
+
+// newBreakerClient returns a client of fake that doesn't retry, keeps no stale responses and whose
+// GraphQL breaker opens after two failures for openTimeout.
+func newBreakerClient(fake *trailheadtest.Server, openTimeout time.Duration) *trailhead.Client {
+	client := newClient(fake)
+	client.Retry.MaxAttempts = 1
+	client.GraphqlBreaker = trailhead.NewBreaker("graphql", 2, openTimeout)
+	client.SetStaleCache(time.Hour, 0)
+
+	return client
+}
+
+func assertBreakerState(t *testing.T, breaker *trailhead.Breaker, want trailhead.BreakerState) {
+	t.Helper()
+
+	if state := breaker.Status().State; state != want {
+		t.Fatalf("breaker is %v, want %v", state, want)
+	}
+}
+
+func TestClientBreakerOpensAfterConsecutiveFailures(t *testing.T) {
+	fake := newFake(t)
+	fake.Fail(trailheadtest.AnyOperation, trailheadtest.Failure{Status: http.StatusServiceUnavailable})
+	client := newBreakerClient(fake, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		assertBreakerState(t, client.GraphqlBreaker, trailhead.BreakerClosed)
+		if _, err := queryRank(context.Background(), client, "astro"); err == nil {
+			t.Fatal("Query() succeeded, want the injected failure")
+		}
+	}
+	assertBreakerState(t, client.GraphqlBreaker, trailhead.BreakerOpen)
+
+	_, err := queryRank(context.Background(), client, "astro")
+
+	var openErr *trailhead.CircuitOpenError
+	if !errors.As(err, &openErr) || !errors.Is(err, trailhead.ErrCircuitOpen) {
+		t.Fatalf("Query() error = %v, want a CircuitOpenError", err)
+	}
+	if openErr.RetryAfter <= 0 || openErr.RetryAfter > time.Minute {
+		t.Errorf("RetryAfter = %v, want up to the 1m open timeout", openErr.RetryAfter)
+	}
+	if calls := fake.Calls("GetTrailheadRank"); calls != 2 {
+		t.Errorf("fake got %d calls, want the open breaker to stop the third", calls)
+	}
+}
+
+func TestClientBreakerClosesAfterSuccessfulTrial(t *testing.T) {
+	fake := newFake(t)
+	fake.Fail(trailheadtest.AnyOperation, trailheadtest.Failure{Status: http.StatusServiceUnavailable, Times: 2})
+	client := newBreakerClient(fake, 50*time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		queryRank(context.Background(), client, "astro")
+	}
+	assertBreakerState(t, client.GraphqlBreaker, trailhead.BreakerOpen)
+
+	time.Sleep(60 * time.Millisecond)
+
+	if _, err := queryRank(context.Background(), client, "astro"); err != nil {
+		t.Fatalf("trial Query() error = %v, want success", err)
+	}
+	assertBreakerState(t, client.GraphqlBreaker, trailhead.BreakerClosed)
+
+	if status := client.GraphqlBreaker.Status(); status.ConsecutiveFailures != 0 {
+		t.Errorf("ConsecutiveFailures = %d after closing, want 0", status.ConsecutiveFailures)
+	}
+}
+
+func TestClientBreakerReopensAfterFailedTrial(t *testing.T) {
+	fake := newFake(t)
+	fake.Fail(trailheadtest.AnyOperation, trailheadtest.Failure{Status: http.StatusServiceUnavailable})
+	client := newBreakerClient(fake, 50*time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		queryRank(context.Background(), client, "astro")
+	}
+	time.Sleep(60 * time.Millisecond)
+
+	if _, err := queryRank(context.Background(), client, "astro"); errors.Is(err, trailhead.ErrCircuitOpen) || err == nil {
+		t.Fatalf("trial Query() error = %v, want the injected failure", err)
+	}
+	assertBreakerState(t, client.GraphqlBreaker, trailhead.BreakerOpen)
+
+	if calls := fake.Calls("GetTrailheadRank"); calls != 3 {
+		t.Errorf("fake got %d calls, want 3 including the trial", calls)
+	}
+}
+
+func TestClientBreakerLetsOneTrialThroughWhileHalfOpen(t *testing.T) {
+	fake := newFake(t)
+	fake.Fail(trailheadtest.AnyOperation, trailheadtest.Failure{Status: http.StatusServiceUnavailable, Times: 2})
+	client := newBreakerClient(fake, 50*time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		queryRank(context.Background(), client, "astro")
+	}
+	time.Sleep(60 * time.Millisecond)
+
+	// Slow the trial down so a second request arrives while it's in flight.
+	fake.Fail(trailheadtest.AnyOperation, trailheadtest.Failure{Status: http.StatusServiceUnavailable, Delay: 200 * time.Millisecond, Times: 1})
+
+	trial := make(chan error, 1)
+	go func() {
+		_, err := queryRank(context.Background(), client, "astro")
+		trial <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	assertBreakerState(t, client.GraphqlBreaker, trailhead.BreakerHalfOpen)
+
+	if _, err := queryRank(context.Background(), client, "matruff"); !errors.Is(err, trailhead.ErrCircuitOpen) {
+		t.Errorf("Query() during the trial error = %v, want ErrCircuitOpen", err)
+	}
+
+	<-trial
+	assertBreakerState(t, client.GraphqlBreaker, trailhead.BreakerOpen)
+}
+
+func TestClientBreakerIgnoresClientErrors(t *testing.T) {
+	fake := newFake(t)
+	fake.Fail(trailheadtest.AnyOperation, trailheadtest.Failure{Status: http.StatusNotFound})
+	client := newBreakerClient(fake, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		queryRank(context.Background(), client, "astro")
+	}
+
+	assertBreakerState(t, client.GraphqlBreaker, trailhead.BreakerClosed)
+}
+
+func TestClientServesStaleResponseWhileBreakerOpen(t *testing.T) {
+	fake := newFake(t)
+	client := newBreakerClient(fake, time.Minute)
+	client.SetStaleCache(time.Hour, 10)
+
+	fresh, err := queryRank(context.Background(), client, "astro")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake.Fail(trailheadtest.AnyOperation, trailheadtest.Failure{Status: http.StatusServiceUnavailable})
+	for i := 0; i < 2; i++ {
+		queryRank(context.Background(), client, "matruff")
+	}
+	assertBreakerState(t, client.GraphqlBreaker, trailhead.BreakerOpen)
+
+	stale, err := queryRank(context.Background(), client, "astro")
+	if err != nil {
+		t.Fatalf("Query() error = %v, want the stale response", err)
+	}
+	if string(stale) != string(fresh) {
+		t.Errorf("Query() = %s, want the stale response %s", stale, fresh)
+	}
+
+	if _, err := queryRank(context.Background(), client, "matruff"); !errors.Is(err, trailhead.ErrCircuitOpen) {
+		t.Errorf("Query() with nothing cached error = %v, want ErrCircuitOpen", err)
+	}
+}
@@ -0,0 +1,74 @@
+package trailhead
+
+import (
+	"sync"
+	"time"
+)
+
+// responseCache keeps the last successful response for each request so it can be served while an
+// upstream's circuit breaker is open. Entries older than ttl are never served, and the oldest
+// entry is evicted once maxEntries is reached.
+type responseCache struct {
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]cachedResponse
+}
+
+type cachedResponse struct {
+	body   []byte
+	stored time.Time
+}
+
+func newResponseCache(ttl time.Duration, maxEntries int) *responseCache {
+	return &responseCache{ttl: ttl, maxEntries: maxEntries, entries: map[string]cachedResponse{}}
+}
+
+// get returns the cached response for key if there is one younger than the cache TTL.
+func (c *responseCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	if time.Since(entry.stored) > c.ttl {
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	return entry.body, true
+}
+
+// put stores the response for key.
+func (c *responseCache) put(key string, body []byte) {
+	if c.maxEntries <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.maxEntries {
+		c.evictOldest()
+	}
+
+	c.entries[key] = cachedResponse{body: body, stored: time.Now()}
+}
+
+// evictOldest removes the least recently stored entry. Callers must hold the lock.
+func (c *responseCache) evictOldest() {
+	var oldestKey string
+	var oldest time.Time
+
+	for key, entry := range c.entries {
+		if oldestKey == "" || entry.stored.Before(oldest) {
+			oldestKey, oldest = key, entry.stored
+		}
+	}
+
+	delete(c.entries, oldestKey)
+}
@@ -0,0 +1,303 @@
+package trailhead
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/meruff/go-trailhead-leaderboard-api/ratelimit"
+	"github.com/meruff/go-trailhead-leaderboard-api/requestid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrProfilePageNotFound is returned by Client.ProfilePage when the Trailblazer has no profile page.
+var ErrProfilePageNotFound = errors.New("profile page not found")
+
+// StatusError is returned when Trailhead responds with an unsuccessful HTTP status.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("trailhead responded with %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Observer is told how every callout a Client makes went, and whether lookups in a cache found what
+// they were looking for, i.e. to export metrics. upstream is "graphql" or "profile", and operation
+// is the GraphQL operation name or "ProfilePage".
+type Observer interface {
+	ObserveCallout(ctx context.Context, upstream, operation string, duration time.Duration, err error)
+	ObserveCacheLookup(ctx context.Context, cache string, hit bool)
+}
+
+// Observers is an Observer that tells each of its Observers in turn.
+type Observers []Observer
+
+func (o Observers) ObserveCallout(ctx context.Context, upstream, operation string, duration time.Duration, err error) {
+	for _, observer := range o {
+		observer.ObserveCallout(ctx, upstream, operation, duration, err)
+	}
+}
+
+func (o Observers) ObserveCacheLookup(ctx context.Context, cache string, hit bool) {
+	for _, observer := range o {
+		observer.ObserveCacheLookup(ctx, cache, hit)
+	}
+}
+
+// Client makes callouts to Trailhead's GraphQL API and Trailblazer profile pages, retrying
+// transient failures according to Retry. Each upstream has its own circuit breaker. While a
+// breaker is open the last successful response to the same request is served if there is one.
+// Identical requests made while one is already in flight share its response, and every attempt
+// sent upstream waits its turn on Limiter, if set. Every callout is reported to Observer, if set,
+// and sends Headers.
+type Client struct {
+	GraphqlURL     string
+	ProfileURL     string
+	Headers        http.Header
+	HTTPClient     *http.Client
+	Retry          RetryPolicy
+	GraphqlBreaker *Breaker
+	ProfileBreaker *Breaker
+	Limiter        *ratelimit.Limiter
+	Observer       Observer
+
+	cache   *responseCache
+	flights flightGroup
+}
+
+// NewClient returns a Client for the given GraphQL endpoint and profile page base URL, sending the
+// Accept headers of a browser, using the default retry policy, a 10 second timeout, breakers that
+// open after 5 consecutive failures for 30 seconds, and at most 10 callouts per second. Responses
+// are kept for up to a day to serve while a breaker is open.
+func NewClient(graphqlURL, profileURL string) *Client {
+	return &Client{
+		GraphqlURL:     graphqlURL,
+		ProfileURL:     profileURL,
+		Headers:        http.Header{"Accept": {"*/*"}, "Accept-Language": {"en-US,en;q=0.5"}},
+		HTTPClient:     &http.Client{Timeout: 10 * time.Second},
+		Retry:          DefaultRetryPolicy(),
+		GraphqlBreaker: NewBreaker("graphql", 5, 30*time.Second),
+		ProfileBreaker: NewBreaker("profile", 5, 30*time.Second),
+		Limiter:        ratelimit.NewLimiter(10, 10),
+		cache:          newResponseCache(24*time.Hour, 1000),
+	}
+}
+
+// SetStaleCache changes how long and how many responses are kept to serve while a breaker is
+// open. A maxEntries of zero disables the cache.
+func (c *Client) SetStaleCache(ttl time.Duration, maxEntries int) {
+	c.cache = newResponseCache(ttl, maxEntries)
+}
+
+// Breakers returns the status of each upstream's circuit breaker.
+func (c *Client) Breakers() []BreakerStatus {
+	return []BreakerStatus{c.GraphqlBreaker.Status(), c.ProfileBreaker.Status()}
+}
+
+// Query posts a GraphQL payload, as built by GetGraphqlPayload, and returns the response body. The
+// body may be shared with concurrent callers and must not be modified.
+func (c *Client) Query(ctx context.Context, operationName string, payload string) ([]byte, error) {
+	ctx, span := startCalloutSpan(ctx, "graphql", operationName, payloadSlug(payload))
+	start := time.Now()
+	body, err := c.do(ctx, c.GraphqlBreaker, "graphql:"+payload, func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", c.GraphqlURL, strings.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+
+		c.setHeaders(req)
+		req.Header.Set("Content-Type", "application/json")
+
+		return req, nil
+	})
+	c.observeCallout(ctx, "graphql", operationName, start, err)
+	endSpan(span, err)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operationName, err)
+	}
+
+	return body, nil
+}
+
+// ProfilePage returns the HTML of a Trailblazer's public profile page. The body may be shared with
+// concurrent callers and must not be modified.
+func (c *Client) ProfilePage(ctx context.Context, handle string) ([]byte, error) {
+	ctx, span := startCalloutSpan(ctx, "profile", "ProfilePage", handle)
+	start := time.Now()
+	body, err := c.do(ctx, c.ProfileBreaker, "profile:"+handle, func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", c.ProfileURL+url.PathEscape(handle), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		c.setHeaders(req)
+
+		return req, nil
+	})
+	c.observeCallout(ctx, "profile", "ProfilePage", start, err)
+	endSpan(span, err)
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+		return nil, ErrProfilePageNotFound
+	}
+
+	return body, err
+}
+
+// observeCallout reports a finished callout to the Observer, if there is one.
+func (c *Client) observeCallout(ctx context.Context, upstream, operation string, start time.Time, err error) {
+	if c.Observer != nil {
+		c.Observer.ObserveCallout(ctx, upstream, operation, time.Since(start), err)
+	}
+}
+
+// setHeaders sets Headers on a callout, and passes along the ID of the inbound request that led to
+// it, if there was one.
+func (c *Client) setHeaders(req *http.Request) {
+	for name, values := range c.Headers {
+		req.Header[name] = values
+	}
+
+	if id := requestid.FromContext(req.Context()); id != "" {
+		req.Header.Set(requestid.Header, id)
+	}
+}
+
+// do coalesces concurrent callouts for the same key, then guards the callout with the upstream's
+// breaker, serving the cached response for key while the breaker is open and caching successful
+// responses. The shared callout keeps the first caller's trace and request ID but not its
+// cancellation, so one caller giving up doesn't fail the others. It's bounded by callTimeout
+// instead.
+func (c *Client) do(ctx context.Context, breaker *Breaker, key string, newRequest func(context.Context) (*http.Request, error)) ([]byte, error) {
+	return c.flights.do(ctx, key, func() ([]byte, error) {
+		callCtx := context.WithoutCancel(ctx)
+		if timeout := c.callTimeout(); timeout > 0 {
+			var cancel context.CancelFunc
+			callCtx, cancel = context.WithTimeout(callCtx, timeout)
+			defer cancel()
+		}
+
+		return c.guarded(callCtx, breaker, key, newRequest)
+	})
+}
+
+// callTimeout returns how long a callout may take, retries included: the last attempt may start
+// as late as Retry.MaxElapsedTime and then take up to the HTTP client's timeout. Zero means no
+// limit, when either of them is unlimited.
+func (c *Client) callTimeout() time.Duration {
+	if c.HTTPClient.Timeout <= 0 || c.Retry.MaxElapsedTime <= 0 {
+		return 0
+	}
+
+	return c.Retry.MaxElapsedTime + c.HTTPClient.Timeout
+}
+
+// guarded makes a callout through the upstream's breaker and the stale response cache.
+func (c *Client) guarded(ctx context.Context, breaker *Breaker, cacheKey string, newRequest func(context.Context) (*http.Request, error)) ([]byte, error) {
+	if err := breaker.Allow(); err != nil {
+		body, ok := c.cache.get(cacheKey)
+		if c.Observer != nil {
+			c.Observer.ObserveCacheLookup(ctx, "stale", ok)
+		}
+		if ok {
+			trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("trailhead.stale_cache", true))
+			return body, nil
+		}
+		return nil, err
+	}
+
+	body, err := c.retry(ctx, newRequest)
+
+	switch {
+	case err == nil:
+		breaker.Record(true)
+		c.cache.put(cacheKey, body)
+	case ctx.Err() != nil:
+		breaker.abandon()
+	default:
+		// Trailhead answering with a client error like 404 means it's up.
+		var statusErr *StatusError
+		breaker.Record(errors.As(err, &statusErr) && !isRetryableStatus(statusErr.StatusCode))
+	}
+
+	return body, err
+}
+
+// retry sends the request built by newRequest with ctx, retrying transient failures. A fresh request
+// is built for every attempt so its body can be read again.
+func (c *Client) retry(ctx context.Context, newRequest func(context.Context) (*http.Request, error)) ([]byte, error) {
+	start := time.Now()
+
+	for attempt := 1; ; attempt++ {
+		if c.Limiter != nil {
+			if err := c.Limiter.Wait(ctx); err != nil {
+				return nil, err
+			}
+		}
+
+		req, err := newRequest(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		body, retryable, wait, err := c.attempt(req, attempt)
+		if err == nil || !retryable || attempt >= c.Retry.MaxAttempts {
+			return body, err
+		}
+
+		if wait == 0 {
+			wait = c.Retry.backoff(attempt)
+		}
+
+		if c.Retry.MaxElapsedTime > 0 && time.Since(start)+wait > c.Retry.MaxElapsedTime {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(wait):
+		}
+	}
+}
+
+// attempt makes a single request in a span of its own. On failure it reports whether the request is
+// worth retrying and how long Trailhead asked us to wait first, zero if it didn't say.
+func (c *Client) attempt(req *http.Request, n int) (body []byte, retryable bool, wait time.Duration, err error) {
+	ctx, span := tracer.Start(req.Context(), req.Method, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("http.request.method", req.Method),
+		attribute.String("url.full", req.URL.String()),
+		attribute.Int("trailhead.attempt", n),
+	))
+	defer func() { endSpan(span, err) }()
+	req = req.WithContext(ctx)
+
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		// Don't retry once the caller has given up on the request.
+		return nil, req.Context().Err() == nil, 0, err
+	}
+	defer res.Body.Close()
+	span.SetAttributes(attribute.Int("http.response.status_code", res.StatusCode))
+
+	body, err = io.ReadAll(res.Body)
+	if err != nil {
+		return nil, true, 0, err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		statusErr := &StatusError{StatusCode: res.StatusCode, Body: string(body)}
+		return nil, isRetryableStatus(res.StatusCode), retryAfter(res.Header, time.Now()), statusErr
+	}
+
+	return body, false, 0, nil
+}
@@ -0,0 +1,151 @@
+package trailhead_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/meruff/go-trailhead-leaderboard-api/trailhead"
+	"github.com/meruff/go-trailhead-leaderboard-api/trailheadtest"
+)
+
+// newFake starts a fake Trailhead with the default fixtures, closed when the test ends.
+func newFake(t *testing.T) *trailheadtest.Server {
+	t.Helper()
+
+	fake := trailheadtest.NewServer(trailheadtest.DefaultTrailblazers())
+	t.Cleanup(fake.Close)
+
+	return fake
+}
+
+// newClient returns a client of fake that retries quickly and without jitter, so tests stay fast
+// and predictable.
+func newClient(fake *trailheadtest.Server) *trailhead.Client {
+	client := fake.Client()
+	client.Retry = trailhead.RetryPolicy{
+		MaxAttempts:     3,
+		InitialInterval: 10 * time.Millisecond,
+		MaxInterval:     50 * time.Millisecond,
+		Multiplier:      2,
+		MaxElapsedTime:  5 * time.Second,
+	}
+	client.Limiter = nil
+
+	return client
+}
+
+// queryRank asks for a Trailblazer's rank.
+func queryRank(ctx context.Context, client *trailhead.Client, handle string) ([]byte, error) {
+	payload := trailhead.GetGraphqlPayload("GetTrailheadRank", handle, "", trailhead.GetRankQuery())
+	return client.Query(ctx, "GetTrailheadRank", payload)
+}
+
+func TestClientRetriesTransientFailures(t *testing.T) {
+	tests := []struct {
+		name    string
+		failure trailheadtest.Failure
+	}{
+		{name: "service unavailable", failure: trailheadtest.Failure{Status: http.StatusServiceUnavailable, Times: 2}},
+		{name: "too many requests", failure: trailheadtest.Failure{Status: http.StatusTooManyRequests, Times: 2}},
+		{name: "dropped connection", failure: trailheadtest.Failure{Times: 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFake(t)
+			fake.Fail("GetTrailheadRank", tt.failure)
+
+			if _, err := queryRank(context.Background(), newClient(fake), "astro"); err != nil {
+				t.Fatalf("Query() error = %v, want success on the third attempt", err)
+			}
+			if calls := fake.Calls("GetTrailheadRank"); calls != 3 {
+				t.Errorf("fake got %d calls, want 3", calls)
+			}
+		})
+	}
+}
+
+func TestClientGivesUpAfterMaxAttempts(t *testing.T) {
+	fake := newFake(t)
+	fake.Fail("GetTrailheadRank", trailheadtest.Failure{Status: http.StatusBadGateway})
+
+	_, err := queryRank(context.Background(), newClient(fake), "astro")
+
+	var statusErr *trailhead.StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("Query() error = %v, want a 502 StatusError", err)
+	}
+	if calls := fake.Calls("GetTrailheadRank"); calls != 3 {
+		t.Errorf("fake got %d calls, want 3", calls)
+	}
+}
+
+func TestClientDoesNotRetryClientErrors(t *testing.T) {
+	fake := newFake(t)
+	fake.Fail("GetTrailheadRank", trailheadtest.Failure{Status: http.StatusBadRequest})
+
+	_, err := queryRank(context.Background(), newClient(fake), "astro")
+
+	var statusErr *trailhead.StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Query() error = %v, want a 400 StatusError", err)
+	}
+	if calls := fake.Calls("GetTrailheadRank"); calls != 1 {
+		t.Errorf("fake got %d calls, want 1", calls)
+	}
+}
+
+func TestClientBacksOffBetweenAttempts(t *testing.T) {
+	fake := newFake(t)
+	fake.Fail("GetTrailheadRank", trailheadtest.Failure{Status: http.StatusServiceUnavailable, Times: 2})
+
+	client := newClient(fake)
+	client.Retry.InitialInterval = 100 * time.Millisecond
+	client.Retry.MaxInterval = time.Second
+
+	start := time.Now()
+	if _, err := queryRank(context.Background(), client, "astro"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Waits 100ms after the first attempt and 200ms after the second.
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Errorf("Query() took %v, want at least 300ms of backoff", elapsed)
+	}
+}
+
+func TestClientHonorsRetryAfter(t *testing.T) {
+	fake := newFake(t)
+	fake.Fail("GetTrailheadRank", trailheadtest.Failure{Status: http.StatusTooManyRequests, RetryAfter: time.Second, Times: 1})
+
+	start := time.Now()
+	if _, err := queryRank(context.Background(), newClient(fake), "astro"); err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("Query() took %v, want it to wait the 1s Retry-After", elapsed)
+	}
+}
+
+func TestClientStopsWhenRetryAfterExceedsMaxElapsedTime(t *testing.T) {
+	fake := newFake(t)
+	fake.Fail("GetTrailheadRank", trailheadtest.Failure{Status: http.StatusTooManyRequests, RetryAfter: time.Minute, Times: 1})
+
+	client := newClient(fake)
+	client.Retry.MaxElapsedTime = time.Second
+
+	start := time.Now()
+	_, err := queryRank(context.Background(), client, "astro")
+
+	var statusErr *trailhead.StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("Query() error = %v, want the 429 StatusError", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Query() took %v, want it to give up without waiting", elapsed)
+	}
+}
@@ -0,0 +1,141 @@
+package trailhead_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/meruff/go-trailhead-leaderboard-api/trailheadtest"
+)
+
+// slowFirstAttempt makes the fake's next GetTrailheadRank response take delay and fail, so the
+// client retries once and succeeds. Callers arriving in the meantime find the callout in flight.
+func slowFirstAttempt(fake *trailheadtest.Server, delay time.Duration) {
+	fake.Fail("GetTrailheadRank", trailheadtest.Failure{Status: http.StatusServiceUnavailable, Delay: delay, Times: 1})
+}
+
+func TestClientCoalescesIdenticalCallouts(t *testing.T) {
+	fake := newFake(t)
+	slowFirstAttempt(fake, 100*time.Millisecond)
+	client := newClient(fake)
+
+	var wg sync.WaitGroup
+	bodies := make([][]byte, 5)
+	errs := make([]error, 5)
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			bodies[i], errs[i] = queryRank(context.Background(), client, "astro")
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("caller %d: Query() error = %v", i, err)
+		}
+		if string(bodies[i]) != string(bodies[0]) {
+			t.Errorf("caller %d got %s, want the shared response %s", i, bodies[i], bodies[0])
+		}
+	}
+
+	// One failed attempt and its retry, shared by every caller.
+	if calls := fake.Calls("GetTrailheadRank"); calls != 2 {
+		t.Errorf("fake got %d calls, want 2", calls)
+	}
+}
+
+func TestClientDoesNotCoalesceDifferentCallouts(t *testing.T) {
+	fake := newFake(t)
+	client := newClient(fake)
+
+	var wg sync.WaitGroup
+	for _, handle := range []string{"astro", "matruff"} {
+		wg.Add(1)
+		go func(handle string) {
+			defer wg.Done()
+			if _, err := queryRank(context.Background(), client, handle); err != nil {
+				t.Errorf("Query(%s) error = %v", handle, err)
+			}
+		}(handle)
+	}
+	wg.Wait()
+
+	if calls := fake.Calls("GetTrailheadRank"); calls != 2 {
+		t.Errorf("fake got %d calls, want one per Trailblazer", calls)
+	}
+}
+
+func TestClientFirstCallerGivingUpDoesNotFailOthers(t *testing.T) {
+	fake := newFake(t)
+	slowFirstAttempt(fake, 200*time.Millisecond)
+	client := newClient(fake)
+
+	firstCtx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := queryRank(firstCtx, client, "astro")
+		first <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	second := make(chan error, 1)
+	go func() {
+		_, err := queryRank(context.Background(), client, "astro")
+		second <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-first:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("first Query() error = %v, want context.Canceled", err)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("first Query() still waiting after its context was cancelled")
+	}
+
+	if err := <-second; err != nil {
+		t.Fatalf("second Query() error = %v, want the shared callout to carry on", err)
+	}
+	if calls := fake.Calls("GetTrailheadRank"); calls != 2 {
+		t.Errorf("fake got %d calls, want 2", calls)
+	}
+}
+
+func TestClientWaiterGivesUpOnItsOwnContext(t *testing.T) {
+	fake := newFake(t)
+	slowFirstAttempt(fake, 300*time.Millisecond)
+	client := newClient(fake)
+
+	first := make(chan error, 1)
+	go func() {
+		_, err := queryRank(context.Background(), client, "astro")
+		first <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := queryRank(ctx, client, "astro")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("waiting Query() error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Errorf("waiting Query() returned after %v, want it to stop at its own 50ms deadline", elapsed)
+	}
+
+	if err := <-first; err != nil {
+		t.Errorf("first Query() error = %v, want success", err)
+	}
+}
@@ -0,0 +1,148 @@
+package trailhead
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Identity pairs a Trailblazer's handle (the slug in their profile URL) with their Salesforce
+// user ID.
+type Identity struct {
+	Handle string
+	UserID string
+}
+
+// IdentityLookup fetches the Identity for a handle or user ID from Trailhead.
+type IdentityLookup func(ctx context.Context, handleOrID string) (Identity, error)
+
+// IsUserID reports whether the given value looks like a Salesforce user ID rather than a handle.
+// User IDs start with the 005 key prefix and are 15 or 18 characters long.
+func IsUserID(s string) bool {
+	return strings.HasPrefix(s, "005") && (len(s) == 15 || len(s) == 18)
+}
+
+// Resolver maps between Trailblazer handles and user IDs, caching each mapping for TTL so
+// repeated requests for the same Trailblazer don't need another lookup. Lookups of user IDs in the
+// cache are reported to Observer, if set.
+type Resolver struct {
+	Observer Observer
+
+	lookup IdentityLookup
+	ttl    time.Duration
+
+	mu       sync.Mutex
+	byHandle map[string]cachedIdentity
+	byUserID map[string]cachedIdentity
+}
+
+type cachedIdentity struct {
+	identity Identity
+	expires  time.Time
+}
+
+// NewResolver returns a Resolver using lookup to find identities it hasn't cached yet.
+func NewResolver(lookup IdentityLookup, ttl time.Duration) *Resolver {
+	return &Resolver{
+		lookup:   lookup,
+		ttl:      ttl,
+		byHandle: map[string]cachedIdentity{},
+		byUserID: map[string]cachedIdentity{},
+	}
+}
+
+// Resolve returns the Identity for a handle or user ID. User IDs are looked up and cached, while
+// handles are returned as is (filled in from the cache when possible) since they can be used to
+// query Trailhead directly.
+func (r *Resolver) Resolve(ctx context.Context, handleOrID string) (Identity, error) {
+	if !IsUserID(handleOrID) {
+		if identity, ok := r.cached(r.byHandle, strings.ToLower(handleOrID)); ok {
+			return identity, nil
+		}
+
+		return Identity{Handle: handleOrID}, nil
+	}
+
+	key := normalizeUserID(handleOrID)
+	identity, ok := r.cached(r.byUserID, key)
+	if r.Observer != nil {
+		r.Observer.ObserveCacheLookup(ctx, "identity", ok)
+	}
+	if ok {
+		return identity, nil
+	}
+
+	identity, err := r.lookup(ctx, handleOrID)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	r.Remember(identity)
+
+	return identity, nil
+}
+
+// Remember caches a handle and user ID pair that was learned elsewhere, i.e. from a profile
+// lookup, so later requests using the user ID don't need a separate lookup.
+func (r *Resolver) Remember(identity Identity) {
+	if identity.Handle == "" || !IsUserID(identity.UserID) {
+		return
+	}
+
+	entry := cachedIdentity{identity: identity, expires: time.Now().Add(r.ttl)}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.byHandle[strings.ToLower(identity.Handle)] = entry
+	r.byUserID[normalizeUserID(identity.UserID)] = entry
+}
+
+// Forget drops the cached identity for a handle, i.e. once the Trailblazer has renamed it, so the
+// handle no longer resolves to their user ID.
+func (r *Resolver) Forget(handle string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := strings.ToLower(handle)
+	entry, ok := r.byHandle[key]
+	if !ok {
+		return
+	}
+
+	delete(r.byHandle, key)
+
+	userID := normalizeUserID(entry.identity.UserID)
+	if cached, ok := r.byUserID[userID]; ok && strings.EqualFold(cached.identity.Handle, handle) {
+		delete(r.byUserID, userID)
+	}
+}
+
+// cached returns an unexpired Identity from the given cache.
+func (r *Resolver) cached(cache map[string]cachedIdentity, key string) (Identity, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, ok := cache[key]
+	if !ok {
+		return Identity{}, false
+	}
+
+	if time.Now().After(entry.expires) {
+		delete(cache, key)
+		return Identity{}, false
+	}
+
+	return entry.identity, true
+}
+
+// normalizeUserID returns the case-sensitive 15 character form of a user ID so 15 and 18
+// character IDs for the same user share a cache entry.
+func normalizeUserID(userID string) string {
+	if len(userID) > 15 {
+		return userID[:15]
+	}
+
+	return userID
+}
@@ -0,0 +1,48 @@
+package trailhead
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestResolverForget(t *testing.T) {
+	resolver := NewResolver(func(ctx context.Context, handleOrID string) (Identity, error) {
+		return Identity{Handle: "renamed", UserID: handleOrID}, nil
+	}, time.Hour)
+	resolver.Remember(Identity{Handle: "Astro", UserID: "005000000000001AAA"})
+
+	resolver.Forget("astro")
+
+	identity, err := resolver.Resolve(context.Background(), "astro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.UserID != "" {
+		t.Errorf("Resolve(astro) = %+v after Forget, want no cached user ID", identity)
+	}
+
+	identity, err = resolver.Resolve(context.Background(), "005000000000001AAA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Handle != "renamed" {
+		t.Errorf("Resolve(user ID) = %+v after Forget, want a fresh lookup", identity)
+	}
+}
+
+func TestResolverForgetKeepsNewerHandle(t *testing.T) {
+	resolver := NewResolver(nil, time.Hour)
+	resolver.Remember(Identity{Handle: "astro", UserID: "005000000000001AAA"})
+	resolver.Remember(Identity{Handle: "astro-nomer", UserID: "005000000000001AAA"})
+
+	resolver.Forget("astro")
+
+	identity, err := resolver.Resolve(context.Background(), "005000000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Handle != "astro-nomer" {
+		t.Errorf("Resolve(user ID) = %+v, want the new handle to stay cached", identity)
+	}
+}
@@ -0,0 +1,299 @@
+package trailhead
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// ErrProfileLayoutChanged is returned by ParseProfilePage when none of the extraction strategies
+// could find profile data on the page. This usually means Trailhead changed the page layout.
+var ErrProfileLayoutChanged = errors.New("trailblazer profile page layout changed")
+
+// profileStrategy extracts a Profile from the raw HTML of a Trailblazer profile page.
+type profileStrategy struct {
+	name    string
+	extract func(page []byte) (Profile, bool)
+}
+
+// profileStrategies are tried in order, the first one that finds profile data wins.
+var profileStrategies = []profileStrategy{
+	{"inline script variable", extractInlineProfile},
+	{"__NEXT_DATA__ script", extractNextDataProfile},
+	{"JSON-LD", extractJSONLDProfile},
+	{"OpenGraph meta", extractOpenGraphProfile},
+}
+
+var (
+	inlineProfileRe = regexp.MustCompile(`(?:var|let|const)\s+profile\s*=\s*`)
+	scriptTagRe     = regexp.MustCompile(`(?is)<script([^>]*)>(.*?)</script>`)
+	metaTagRe       = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	attributeRe     = regexp.MustCompile(`(?is)([a-z:_-]+)\s*=\s*("[^"]*"|'[^']*')`)
+)
+
+// ParseProfilePage extracts Trailblazer profile data from the HTML of a profile page. Several
+// strategies are tried in turn so a change to one part of the page doesn't silently blank out
+// the profile. Returns an error wrapping ErrProfileLayoutChanged if every strategy fails.
+func ParseProfilePage(page []byte) (Profile, error) {
+	tried := make([]string, 0, len(profileStrategies))
+
+	for _, strategy := range profileStrategies {
+		if profile, ok := strategy.extract(page); ok {
+			return profile, nil
+		}
+
+		tried = append(tried, strategy.name)
+	}
+
+	return Profile{}, fmt.Errorf("%w (tried %s)", ErrProfileLayoutChanged, strings.Join(tried, ", "))
+}
+
+// hasProfileData reports whether enough of a Profile was populated to be worth returning.
+func hasProfileData(p Profile) bool {
+	return p.ID != "" || p.Username != "" || p.FirstName != "" || p.LastName != ""
+}
+
+// extractInlineProfile reads the `var profile = {...};` assignment embedded in a page script.
+func extractInlineProfile(page []byte) (Profile, bool) {
+	loc := inlineProfileRe.FindIndex(page)
+	if loc == nil {
+		return Profile{}, false
+	}
+
+	// Decode straight from the assignment so semicolons inside strings don't cut the object short.
+	var profile Profile
+	if err := json.NewDecoder(bytes.NewReader(page[loc[1]:])).Decode(&profile); err != nil {
+		return Profile{}, false
+	}
+
+	return profile, hasProfileData(profile)
+}
+
+// extractNextDataProfile searches `<script id="__NEXT_DATA__">` JSON blobs for an object that
+// looks like a profile, skipping blobs that aren't valid JSON or hold no profile.
+func extractNextDataProfile(page []byte) (Profile, bool) {
+	for _, script := range scriptTagRe.FindAllSubmatch(page, -1) {
+		if parseAttributes(script[1])["id"] != "__NEXT_DATA__" {
+			continue
+		}
+
+		var data interface{}
+		if err := json.Unmarshal(bytes.TrimSpace(script[2]), &data); err != nil {
+			continue
+		}
+
+		found := findProfileObject(data)
+		if found == nil {
+			continue
+		}
+
+		raw, err := json.Marshal(found)
+		if err != nil {
+			continue
+		}
+
+		var profile Profile
+		if err := json.Unmarshal(raw, &profile); err != nil {
+			continue
+		}
+
+		if hasProfileData(profile) {
+			return profile, true
+		}
+	}
+
+	return Profile{}, false
+}
+
+// findProfileObject walks decoded JSON depth first and returns the first object with both
+// firstName and lastName keys.
+func findProfileObject(v interface{}) map[string]interface{} {
+	switch node := v.(type) {
+	case map[string]interface{}:
+		_, hasFirst := node["firstName"]
+		_, hasLast := node["lastName"]
+		if hasFirst && hasLast {
+			return node
+		}
+
+		for _, child := range node {
+			if found := findProfileObject(child); found != nil {
+				return found
+			}
+		}
+	case []interface{}:
+		for _, child := range node {
+			if found := findProfileObject(child); found != nil {
+				return found
+			}
+		}
+	}
+
+	return nil
+}
+
+// jsonLDPerson is the subset of a schema.org Person used on profile pages.
+type jsonLDPerson struct {
+	Type       interface{} `json:"@type"`
+	Identifier string      `json:"identifier"`
+	URL        string      `json:"url"`
+	Name       string      `json:"name"`
+	GivenName  string      `json:"givenName"`
+	FamilyName string      `json:"familyName"`
+	JobTitle   string      `json:"jobTitle"`
+	Image      interface{} `json:"image"`
+	WorksFor   struct {
+		Name string `json:"name"`
+		URL  string `json:"url"`
+	} `json:"worksFor"`
+	Description string `json:"description"`
+}
+
+// extractJSONLDProfile reads a schema.org Person from `<script type="application/ld+json">`.
+func extractJSONLDProfile(page []byte) (Profile, bool) {
+	for _, script := range scriptTagRe.FindAllSubmatch(page, -1) {
+		if !strings.EqualFold(parseAttributes(script[1])["type"], "application/ld+json") {
+			continue
+		}
+
+		body := bytes.TrimSpace(script[2])
+
+		// A JSON-LD block can hold a single node or a list of them.
+		var people []jsonLDPerson
+		if len(body) > 0 && body[0] == '[' {
+			if err := json.Unmarshal(body, &people); err != nil {
+				continue
+			}
+		} else {
+			var person jsonLDPerson
+			if err := json.Unmarshal(body, &person); err != nil {
+				continue
+			}
+			people = append(people, person)
+		}
+
+		for _, person := range people {
+			if !isJSONLDType(person.Type, "Person") {
+				continue
+			}
+
+			profile := Profile{
+				ID:         person.Identifier,
+				FirstName:  person.GivenName,
+				LastName:   person.FamilyName,
+				ProfileURL: person.URL,
+				Title:      person.JobTitle,
+				PhotoURL:   jsonLDImageURL(person.Image),
+				Bio:        person.Description,
+			}
+			profile.Company.Name = person.WorksFor.Name
+			profile.Company.Website = person.WorksFor.URL
+
+			if profile.FirstName == "" && profile.LastName == "" {
+				profile.FirstName, profile.LastName = splitName(person.Name)
+			}
+
+			if hasProfileData(profile) {
+				return profile, true
+			}
+		}
+	}
+
+	return Profile{}, false
+}
+
+// isJSONLDType checks a JSON-LD @type value, which may be a string or a list of strings.
+func isJSONLDType(v interface{}, want string) bool {
+	switch t := v.(type) {
+	case string:
+		return t == want
+	case []interface{}:
+		for _, item := range t {
+			if s, ok := item.(string); ok && s == want {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// jsonLDImageURL returns the URL of a JSON-LD image, which may be a string or an ImageObject.
+func jsonLDImageURL(v interface{}) string {
+	switch image := v.(type) {
+	case string:
+		return image
+	case map[string]interface{}:
+		if url, ok := image["url"].(string); ok {
+			return url
+		}
+	}
+
+	return ""
+}
+
+// extractOpenGraphProfile builds a Profile from OpenGraph `og:` and `profile:` meta tags. This is
+// the least detailed strategy so it is tried last.
+func extractOpenGraphProfile(page []byte) (Profile, bool) {
+	meta := map[string]string{}
+
+	for _, tag := range metaTagRe.FindAll(page, -1) {
+		attributes := parseAttributes(tag)
+		property := attributes["property"]
+		if property == "" {
+			property = attributes["name"]
+		}
+
+		if strings.HasPrefix(property, "og:") || strings.HasPrefix(property, "profile:") {
+			meta[property] = attributes["content"]
+		}
+	}
+
+	// Generic pages (e.g. a "not found" page) carry og:title too, so only trust profile pages.
+	if meta["og:type"] != "profile" && meta["profile:username"] == "" && meta["profile:first_name"] == "" {
+		return Profile{}, false
+	}
+
+	profile := Profile{
+		FirstName:  meta["profile:first_name"],
+		LastName:   meta["profile:last_name"],
+		Username:   meta["profile:username"],
+		ProfileURL: meta["og:url"],
+		PhotoURL:   meta["og:image"],
+		Bio:        meta["og:description"],
+	}
+
+	if profile.FirstName == "" && profile.LastName == "" {
+		// Titles are usually suffixed with the site name, e.g. "Jane Doe | Trailblazer".
+		title, _, _ := strings.Cut(meta["og:title"], "|")
+		profile.FirstName, profile.LastName = splitName(title)
+	}
+
+	return profile, hasProfileData(profile)
+}
+
+// parseAttributes returns the attributes of an HTML tag keyed by lower case name.
+func parseAttributes(tag []byte) map[string]string {
+	attributes := map[string]string{}
+
+	for _, match := range attributeRe.FindAllSubmatch(tag, -1) {
+		value := string(match[2])
+		attributes[strings.ToLower(string(match[1]))] = html.UnescapeString(value[1 : len(value)-1])
+	}
+
+	return attributes
+}
+
+// splitName splits a display name into first and last name on the first space.
+func splitName(name string) (string, string) {
+	name = strings.TrimSpace(name)
+	if first, last, found := strings.Cut(name, " "); found {
+		return first, strings.TrimSpace(last)
+	}
+
+	return name, ""
+}
@@ -0,0 +1,71 @@
+package trailhead
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func TestParseProfilePage(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+		wantErr error
+	}{
+		{name: "inline script variable", fixture: "inline_script"},
+		{name: "next data, skipping an invalid block", fixture: "next_data"},
+		{name: "json-ld person", fixture: "json_ld"},
+		{name: "open graph tags", fixture: "open_graph"},
+		{name: "changed layout", fixture: "changed_layout", wantErr: ErrProfileLayoutChanged},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := os.ReadFile(filepath.Join("testdata", tt.fixture+".html"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			profile, err := ParseProfilePage(page)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ParseProfilePage() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseProfilePage() error = %v", err)
+			}
+
+			golden := filepath.Join("testdata", tt.fixture+".golden.json")
+			if *update {
+				raw, err := json.MarshalIndent(profile, "", "  ")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(golden, append(raw, '\n'), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			raw, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want Profile
+			if err := json.Unmarshal(raw, &want); err != nil {
+				t.Fatalf("parsing %s: %v", golden, err)
+			}
+
+			if !reflect.DeepEqual(profile, want) {
+				t.Errorf("ParseProfilePage() = %+v, want %+v", profile, want)
+			}
+		})
+	}
+}
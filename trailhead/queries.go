@@ -1,5 +1,45 @@
 package trailhead
 
+// GetProfileQuery returns GraphQL query for PublicProfile
+func GetProfileQuery() string {
+	return `
+        fragment PublicProfile on PublicProfile {
+            __typename
+            id
+            username
+            firstName
+            lastName
+            title
+            role
+            relationshipToSalesforce
+            photoUrl
+            backgroundImageUrl
+            bio
+            linkedinHandle
+            websiteUrl
+            company {
+                name
+                size
+                website
+            }
+            address {
+                state
+                country
+            }
+        }
+
+        query GetTrailheadProfile($slug: String, $userId: String, $hasSlug: Boolean!) {
+            profile(slug: $slug, userId: $userId) @include(if: $hasSlug) {
+                ... on PublicProfile {
+                    ...PublicProfile
+                }
+                ... on PrivateProfile {
+                    __typename
+                }
+            }
+        }`
+}
+
 // GetRankQuery returns GraphQL query for TrailheadRank
 func GetRankQuery() string {
 	return `
@@ -0,0 +1,190 @@
+package trailhead_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/meruff/go-trailhead-leaderboard-api/trailhead"
+)
+
+// queryBadges asks for a page of a Trailblazer's badges.
+func queryBadges(t *testing.T, client *trailhead.Client, handle string, request trailhead.BadgeRequest) trailhead.Badges {
+	t.Helper()
+
+	payload := trailhead.GetGraphqlPayload(
+		"GetTrailheadBadges", handle, trailhead.GetBadgesFilterPayload(handle, request), trailhead.GetBadgesQuery(),
+	)
+	body, err := client.Query(context.Background(), "GetTrailheadBadges", payload)
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+
+	var badges trailhead.Badges
+	if err := json.Unmarshal(body, &badges); err != nil {
+		t.Fatalf("decoding badges: %v", err)
+	}
+
+	return badges
+}
+
+// queryProfile asks for a Trailblazer's profile by handle or user ID.
+func queryProfile(t *testing.T, client *trailhead.Client, handleOrID string) trailhead.PublicProfile {
+	t.Helper()
+
+	var variables string
+	if trailhead.IsUserID(handleOrID) {
+		variables = trailhead.GetUserIDPayload(handleOrID)
+	}
+	payload := trailhead.GetGraphqlPayload("GetTrailheadProfile", handleOrID, variables, trailhead.GetProfileQuery())
+
+	body, err := client.Query(context.Background(), "GetTrailheadProfile", payload)
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+
+	var profile trailhead.PublicProfile
+	if err := json.Unmarshal(body, &profile); err != nil {
+		t.Fatalf("decoding profile: %v", err)
+	}
+
+	return profile
+}
+
+func TestBadgesPageThroughEndCursors(t *testing.T) {
+	tests := []struct {
+		name      string
+		filter    string
+		count     int
+		wantPages []int
+	}{
+		{name: "all badges", count: 5, wantPages: []int{5, 5, 2}},
+		{name: "exact pages", count: 4, wantPages: []int{4, 4, 4}},
+		{name: "one page", count: 20, wantPages: []int{12}},
+		{name: "filtered", filter: "module", count: 3, wantPages: []int{3, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newClient(newFake(t))
+
+			var pages []int
+			seen := map[string]bool{}
+			request := trailhead.BadgeRequest{Filter: tt.filter, Count: tt.count}
+
+			for {
+				badges := queryBadges(t, client, "matruff", request)
+				awards := badges.Data.Profile.EarnedAwards
+
+				if got, want := awards.PageInfo.HasPreviousPage, len(pages) > 0; got != want {
+					t.Errorf("page %d HasPreviousPage = %v, want %v", len(pages)+1, got, want)
+				}
+				for _, edge := range awards.Edges {
+					if seen[edge.Node.ID] {
+						t.Errorf("badge %s returned twice", edge.Node.ID)
+					}
+					seen[edge.Node.ID] = true
+
+					if tt.filter != "" && !strings.EqualFold(edge.Node.Award.Type, tt.filter) {
+						t.Errorf("badge %s has type %s, want %s", edge.Node.ID, edge.Node.Award.Type, tt.filter)
+					}
+				}
+				pages = append(pages, len(awards.Edges))
+
+				if !awards.PageInfo.HasNextPage {
+					break
+				}
+				if len(pages) > len(tt.wantPages) {
+					t.Fatalf("still paging after %d pages", len(pages))
+				}
+				request.After = awards.PageInfo.EndCursor
+			}
+
+			if len(pages) != len(tt.wantPages) {
+				t.Fatalf("got pages of %v badges, want %v", pages, tt.wantPages)
+			}
+			for i := range pages {
+				if pages[i] != tt.wantPages[i] {
+					t.Fatalf("got pages of %v badges, want %v", pages, tt.wantPages)
+				}
+			}
+		})
+	}
+}
+
+func TestBadgesPastTheLastPage(t *testing.T) {
+	client := newClient(newFake(t))
+
+	first := queryBadges(t, client, "astro", trailhead.BadgeRequest{Count: 3})
+	awards := first.Data.Profile.EarnedAwards
+	if len(awards.Edges) != 3 || awards.PageInfo.HasNextPage {
+		t.Fatalf("first page has %d badges, HasNextPage %v, want all 3 and no next page",
+			len(awards.Edges), awards.PageInfo.HasNextPage)
+	}
+
+	last := queryBadges(t, client, "astro", trailhead.BadgeRequest{Count: 3, After: awards.PageInfo.EndCursor})
+	awards = last.Data.Profile.EarnedAwards
+	if len(awards.Edges) != 0 || awards.PageInfo.HasNextPage || awards.PageInfo.EndCursor != "" {
+		t.Errorf("page after the last has %d badges, HasNextPage %v, EndCursor %q, want none",
+			len(awards.Edges), awards.PageInfo.HasNextPage, awards.PageInfo.EndCursor)
+	}
+}
+
+func TestPrivateProfiles(t *testing.T) {
+	for _, handleOrID := range []string{"hidden", "0055e00000HiddnQAA"} {
+		t.Run(handleOrID, func(t *testing.T) {
+			client := newClient(newFake(t))
+
+			profile := queryProfile(t, client, handleOrID).Data.Profile
+			if profile.Typename != "PrivateProfile" {
+				t.Errorf("Typename = %q, want PrivateProfile", profile.Typename)
+			}
+			if profile.Profile != (trailhead.Profile{}) {
+				t.Errorf("Profile = %+v, want nothing for a private profile", profile.Profile)
+			}
+
+			if trailhead.IsUserID(handleOrID) {
+				return // Badges are only ever asked for by handle, once a user ID is resolved.
+			}
+			badges := queryBadges(t, client, handleOrID, trailhead.BadgeRequest{Count: 8})
+			if typename := badges.Data.Profile.Typename; typename != "PrivateProfile" {
+				t.Errorf("badges Typename = %q, want PrivateProfile", typename)
+			}
+			if edges := badges.Data.Profile.EarnedAwards.Edges; len(edges) != 0 {
+				t.Errorf("got %d badges for a private profile, want none", len(edges))
+			}
+		})
+	}
+}
+
+func TestPrivateProfilePageHasNoProfileData(t *testing.T) {
+	client := newClient(newFake(t))
+
+	page, err := client.ProfilePage(context.Background(), "hidden")
+	if err != nil {
+		t.Fatalf("ProfilePage() error = %v", err)
+	}
+
+	if _, err := trailhead.ParseProfilePage(page); !errors.Is(err, trailhead.ErrProfileLayoutChanged) {
+		t.Errorf("ParseProfilePage() error = %v, want ErrProfileLayoutChanged", err)
+	}
+}
+
+func TestPublicAndMissingProfiles(t *testing.T) {
+	client := newClient(newFake(t))
+
+	public := queryProfile(t, client, "0053k00000AstroQAA").Data.Profile
+	if public.Typename != "PublicProfile" || public.Handle() != "astro" {
+		t.Errorf("profile by user ID = %q %q, want PublicProfile astro", public.Typename, public.Handle())
+	}
+
+	if missing := queryProfile(t, client, "nobody").Data.Profile; missing.Typename != "" {
+		t.Errorf("Typename = %q for a missing profile, want none", missing.Typename)
+	}
+
+	if _, err := client.ProfilePage(context.Background(), "nobody"); !errors.Is(err, trailhead.ErrProfilePageNotFound) {
+		t.Errorf("ProfilePage() error = %v, want ErrProfilePageNotFound", err)
+	}
+}
@@ -0,0 +1,57 @@
+package trailhead
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer starts spans for callouts using the global tracer provider, which does nothing unless the
+// app configures one.
+var tracer = otel.Tracer("github.com/meruff/go-trailhead-leaderboard-api/trailhead")
+
+// startCalloutSpan starts the span covering a callout to Trailhead, retries and all.
+func startCalloutSpan(ctx context.Context, upstream, operation, slug string) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{
+		attribute.String("trailhead.upstream", upstream),
+		attribute.String("trailhead.operation", operation),
+	}
+	if slug != "" {
+		attrs = append(attrs, attribute.String("trailhead.slug", slug))
+	}
+
+	return tracer.Start(ctx, "trailhead "+operation, trace.WithAttributes(attrs...))
+}
+
+// endSpan records how a span's work went, including the HTTP status if Trailhead responded with
+// an error, and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) {
+			span.SetAttributes(attribute.Int("http.response.status_code", statusErr.StatusCode))
+		}
+
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+// payloadSlug returns the Trailblazer handle a GraphQL payload queries, or "" if it has none.
+func payloadSlug(payload string) string {
+	var body struct {
+		Variables struct {
+			Slug string `json:"slug"`
+		} `json:"variables"`
+	}
+	json.Unmarshal([]byte(payload), &body)
+
+	return body.Variables.Slug
+}
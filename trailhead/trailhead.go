@@ -1,7 +1,9 @@
 package trailhead
 
 import (
-	"strconv"
+	"encoding/json"
+	"net/url"
+	"path"
 )
 
 // ProfileReturn represents the basic trailhead data returned via the Go API.
@@ -47,50 +49,78 @@ type Profile struct {
 	} `json:"address"`
 }
 
+// Handle returns the Trailblazer's handle, taken from their profile URL when available.
+func (p Profile) Handle() string {
+	if u, err := url.Parse(p.ProfileURL); err == nil && p.ProfileURL != "" {
+		if handle := path.Base(u.Path); handle != "/" && handle != "." {
+			return handle
+		}
+	}
+
+	return p.Username
+}
+
+// PublicProfile represents profile data returned from trailhead. Typename is "PrivateProfile"
+// when the Trailblazer has hidden their profile, in which case no other fields are set.
+type PublicProfile struct {
+	Data struct {
+		Profile struct {
+			Typename string `json:"__typename"`
+			Profile
+		} `json:"profile"`
+	} `json:"data"`
+}
+
 // Rank represents skill data returned from trailhead.
 type Rank struct {
 	Data struct {
 		Profile struct {
-			Typename       string `json:"__typename"`
-			TrailheadStats struct {
-				Typename            string `json:"__typename"`
-				EarnedPointsSum     int    `json:"earnedPointsSum"`
-				EarnedBadgesCount   int    `json:"earnedBadgesCount"`
-				CompletedTrailCount int    `json:"completedTrailCount"`
-				Rank                struct {
-					Typename            string `json:"__typename"`
-					Title               string `json:"title"`
-					RequiredPointsSum   int    `json:"requiredPointsSum"`
-					RequiredBadgesCount int    `json:"requiredBadgesCount"`
-					ImageURL            string `json:"imageUrl"`
-				} `json:"rank"`
-				NextRank interface{} `json:"nextRank"`
-			} `json:"trailheadStats"`
+			Typename       string         `json:"__typename"`
+			TrailheadStats TrailheadStats `json:"trailheadStats"`
 		} `json:"profile"`
 	} `json:"data"`
 }
 
+// TrailheadStats represents a Trailblazer's rank and totals. Used in Rank.
+type TrailheadStats struct {
+	Typename            string `json:"__typename"`
+	EarnedPointsSum     int    `json:"earnedPointsSum"`
+	EarnedBadgesCount   int    `json:"earnedBadgesCount"`
+	CompletedTrailCount int    `json:"completedTrailCount"`
+	Rank                struct {
+		Typename            string `json:"__typename"`
+		Title               string `json:"title"`
+		RequiredPointsSum   int    `json:"requiredPointsSum"`
+		RequiredBadgesCount int    `json:"requiredBadgesCount"`
+		ImageURL            string `json:"imageUrl"`
+	} `json:"rank"`
+	NextRank interface{} `json:"nextRank"`
+}
+
 // Skills represents skill data returned from trailhead.
 type Skills struct {
 	Data struct {
 		Profile struct {
-			Typename     string `json:"__typename"`
-			EarnedSkills []struct {
-				Typename               string `json:"__typename"`
-				EarnedPointsSum        int    `json:"earnedPointsSum"`
-				ID                     string `json:"id"`
-				ItemProgressEntryCount int    `json:"itemProgressEntryCount"`
-				Skill                  struct {
-					Typename string `json:"__typename"`
-					APIName  string `json:"apiName"`
-					ID       string `json:"id"`
-					Name     string `json:"name"`
-				} `json:"skill"`
-			} `json:"earnedSkills"`
+			Typename     string        `json:"__typename"`
+			EarnedSkills []EarnedSkill `json:"earnedSkills"`
 		} `json:"profile"`
 	} `json:"data"`
 }
 
+// EarnedSkill represents the points a Trailblazer has earned in a single skill. Used in Skills.
+type EarnedSkill struct {
+	Typename               string `json:"__typename"`
+	EarnedPointsSum        int    `json:"earnedPointsSum"`
+	ID                     string `json:"id"`
+	ItemProgressEntryCount int    `json:"itemProgressEntryCount"`
+	Skill                  struct {
+		Typename string `json:"__typename"`
+		APIName  string `json:"apiName"`
+		ID       string `json:"id"`
+		Name     string `json:"name"`
+	} `json:"skill"`
+}
+
 // CertificationsReturn represents the certification data returned via the Go API.
 type CertificationsReturn struct {
 	Error              string
@@ -135,61 +165,45 @@ type Certifications struct {
 					Name     string `json:"name"`
 					Logo     string `json:"logo"`
 				} `json:"brands"`
-				Certifications []struct {
-					Cta struct {
-						Typename string `json:"__typename"`
-						Label    string `json:"label"`
-						URL      string `json:"url"`
-					} `json:"cta"`
-					DateCompleted      string `json:"dateCompleted"`
-					DateExpired        any    `json:"dateExpired"`
-					DownloadLogoURL    string `json:"downloadLogoUrl"`
-					LogoURL            string `json:"logoUrl"`
-					InfoURL            string `json:"infoUrl"`
-					MaintenanceDueDate string `json:"maintenanceDueDate"`
-					Product            string `json:"product"`
-					PublicDescription  string `json:"publicDescription"`
-					Status             struct {
-						Typename string `json:"__typename"`
-						Title    string `json:"title"`
-						Expired  bool   `json:"expired"`
-						Date     string `json:"date"`
-						Color    string `json:"color"`
-						Order    int    `json:"order"`
-					} `json:"status"`
-					Title string `json:"title"`
-				} `json:"certifications"`
+				Certifications []EarnedCertification `json:"certifications"`
 			} `json:"credential"`
 		} `json:"profile"`
 	} `json:"data"`
 }
 
+// EarnedCertification represents a single certification record. Used in Certifications.
+type EarnedCertification struct {
+	Cta struct {
+		Typename string `json:"__typename"`
+		Label    string `json:"label"`
+		URL      string `json:"url"`
+	} `json:"cta"`
+	DateCompleted      string `json:"dateCompleted"`
+	DateExpired        any    `json:"dateExpired"`
+	DownloadLogoURL    string `json:"downloadLogoUrl"`
+	LogoURL            string `json:"logoUrl"`
+	InfoURL            string `json:"infoUrl"`
+	MaintenanceDueDate string `json:"maintenanceDueDate"`
+	Product            string `json:"product"`
+	PublicDescription  string `json:"publicDescription"`
+	Status             struct {
+		Typename string `json:"__typename"`
+		Title    string `json:"title"`
+		Expired  bool   `json:"expired"`
+		Date     string `json:"date"`
+		Color    string `json:"color"`
+		Order    int    `json:"order"`
+	} `json:"status"`
+	Title string `json:"title"`
+}
+
 // Badges represents skill data returned from trailhead.
 type Badges struct {
 	Data struct {
 		Profile struct {
 			Typename     string `json:"__typename"`
 			EarnedAwards struct {
-				Edges []struct {
-					Node struct {
-						Typename string `json:"__typename"`
-						ID       string `json:"id"`
-						Award    struct {
-							Typename string `json:"__typename"`
-							ID       string `json:"id"`
-							Title    string `json:"title"`
-							Type     string `json:"type"`
-							Icon     string `json:"icon"`
-							Content  struct {
-								Typename    string `json:"__typename"`
-								WebURL      string `json:"webUrl"`
-								Description string `json:"description"`
-							} `json:"content"`
-						} `json:"award"`
-						EarnedAt        string `json:"earnedAt"`
-						EarnedPointsSum string `json:"earnedPointsSum"`
-					} `json:"node"`
-				} `json:"edges"`
+				Edges    []EarnedAwardEdge `json:"edges"`
 				PageInfo struct {
 					Typename        string `json:"__typename"`
 					EndCursor       string `json:"endCursor"`
@@ -202,6 +216,31 @@ type Badges struct {
 	} `json:"data"`
 }
 
+// EarnedAwardEdge wraps a badge in a page of badges. Used in Badges.
+type EarnedAwardEdge struct {
+	Node EarnedAward `json:"node"`
+}
+
+// EarnedAward represents a single badge the Trailblazer has earned. Used in Badges.
+type EarnedAward struct {
+	Typename string `json:"__typename"`
+	ID       string `json:"id"`
+	Award    struct {
+		Typename string `json:"__typename"`
+		ID       string `json:"id"`
+		Title    string `json:"title"`
+		Type     string `json:"type"`
+		Icon     string `json:"icon"`
+		Content  struct {
+			Typename    string `json:"__typename"`
+			WebURL      string `json:"webUrl"`
+			Description string `json:"description"`
+		} `json:"content"`
+	} `json:"award"`
+	EarnedAt        string `json:"earnedAt"`
+	EarnedPointsSum string `json:"earnedPointsSum"`
+}
+
 // BadgeRequest represents a request to the /badges trailhead endpoint.
 type BadgeRequest struct {
 	Filter string `json:"filter"`
@@ -209,47 +248,61 @@ type BadgeRequest struct {
 	Count  int    `json:"count"`
 }
 
-// GetGraphqlPayload returns a JSON string to use in Trailhead graphql callouts.
+// GetGraphqlPayload returns a JSON string to use in Trailhead graphql callouts. variables is a
+// `"variables": {...}` member as returned by GetBadgesFilterPayload or GetUserIDPayload, or "" to
+// query the Trailblazer with the handle userID. Every value is marshalled so it is always escaped.
 func GetGraphqlPayload(operationName string, userID string, variables string, query string) string {
-	var variablesJsonString string
-
-	if variables != "" {
-		variablesJsonString = variables
-	} else {
-		variablesJsonString = `"variables": {
-    		"hasSlug": true,
-    		"slug": "` + userID + `"
-  		}`
+	if variables == "" {
+		variables = variablesPayload(struct {
+			HasSlug bool   `json:"hasSlug"`
+			Slug    string `json:"slug"`
+		}{HasSlug: true, Slug: userID})
 	}
 
-	return `{
-		"operationName": "` + operationName + `",
-  		` + variablesJsonString + `,
-  		"query": "` + query + `"
-	}`
+	name, _ := json.Marshal(operationName)
+	text, _ := json.Marshal(query)
+
+	return `{"operationName": ` + string(name) + `, ` + variables + `, "query": ` + string(text) + `}`
 }
 
-// GetBadgesFilterPayload returns a variables json string to be used on the GraphQL callout.
+// GetBadgesFilterPayload returns a variables json string to be used on the GraphQL callout. An
+// empty After or Filter is sent as null.
 func GetBadgesFilterPayload(userID string, badgeFilters BadgeRequest) string {
-	var afterLine, filterLine string
+	return variablesPayload(struct {
+		Count   int     `json:"count"`
+		After   *string `json:"after"`
+		Filter  *string `json:"filter"`
+		HasSlug bool    `json:"hasSlug"`
+		Slug    string  `json:"slug"`
+	}{
+		Count:   badgeFilters.Count,
+		After:   nullIfEmpty(badgeFilters.After),
+		Filter:  nullIfEmpty(badgeFilters.Filter),
+		HasSlug: true,
+		Slug:    userID,
+	})
+}
 
-	if badgeFilters.After != "" {
-		afterLine = `"after": "` + badgeFilters.After + `",`
-	} else {
-		afterLine = `"after": null,`
-	}
+// GetUserIDPayload returns a variables json string to look up a profile by Salesforce user ID
+// rather than by handle.
+func GetUserIDPayload(userID string) string {
+	return variablesPayload(struct {
+		HasSlug bool    `json:"hasSlug"`
+		Slug    *string `json:"slug"`
+		UserID  string  `json:"userId"`
+	}{HasSlug: true, UserID: userID})
+}
+
+// variablesPayload marshals the variables of a GraphQL callout into a `"variables": {...}` member.
+func variablesPayload(variables interface{}) string {
+	raw, _ := json.Marshal(variables)
+	return `"variables": ` + string(raw)
+}
 
-	if badgeFilters.Filter != "" {
-		filterLine = `"filter": "` + badgeFilters.Filter + `",`
-	} else {
-		filterLine = `"filter": null,`
+func nullIfEmpty(s string) *string {
+	if s == "" {
+		return nil
 	}
 
-	return `"variables": {
-		"count": ` + strconv.Itoa(badgeFilters.Count) + `,
-		` + afterLine + `
-		` + filterLine + `
-		"hasSlug": true,
-		"slug": "` + userID + `"
-	}`
+	return &s
 }
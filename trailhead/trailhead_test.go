@@ -0,0 +1,106 @@
+package trailhead
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestGetUserIDPayloadEscapesUserID(t *testing.T) {
+	userID := `005"}, "operationName": "evil`
+	payload := GetGraphqlPayload("GetTrailheadRank", userID, GetUserIDPayload(userID), "query")
+
+	var body struct {
+		OperationName string `json:"operationName"`
+		Variables     struct {
+			HasSlug bool    `json:"hasSlug"`
+			Slug    *string `json:"slug"`
+			UserID  string  `json:"userId"`
+		} `json:"variables"`
+	}
+	if err := json.Unmarshal([]byte(payload), &body); err != nil {
+		t.Fatalf("payload isn't valid JSON: %v\n%s", err, payload)
+	}
+
+	if body.OperationName != "GetTrailheadRank" {
+		t.Errorf("operationName = %q, want GetTrailheadRank", body.OperationName)
+	}
+	if body.Variables.UserID != userID {
+		t.Errorf("userId = %q, want %q", body.Variables.UserID, userID)
+	}
+	if !body.Variables.HasSlug || body.Variables.Slug != nil {
+		t.Errorf("hasSlug = %v, slug = %v, want true and null", body.Variables.HasSlug, body.Variables.Slug)
+	}
+}
+
+func TestGraphqlPayloadsEscapeVariables(t *testing.T) {
+	evil := `x", "hasSlug": false, "slug": "victim`
+
+	tests := []struct {
+		name       string
+		payload    string
+		wantSlug   string
+		wantAfter  *string
+		wantFilter *string
+	}{
+		{
+			name:     "handle",
+			payload:  GetGraphqlPayload("GetTrailheadRank", evil, "", GetRankQuery()),
+			wantSlug: evil,
+		},
+		{
+			name: "badge filters",
+			payload: GetGraphqlPayload("GetTrailheadBadges", evil,
+				GetBadgesFilterPayload(evil, BadgeRequest{After: evil, Filter: evil, Count: 8}), GetBadgesQuery()),
+			wantSlug:   evil,
+			wantAfter:  &evil,
+			wantFilter: &evil,
+		},
+		{
+			name: "empty badge filters",
+			payload: GetGraphqlPayload("GetTrailheadBadges", "astro",
+				GetBadgesFilterPayload("astro", BadgeRequest{Count: 8}), GetBadgesQuery()),
+			wantSlug: "astro",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body struct {
+				Query     string `json:"query"`
+				Variables struct {
+					HasSlug bool    `json:"hasSlug"`
+					Slug    string  `json:"slug"`
+					After   *string `json:"after"`
+					Filter  *string `json:"filter"`
+				} `json:"variables"`
+			}
+			if err := json.Unmarshal([]byte(tt.payload), &body); err != nil {
+				t.Fatalf("payload isn't valid JSON: %v\n%s", err, tt.payload)
+			}
+
+			if !body.Variables.HasSlug || body.Variables.Slug != tt.wantSlug {
+				t.Errorf("hasSlug = %v, slug = %q, want true and %q", body.Variables.HasSlug, body.Variables.Slug, tt.wantSlug)
+			}
+			if !equalPtr(body.Variables.After, tt.wantAfter) {
+				t.Errorf("after = %v, want %v", body.Variables.After, tt.wantAfter)
+			}
+			if !equalPtr(body.Variables.Filter, tt.wantFilter) {
+				t.Errorf("filter = %v, want %v", body.Variables.Filter, tt.wantFilter)
+			}
+			if body.Query == "" {
+				t.Error("query is empty")
+			}
+			if slug := payloadSlug(tt.payload); slug != tt.wantSlug {
+				t.Errorf("payloadSlug() = %q, want %q", slug, tt.wantSlug)
+			}
+		})
+	}
+}
+
+func equalPtr(got, want *string) bool {
+	if got == nil || want == nil {
+		return got == want
+	}
+
+	return *got == *want
+}
@@ -0,0 +1,69 @@
+package trailheadtest
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/meruff/go-trailhead-leaderboard-api/trailhead"
+)
+
+// Trailblazer is the data the fake serves for one Trailblazer. Profile.ID is the user ID profiles
+// can be looked up by. A private Trailblazer's profile is hidden and none of their data is
+// served. Badges are listed most recently earned first, as Trailhead pages through them.
+type Trailblazer struct {
+	Handle         string                          `json:"handle"`
+	Private        bool                            `json:"private"`
+	Profile        trailhead.Profile               `json:"profile"`
+	Stats          trailhead.TrailheadStats        `json:"stats"`
+	Skills         []trailhead.EarnedSkill         `json:"skills"`
+	Certifications []trailhead.EarnedCertification `json:"certifications"`
+	Badges         []trailhead.EarnedAward         `json:"badges"`
+}
+
+//go:embed fixtures/trailblazers.json
+var defaultFixtures []byte
+
+// DefaultTrailblazers returns the built in fixtures: matruff, a public Trailblazer with enough
+// badges of every type to page through, astro, with a few of each, and hidden, whose profile is
+// private.
+func DefaultTrailblazers() []Trailblazer {
+	trailblazers, err := parseTrailblazers(defaultFixtures)
+	if err != nil {
+		panic("trailheadtest: parsing built in fixtures: " + err.Error())
+	}
+
+	return trailblazers
+}
+
+// LoadTrailblazers reads fixtures from a JSON file holding a list of Trailblazers, in the format of
+// fixtures/trailblazers.json.
+func LoadTrailblazers(path string) ([]Trailblazer, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	trailblazers, err := parseTrailblazers(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parsing fixtures %s: %w", path, err)
+	}
+
+	return trailblazers, nil
+}
+
+func parseTrailblazers(raw []byte) ([]Trailblazer, error) {
+	var trailblazers []Trailblazer
+	if err := json.Unmarshal(raw, &trailblazers); err != nil {
+		return nil, err
+	}
+
+	for i, trailblazer := range trailblazers {
+		if trailblazer.Handle == "" {
+			return nil, fmt.Errorf("trailblazer %d has no handle", i+1)
+		}
+	}
+
+	return trailblazers, nil
+}
@@ -0,0 +1,437 @@
+package trailheadtest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/meruff/go-trailhead-leaderboard-api/trailhead"
+)
+
+// AnyOperation fails every operation when passed to Server.Fail.
+const AnyOperation = "*"
+
+// Failure is a canned failure the fake responds with instead of fixture data. A Status of zero
+// drops the connection without responding, like a network error. The response waits for Delay
+// first, i.e. to trip a client's timeout. Times is how many requests fail before the fake recovers,
+// zero fails them all until the failure is cleared.
+type Failure struct {
+	Status     int
+	Body       string
+	RetryAfter time.Duration
+	Delay      time.Duration
+	Times      int
+}
+
+// Server is a fake of Trailhead's GraphQL API and Trailblazer profile pages serving fixture data.
+// It answers GetTrailheadProfile, GetTrailheadRank, GetEarnedSkills, GetUserCertifications and
+// GetTrailheadBadges at GraphqlURL, and profile pages under ProfileURL.
+type Server struct {
+	URL        string
+	GraphqlURL string
+	ProfileURL string
+
+	server *httptest.Server
+
+	mu           sync.Mutex
+	trailblazers []Trailblazer
+	failures     map[string]*Failure
+	calls        map[string]int
+}
+
+// NewServer starts a fake serving the given Trailblazers on a local port. It must be closed once
+// it's no longer needed.
+func NewServer(trailblazers []Trailblazer) *Server {
+	s := &Server{
+		trailblazers: append([]Trailblazer(nil), trailblazers...),
+		failures:     map[string]*Failure{},
+		calls:        map[string]int{},
+	}
+
+	s.server = httptest.NewServer(s)
+	s.URL = s.server.URL
+	s.GraphqlURL = s.URL + "/graphql"
+	s.ProfileURL = s.URL + "/trailblazer/"
+
+	return s
+}
+
+// Close shuts the fake down, waiting for requests in flight to finish.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Client returns a Trailhead client calling the fake.
+func (s *Server) Client() *trailhead.Client {
+	return trailhead.NewClient(s.GraphqlURL, s.ProfileURL)
+}
+
+// Put adds a Trailblazer, or replaces the one with the same handle, i.e. to earn them a badge.
+func (s *Server) Put(trailblazer Trailblazer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.trailblazers {
+		if strings.EqualFold(s.trailblazers[i].Handle, trailblazer.Handle) {
+			s.trailblazers[i] = trailblazer
+			return
+		}
+	}
+
+	s.trailblazers = append(s.trailblazers, trailblazer)
+}
+
+// Fail makes requests for an operation fail, a GraphQL operation name, "ProfilePage" or
+// AnyOperation, until Clear is called or the failure's Times run out. Failures of a particular
+// operation take precedence over AnyOperation.
+func (s *Server) Fail(operation string, failure Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures[operation] = &failure
+}
+
+// Clear removes every failure.
+func (s *Server) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures = map[string]*Failure{}
+}
+
+// Calls returns how many requests for an operation the fake has received, failed ones included.
+func (s *Server) Calls(operation string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.calls[operation]
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/graphql" && r.Method == http.MethodPost:
+		s.serveGraphql(w, r)
+	case strings.HasPrefix(r.URL.Path, "/trailblazer/") && r.Method == http.MethodGet:
+		s.serveProfilePage(w, r, strings.TrimPrefix(r.URL.Path, "/trailblazer/"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// graphqlRequest is the part of a GraphQL payload the fake needs.
+type graphqlRequest struct {
+	OperationName string `json:"operationName"`
+	Variables     struct {
+		Slug   *string `json:"slug"`
+		UserID *string `json:"userId"`
+		Count  *int    `json:"count"`
+		After  *string `json:"after"`
+		Filter *string `json:"filter"`
+	} `json:"variables"`
+}
+
+func (s *Server) serveGraphql(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeGraphqlError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var req graphqlRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeGraphqlError(w, http.StatusBadRequest, "parsing payload: "+err.Error())
+		return
+	}
+
+	if s.fail(w, req.OperationName) {
+		return
+	}
+
+	trailblazer, found := s.lookup(req.Variables.Slug, req.Variables.UserID)
+
+	var response interface{}
+	switch {
+	case !isOperation(req.OperationName):
+		writeGraphqlError(w, http.StatusBadRequest, fmt.Sprintf("unknown operation %q", req.OperationName))
+		return
+	case !found:
+		response = map[string]interface{}{"data": map[string]interface{}{"profile": nil}}
+	case trailblazer.Private:
+		response = map[string]interface{}{"data": map[string]interface{}{"profile": map[string]string{"__typename": "PrivateProfile"}}}
+	default:
+		var err error
+		if response, err = respond(req, trailblazer); err != nil {
+			writeGraphqlError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func isOperation(name string) bool {
+	switch name {
+	case "GetTrailheadProfile", "GetTrailheadRank", "GetEarnedSkills", "GetUserCertifications", "GetTrailheadBadges":
+		return true
+	}
+
+	return false
+}
+
+// respond builds the response to an operation for a public Trailblazer, in the same shape as
+// Trailhead's.
+func respond(req graphqlRequest, trailblazer Trailblazer) (interface{}, error) {
+	switch req.OperationName {
+	case "GetTrailheadProfile":
+		var response trailhead.PublicProfile
+		response.Data.Profile.Typename = "PublicProfile"
+		response.Data.Profile.Profile = trailblazer.Profile
+		return response, nil
+
+	case "GetTrailheadRank":
+		var response trailhead.Rank
+		response.Data.Profile.Typename = "PublicProfile"
+		response.Data.Profile.TrailheadStats = trailblazer.Stats
+		stats := &response.Data.Profile.TrailheadStats
+		stats.Typename = or(stats.Typename, "TrailheadProfileStats")
+		stats.Rank.Typename = or(stats.Rank.Typename, "TrailheadRank")
+		return response, nil
+
+	case "GetEarnedSkills":
+		var response trailhead.Skills
+		response.Data.Profile.Typename = "PublicProfile"
+		response.Data.Profile.EarnedSkills = []trailhead.EarnedSkill{}
+		for _, skill := range trailblazer.Skills {
+			skill.Typename = or(skill.Typename, "EarnedSkill")
+			skill.Skill.Typename = or(skill.Skill.Typename, "Skill")
+			response.Data.Profile.EarnedSkills = append(response.Data.Profile.EarnedSkills, skill)
+		}
+		return response, nil
+
+	case "GetUserCertifications":
+		var response trailhead.Certifications
+		profile := &response.Data.Profile
+		profile.Typename = "PublicProfile"
+		profile.ID = trailblazer.Profile.ID
+		profile.Credential.Certifications = []trailhead.EarnedCertification{}
+		for _, certification := range trailblazer.Certifications {
+			certification.Cta.Typename = or(certification.Cta.Typename, "CredentialCta")
+			certification.Status.Typename = or(certification.Status.Typename, "CredentialStatus")
+			profile.Credential.Certifications = append(profile.Credential.Certifications, certification)
+		}
+		return response, nil
+
+	default:
+		return badgesPage(req, trailblazer)
+	}
+}
+
+// badgesPage returns the page of badges asked for, count (default 8) badges of the type filtered
+// by, if any, after the cursor, if any. Cursors are the position of a badge in the filtered list.
+func badgesPage(req graphqlRequest, trailblazer Trailblazer) (trailhead.Badges, error) {
+	var response trailhead.Badges
+	response.Data.Profile.Typename = "PublicProfile"
+	awards := &response.Data.Profile.EarnedAwards
+	awards.Edges = []trailhead.EarnedAwardEdge{}
+	awards.PageInfo.Typename = "PageInfo"
+
+	count := 8
+	if req.Variables.Count != nil {
+		count = *req.Variables.Count
+	}
+
+	var badges []trailhead.EarnedAward
+	for _, badge := range trailblazer.Badges {
+		if req.Variables.Filter == nil || strings.EqualFold(badge.Award.Type, *req.Variables.Filter) {
+			badges = append(badges, badge)
+		}
+	}
+
+	start := 0
+	if req.Variables.After != nil {
+		position, err := decodeCursor(*req.Variables.After)
+		if err != nil {
+			return response, err
+		}
+		start = position + 1
+	}
+
+	end := start + count
+	if end > len(badges) {
+		end = len(badges)
+	}
+
+	for i := start; i < end; i++ {
+		badge := badges[i]
+		badge.Typename = or(badge.Typename, "EarnedAward")
+		badge.Award.Typename = or(badge.Award.Typename, "Award")
+		badge.Award.Content.Typename = or(badge.Award.Content.Typename, "Content")
+		awards.Edges = append(awards.Edges, trailhead.EarnedAwardEdge{Node: badge})
+	}
+
+	if start < end {
+		awards.PageInfo.StartCursor = encodeCursor(start)
+		awards.PageInfo.EndCursor = encodeCursor(end - 1)
+	}
+	awards.PageInfo.HasPreviousPage = start > 0
+	awards.PageInfo.HasNextPage = end < len(badges)
+
+	return response, nil
+}
+
+func encodeCursor(position int) string {
+	return base64.StdEncoding.EncodeToString([]byte(strconv.Itoa(position)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	raw, err := base64.StdEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cursor %q", cursor)
+	}
+
+	position, err := strconv.Atoi(string(raw))
+	if err != nil || position < 0 {
+		return 0, fmt.Errorf("invalid cursor %q", cursor)
+	}
+
+	return position, nil
+}
+
+// profilePage renders a profile the way Trailhead's pages embed it, as an inline script variable,
+// along with OpenGraph tags.
+var profilePage = template.Must(template.New("profile").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>{{.FirstName}} {{.LastName}} | Trailblazer</title>
+<meta property="og:type" content="profile">
+<meta property="og:title" content="{{.FirstName}} {{.LastName}} | Trailblazer">
+<meta property="og:url" content="{{.ProfileURL}}">
+<meta property="profile:first_name" content="{{.FirstName}}">
+<meta property="profile:last_name" content="{{.LastName}}">
+<meta property="profile:username" content="{{.Username}}">
+<script>var profile = {{.}};</script>
+</head>
+<body></body>
+</html>
+`))
+
+const privateProfilePage = `<!DOCTYPE html>
+<html>
+<head><title>Trailblazer</title></head>
+<body><p>This Trailblazer's profile is private.</p></body>
+</html>
+`
+
+func (s *Server) serveProfilePage(w http.ResponseWriter, r *http.Request, handle string) {
+	if s.fail(w, "ProfilePage") {
+		return
+	}
+
+	trailblazer, found := s.lookup(&handle, nil)
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if trailblazer.Private {
+		fmt.Fprint(w, privateProfilePage)
+		return
+	}
+
+	profilePage.Execute(w, trailblazer.Profile)
+}
+
+// lookup finds a Trailblazer by handle, or by user ID if there's no handle.
+func (s *Server) lookup(handle, userID *string) (Trailblazer, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, trailblazer := range s.trailblazers {
+		if handle != nil && strings.EqualFold(trailblazer.Handle, *handle) {
+			return trailblazer, true
+		}
+		if handle == nil && userID != nil && trailblazer.Profile.ID == *userID {
+			return trailblazer, true
+		}
+	}
+
+	return Trailblazer{}, false
+}
+
+// fail counts a request for an operation and responds with its failure, if it has one. Returns
+// whether it did.
+func (s *Server) fail(w http.ResponseWriter, operation string) bool {
+	s.mu.Lock()
+	s.calls[operation]++
+
+	key := operation
+	failure := s.failures[key]
+	if failure == nil {
+		key = AnyOperation
+		failure = s.failures[key]
+	}
+
+	var current Failure
+	if failure != nil {
+		current = *failure
+		if failure.Times > 0 {
+			failure.Times--
+			if failure.Times == 0 {
+				delete(s.failures, key)
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	if failure == nil {
+		return false
+	}
+
+	time.Sleep(current.Delay)
+
+	if current.Status == 0 {
+		if hijacker, ok := w.(http.Hijacker); ok {
+			if conn, _, err := hijacker.Hijack(); err == nil {
+				conn.Close()
+				return true
+			}
+		}
+		current.Status = http.StatusBadGateway
+	}
+
+	if current.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(current.RetryAfter.Seconds()))))
+	}
+	if current.Body == "" {
+		current.Body = http.StatusText(current.Status)
+	}
+	w.WriteHeader(current.Status)
+	fmt.Fprint(w, current.Body)
+
+	return true
+}
+
+func writeGraphqlError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"errors": []map[string]string{{"message": message}}})
+}
+
+func or(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+
+	return value
+}
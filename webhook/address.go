@@ -0,0 +1,80 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateAddress is returned for webhook URLs on loopback, link-local, private, multicast or
+// unspecified addresses outside the dispatcher's AllowedNetworks.
+var ErrPrivateAddress = errors.New("webhook address is not public")
+
+// CheckURL resolves the host of a webhook URL and returns ErrPrivateAddress if any of its
+// addresses can't be delivered to. Deliveries check the address again when they connect, so a
+// host that is later pointed at a private address is still refused.
+func (d *Dispatcher) CheckURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return err
+	}
+
+	for _, addr := range addrs {
+		if !d.allowed(addr.IP) {
+			return fmt.Errorf("%w: %s is %s", ErrPrivateAddress, u.Hostname(), addr.IP)
+		}
+	}
+
+	return nil
+}
+
+// allowed reports whether deliveries may connect to ip: a public unicast address, or any address
+// in AllowedNetworks.
+func (d *Dispatcher) allowed(ip net.IP) bool {
+	for _, network := range d.AllowedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return ip.IsGlobalUnicast() && !ip.IsPrivate()
+}
+
+// newTransport returns the transport deliveries are sent with. It checks each address after it
+// is resolved, right before connecting, and never uses a proxy since that would hide the address.
+func (d *Dispatcher) newTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !d.allowed(ip) {
+				return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+			}
+
+			return nil
+		},
+	}
+
+	return &http.Transport{
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+}
@@ -0,0 +1,61 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestCheckURL(t *testing.T) {
+	_, office, _ := net.ParseCIDR("10.20.0.0/16")
+
+	tests := []struct {
+		name    string
+		url     string
+		allowed []*net.IPNet
+		wantErr bool
+	}{
+		{name: "public address", url: "https://93.184.216.34/hook"},
+		{name: "public IPv6 address", url: "https://[2606:2800:220:1:248:1893:25c8:1946]/hook"},
+		{name: "loopback", url: "http://127.0.0.1:8080/hook", wantErr: true},
+		{name: "localhost", url: "http://localhost/hook", wantErr: true},
+		{name: "IPv6 loopback", url: "http://[::1]/hook", wantErr: true},
+		{name: "private", url: "http://192.168.1.10/hook", wantErr: true},
+		{name: "link-local metadata", url: "http://169.254.169.254/latest/meta-data", wantErr: true},
+		{name: "unspecified", url: "http://0.0.0.0/hook", wantErr: true},
+		{name: "IPv4-mapped loopback", url: "http://[::ffff:127.0.0.1]/hook", wantErr: true},
+		{name: "allowed private network", url: "http://10.20.3.4/hook", allowed: []*net.IPNet{office}},
+		{name: "private outside allowed network", url: "http://10.30.3.4/hook", allowed: []*net.IPNet{office}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDispatcher(nil, 0, 10)
+			d.AllowedNetworks = tt.allowed
+
+			err := d.CheckURL(context.Background(), tt.url)
+			if tt.wantErr != errors.Is(err, ErrPrivateAddress) {
+				t.Errorf("CheckURL(%q) = %v, want ErrPrivateAddress %v", tt.url, err, tt.wantErr)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("CheckURL(%q) = %v, want no error", tt.url, err)
+			}
+		})
+	}
+}
+
+func TestDeliverRefusesPrivateAddresses(t *testing.T) {
+	rec := newReceiver(t)
+	d := newTestDispatcher(t, 0)
+	d.AllowedNetworks = nil
+
+	delivery := d.Deliver(Subscription{ID: "sub1", URL: rec.URL, Secret: "s3cret"}, badgeEvent())
+
+	if delivery.Delivered || delivery.Attempts != 1 {
+		t.Errorf("Deliver() = %d attempts, delivered %v, want one refused attempt", delivery.Attempts, delivery.Delivered)
+	}
+	if rec.received() != 0 {
+		t.Errorf("receiver got %d requests, want none", rec.received())
+	}
+}
@@ -0,0 +1,279 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/meruff/go-trailhead-leaderboard-api/events"
+)
+
+// Delivery records an attempt to deliver an event to a subscription, including its retries.
+type Delivery struct {
+	ID             string      `json:"id"`
+	SubscriptionID string      `json:"subscriptionId"`
+	EventID        string      `json:"eventId"`
+	EventType      events.Type `json:"eventType"`
+	URL            string      `json:"url"`
+	Attempts       int         `json:"attempts"`
+	StatusCode     int         `json:"statusCode,omitempty"`
+	Error          string      `json:"error,omitempty"`
+	Delivered      bool        `json:"delivered"`
+	CreatedAt      time.Time   `json:"createdAt"`
+	CompletedAt    time.Time   `json:"completedAt"`
+}
+
+// job is an event waiting to be delivered to a subscription.
+type job struct {
+	subscription Subscription
+	event        events.Event
+	queuedAt     time.Time
+}
+
+// Dispatcher delivers events to the subscriptions in a Store from a pool of workers. Failed
+// deliveries are retried with exponential backoff, from InitialBackoff doubling up to MaxBackoff,
+// until MaxAttempts attempts have been made. The outcome of recent deliveries is kept in memory.
+// Deliveries are only made to public addresses, or to private ones in AllowedNetworks.
+type Dispatcher struct {
+	Store           *Store
+	HTTPClient      *http.Client
+	MaxAttempts     int
+	InitialBackoff  time.Duration
+	MaxBackoff      time.Duration
+	AllowedNetworks []*net.IPNet
+
+	queue   chan job
+	pending sync.WaitGroup
+
+	mu         sync.Mutex
+	deliveries []Delivery
+	logSize    int
+}
+
+// NewDispatcher returns a Dispatcher for the subscriptions in store, delivering with the given
+// number of workers and remembering the last logSize deliveries.
+func NewDispatcher(store *Store, workers, logSize int) *Dispatcher {
+	d := &Dispatcher{
+		Store:          store,
+		MaxAttempts:    5,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Minute,
+		queue:          make(chan job, 1000),
+		logSize:        logSize,
+	}
+	d.HTTPClient = &http.Client{Transport: d.newTransport(), Timeout: 10 * time.Second}
+
+	for i := 0; i < workers; i++ {
+		go d.work()
+	}
+
+	return d
+}
+
+// Dispatch queues the event for delivery to every subscription it matches. If the queue is full
+// the delivery is dropped and logged as failed.
+func (d *Dispatcher) Dispatch(event events.Event) {
+	for _, subscription := range d.Store.List() {
+		if !subscription.Matches(event) {
+			continue
+		}
+
+		d.pending.Add(1)
+		select {
+		case d.queue <- job{subscription: subscription, event: event, queuedAt: time.Now()}:
+		default:
+			d.pending.Done()
+			now := time.Now()
+			d.record(Delivery{
+				ID:             events.NewID(),
+				SubscriptionID: subscription.ID,
+				EventID:        event.ID,
+				EventType:      event.Type,
+				URL:            subscription.URL,
+				Error:          "delivery queue is full",
+				CreatedAt:      now,
+				CompletedAt:    now,
+			})
+		}
+	}
+}
+
+// Deliver sends the event to the subscription right away, retrying failures, and returns the
+// outcome.
+func (d *Dispatcher) Deliver(subscription Subscription, event events.Event) Delivery {
+	return d.deliver(context.Background(), job{subscription: subscription, event: event, queuedAt: time.Now()}, d.MaxAttempts)
+}
+
+// Ping sends the event to the subscription once, without retrying, and returns the outcome. The
+// request is cancelled when ctx is done, so a caller serving an HTTP request can bound how long
+// a slow receiver holds it up.
+func (d *Dispatcher) Ping(ctx context.Context, subscription Subscription, event events.Event) Delivery {
+	return d.deliver(ctx, job{subscription: subscription, event: event, queuedAt: time.Now()}, 1)
+}
+
+// Drain waits for every queued delivery to complete, retries included, or for ctx to be done,
+// whichever comes first. Returns ctx's error if deliveries were still queued or in progress.
+func (d *Dispatcher) Drain(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		d.pending.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Deliveries returns the recent deliveries to a subscription, newest first. An empty ID returns
+// deliveries to every subscription.
+func (d *Dispatcher) Deliveries(subscriptionID string) []Delivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	deliveries := []Delivery{}
+	for i := len(d.deliveries) - 1; i >= 0; i-- {
+		if subscriptionID == "" || d.deliveries[i].SubscriptionID == subscriptionID {
+			deliveries = append(deliveries, d.deliveries[i])
+		}
+	}
+
+	return deliveries
+}
+
+// work delivers queued events until the queue is closed.
+func (d *Dispatcher) work() {
+	for j := range d.queue {
+		d.deliver(context.Background(), j, d.MaxAttempts)
+		d.pending.Done()
+	}
+}
+
+// deliver sends a queued event, retrying failures until maxAttempts attempts have been made, and
+// records the outcome.
+func (d *Dispatcher) deliver(ctx context.Context, j job, maxAttempts int) Delivery {
+	delivery := Delivery{
+		ID:             events.NewID(),
+		SubscriptionID: j.subscription.ID,
+		EventID:        j.event.ID,
+		EventType:      j.event.Type,
+		URL:            j.subscription.URL,
+		CreatedAt:      j.queuedAt,
+	}
+
+	body, err := Payload(j.subscription.Format, j.event)
+	if err != nil {
+		delivery.Error = err.Error()
+	}
+
+	for err == nil && delivery.Attempts < maxAttempts {
+		if delivery.Attempts > 0 {
+			time.Sleep(d.backoff(delivery.Attempts))
+		}
+		delivery.Attempts++
+
+		statusCode, retry, sendErr := d.send(ctx, j.subscription, delivery.ID, j.event.Type, body)
+		delivery.StatusCode = statusCode
+		if sendErr == nil {
+			delivery.Delivered, delivery.Error = true, ""
+			break
+		}
+
+		delivery.Error = sendErr.Error()
+		if !retry {
+			break
+		}
+	}
+
+	if !delivery.Delivered {
+		slog.Warn("webhook delivery failed",
+			"event_type", j.event.Type, "event_id", j.event.ID, "webhook_id", j.subscription.ID,
+			"attempts", delivery.Attempts, "error", delivery.Error)
+	}
+
+	delivery.CompletedAt = time.Now()
+	d.record(delivery)
+
+	return delivery
+}
+
+// send posts a signed event body to the subscription's URL once. Returns the response status and
+// whether a failure is worth retrying.
+func (d *Dispatcher) send(ctx context.Context, subscription Subscription, deliveryID string, eventType events.Type, body []byte) (int, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, false, err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-trailhead-leaderboard-api-webhooks")
+	req.Header.Set("X-Webhook-ID", deliveryID)
+	req.Header.Set("X-Webhook-Event", string(eventType))
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Webhook-Signature", "sha256="+Sign(subscription.Secret, timestamp, body))
+
+	resp, err := d.HTTPClient.Do(req)
+	if err != nil {
+		return 0, !errors.Is(err, ErrPrivateAddress), err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.StatusCode, false, nil
+	}
+
+	retry := resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests ||
+		resp.StatusCode >= 500
+
+	return resp.StatusCode, retry, fmt.Errorf("webhook responded %s", resp.Status)
+}
+
+// backoff returns how long to wait before retrying after the given attempt, counting from 1, with
+// 20% jitter so failing receivers aren't hit in lockstep.
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := d.InitialBackoff << (attempt - 1)
+	if delay <= 0 || delay > d.MaxBackoff {
+		delay = d.MaxBackoff
+	}
+
+	return delay + time.Duration(float64(delay)*0.2*(2*rand.Float64()-1))
+}
+
+// record adds a delivery to the log, dropping the oldest once it's full.
+func (d *Dispatcher) record(delivery Delivery) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.deliveries = append(d.deliveries, delivery)
+	if len(d.deliveries) > d.logSize {
+		d.deliveries = d.deliveries[len(d.deliveries)-d.logSize:]
+	}
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of a delivery, sent in the X-Webhook-Signature header.
+// The timestamp is signed along with the body, as "{timestamp}.{body}", so a captured delivery
+// can't be replayed later with a new timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,262 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/meruff/go-trailhead-leaderboard-api/events"
+)
+
+// receiver is a webhook endpoint that records every delivery and answers with the next status in
+// statuses, then 200 once they run out.
+type receiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	t.Helper()
+
+	rec := &receiver{statuses: statuses}
+	rec.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		rec.mu.Lock()
+		rec.requests = append(rec.requests, r)
+		rec.bodies = append(rec.bodies, body)
+		status := http.StatusOK
+		if len(rec.statuses) > 0 {
+			status, rec.statuses = rec.statuses[0], rec.statuses[1:]
+		}
+		rec.mu.Unlock()
+
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(rec.Close)
+
+	return rec
+}
+
+func (rec *receiver) received() int {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	return len(rec.requests)
+}
+
+// newTestDispatcher returns a dispatcher with the given number of workers, backing off for a few
+// milliseconds between attempts and allowed to deliver to loopback receivers, for a store holding
+// the given subscriptions.
+func newTestDispatcher(t *testing.T, workers int, subscriptions ...Subscription) *Dispatcher {
+	t.Helper()
+
+	store, err := OpenStore(filepath.Join(t.TempDir(), "webhooks.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, subscription := range subscriptions {
+		if _, err := store.Add(subscription); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d := NewDispatcher(store, workers, 100)
+	d.InitialBackoff = time.Millisecond
+	d.MaxBackoff = 5 * time.Millisecond
+	d.AllowedNetworks = []*net.IPNet{{IP: net.IPv4(127, 0, 0, 0), Mask: net.CIDRMask(8, 32)}}
+
+	return d
+}
+
+func TestDeliverSignsRequests(t *testing.T) {
+	rec := newReceiver(t)
+	d := newTestDispatcher(t, 0)
+	subscription := Subscription{ID: "sub1", URL: rec.URL, Secret: "s3cret"}
+
+	delivery := d.Deliver(subscription, badgeEvent())
+	if !delivery.Delivered {
+		t.Fatalf("Deliver() = %+v, want delivered", delivery)
+	}
+
+	req, body := rec.requests[0], rec.bodies[0]
+
+	timestamp, err := strconv.ParseInt(req.Header.Get("X-Webhook-Timestamp"), 10, 64)
+	if err != nil {
+		t.Fatalf("X-Webhook-Timestamp = %q, want a unix time", req.Header.Get("X-Webhook-Timestamp"))
+	}
+	if age := time.Since(time.Unix(timestamp, 0)); age < 0 || age > time.Minute {
+		t.Errorf("X-Webhook-Timestamp is %v old, want the time of sending", age)
+	}
+
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	if got, want := req.Header.Get("X-Webhook-Signature"), "sha256="+hex.EncodeToString(mac.Sum(nil)); got != want {
+		t.Errorf("X-Webhook-Signature = %q, want %q", got, want)
+	}
+
+	if got := req.Header.Get("X-Webhook-ID"); got != delivery.ID {
+		t.Errorf("X-Webhook-ID = %q, want the delivery ID %q", got, delivery.ID)
+	}
+	if got := req.Header.Get("X-Webhook-Event"); got != string(events.BadgeEarned) {
+		t.Errorf("X-Webhook-Event = %q, want %q", got, events.BadgeEarned)
+	}
+	if got := req.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+}
+
+func TestDeliverSendsFormattedBody(t *testing.T) {
+	for _, format := range Formats {
+		t.Run(string(format), func(t *testing.T) {
+			rec := newReceiver(t)
+			d := newTestDispatcher(t, 0)
+
+			d.Deliver(Subscription{ID: "sub1", URL: rec.URL, Format: format, Secret: "s3cret"}, badgeEvent())
+
+			want, err := Payload(format, badgeEvent())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rec.received() != 1 || string(rec.bodies[0]) != string(want) {
+				t.Errorf("received %d deliveries, want one with body %s", rec.received(), want)
+			}
+		})
+	}
+}
+
+func TestDeliverRetries(t *testing.T) {
+	tests := []struct {
+		name          string
+		statuses      []int
+		maxAttempts   int
+		wantAttempts  int
+		wantDelivered bool
+		wantStatus    int
+	}{
+		{name: "recovers after server errors", statuses: []int{503, 500}, maxAttempts: 5, wantAttempts: 3, wantDelivered: true, wantStatus: 200},
+		{name: "retries too many requests", statuses: []int{429}, maxAttempts: 5, wantAttempts: 2, wantDelivered: true, wantStatus: 200},
+		{name: "gives up after max attempts", statuses: []int{502, 502, 502}, maxAttempts: 3, wantAttempts: 3, wantStatus: 502},
+		{name: "does not retry client errors", statuses: []int{410}, maxAttempts: 5, wantAttempts: 1, wantStatus: 410},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := newReceiver(t, tt.statuses...)
+			d := newTestDispatcher(t, 0)
+			d.MaxAttempts = tt.maxAttempts
+
+			delivery := d.Deliver(Subscription{ID: "sub1", URL: rec.URL, Secret: "s3cret"}, badgeEvent())
+
+			if delivery.Attempts != tt.wantAttempts || delivery.Delivered != tt.wantDelivered || delivery.StatusCode != tt.wantStatus {
+				t.Errorf("Deliver() = %d attempts, delivered %v, status %d, want %d, %v, %d",
+					delivery.Attempts, delivery.Delivered, delivery.StatusCode, tt.wantAttempts, tt.wantDelivered, tt.wantStatus)
+			}
+			if rec.received() != tt.wantAttempts {
+				t.Errorf("receiver got %d requests, want %d", rec.received(), tt.wantAttempts)
+			}
+			if tt.wantDelivered == (delivery.Error != "") {
+				t.Errorf("Error = %q with delivered %v", delivery.Error, delivery.Delivered)
+			}
+		})
+	}
+}
+
+func TestDrainWaitsForDeliveries(t *testing.T) {
+	release := make(chan struct{})
+	var received sync.WaitGroup
+	received.Add(1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Done()
+		<-release
+	}))
+	defer server.Close()
+
+	d := newTestDispatcher(t, 1, Subscription{ID: "sub1", URL: server.URL})
+	d.Dispatch(badgeEvent())
+	received.Wait()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := d.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Drain() with a delivery in flight = %v, want context.DeadlineExceeded", err)
+	}
+
+	close(release)
+
+	if err := d.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain() = %v", err)
+	}
+	if deliveries := d.Deliveries("sub1"); len(deliveries) != 1 || !deliveries[0].Delivered {
+		t.Errorf("Deliveries() = %+v after Drain, want the completed delivery", deliveries)
+	}
+}
+
+func TestDrainWaitsForRetries(t *testing.T) {
+	rec := newReceiver(t, 503, 503)
+	d := newTestDispatcher(t, 1, Subscription{ID: "sub1", URL: rec.URL})
+	d.InitialBackoff, d.MaxBackoff = 20*time.Millisecond, 20*time.Millisecond
+
+	d.Dispatch(badgeEvent())
+
+	if err := d.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if rec.received() != 3 {
+		t.Errorf("receiver got %d requests by the time Drain returned, want all 3 attempts", rec.received())
+	}
+}
+
+func TestPingMakesOneAttempt(t *testing.T) {
+	rec := newReceiver(t, 503)
+	d := newTestDispatcher(t, 0)
+
+	delivery := d.Ping(context.Background(), Subscription{ID: "sub1", URL: rec.URL, Secret: "s3cret"}, badgeEvent())
+
+	if delivery.Attempts != 1 || delivery.Delivered || delivery.StatusCode != 503 {
+		t.Errorf("Ping() = %d attempts, delivered %v, status %d, want 1, false, 503",
+			delivery.Attempts, delivery.Delivered, delivery.StatusCode)
+	}
+	if rec.received() != 1 {
+		t.Errorf("receiver got %d requests, want 1", rec.received())
+	}
+}
+
+func TestPingGivesUpWhenContextIsDone(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+	d := newTestDispatcher(t, 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	delivery := d.Ping(ctx, Subscription{ID: "sub1", URL: server.URL, Secret: "s3cret"}, badgeEvent())
+
+	if delivery.Delivered || delivery.Attempts != 1 || delivery.Error == "" {
+		t.Errorf("Ping() = %+v, want one failed attempt", delivery)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Ping() took %s, want it to stop once ctx is done", elapsed)
+	}
+}
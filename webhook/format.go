@@ -0,0 +1,206 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/meruff/go-trailhead-leaderboard-api/badge"
+	"github.com/meruff/go-trailhead-leaderboard-api/events"
+)
+
+// Format is how an event is written in the body of a delivery.
+type Format string
+
+const (
+	// FormatJSON sends the event itself.
+	FormatJSON Format = "json"
+	// FormatSlack sends a Slack Block Kit message, for Slack incoming webhooks.
+	FormatSlack Format = "slack"
+	// FormatTeams sends an Adaptive Card, for Microsoft Teams incoming webhooks and workflows.
+	FormatTeams Format = "teams"
+)
+
+// Formats are the formats a subscription can choose.
+var Formats = []Format{FormatJSON, FormatSlack, FormatTeams}
+
+// Payload returns the body of a delivery of the event in the given format. An empty format is
+// FormatJSON.
+func Payload(format Format, event events.Event) ([]byte, error) {
+	switch format {
+	case "", FormatJSON:
+		return json.Marshal(event)
+	case FormatSlack:
+		return json.Marshal(slackPayload(newMessage(event)))
+	case FormatTeams:
+		return json.Marshal(teamsPayload(newMessage(event)))
+	default:
+		return nil, fmt.Errorf("unknown webhook format %q", format)
+	}
+}
+
+// message is the chat message for an event, before it's written for a particular chat app. Headline
+// and Text are written in markdown, with names wrapped in ** for bold.
+type message struct {
+	Fallback  string
+	Headline  string
+	Text      string
+	ImageURL  string
+	ImageAlt  string
+	LinkURL   string
+	LinkLabel string
+	Facts     []fact
+}
+
+// fact is a labelled value shown under a message.
+type fact struct {
+	Title string
+	Value string
+}
+
+// newMessage describes an event for people.
+func newMessage(event events.Event) message {
+	who := event.Name
+	if who == "" {
+		who = event.Handle
+	}
+
+	switch {
+	case event.Type == events.BadgeEarned && event.Badge != nil:
+		return message{
+			Fallback:  fmt.Sprintf("%s earned the %s badge", who, event.Badge.Title),
+			Headline:  fmt.Sprintf("**%s** earned the **%s** badge", who, event.Badge.Title),
+			Text:      event.Badge.Description,
+			ImageURL:  event.Badge.Icon,
+			ImageAlt:  event.Badge.Title,
+			LinkURL:   event.Badge.URL,
+			LinkLabel: "View badge",
+		}
+	case event.Type == events.RankUp && event.Rank != nil:
+		return message{
+			Fallback: fmt.Sprintf("%s ranked up to %s", who, event.Rank.Title),
+			Headline: fmt.Sprintf("**%s** ranked up from %s to **%s**", who, event.Rank.Previous, event.Rank.Title),
+			ImageURL: event.Rank.ImageURL,
+			ImageAlt: event.Rank.Title,
+			Facts: []fact{
+				{Title: "Points", Value: badge.FormatCount(event.Rank.Points)},
+				{Title: "Badges", Value: badge.FormatCount(event.Rank.Badges)},
+			},
+		}
+	case event.Type == events.CertificationEarned && event.Certification != nil:
+		return message{
+			Fallback:  fmt.Sprintf("%s earned the %s certification", who, event.Certification.Title),
+			Headline:  fmt.Sprintf("**%s** earned the **%s** certification", who, event.Certification.Title),
+			ImageURL:  event.Certification.LogoURL,
+			ImageAlt:  event.Certification.Title,
+			LinkURL:   event.Certification.InfoURL,
+			LinkLabel: "About this certification",
+		}
+	case event.Type == events.CertificationExpired && event.Certification != nil:
+		return message{
+			Fallback:  fmt.Sprintf("%s's %s certification expired", who, event.Certification.Title),
+			Headline:  fmt.Sprintf("**%s**'s **%s** certification expired", who, event.Certification.Title),
+			ImageURL:  event.Certification.LogoURL,
+			ImageAlt:  event.Certification.Title,
+			LinkURL:   event.Certification.InfoURL,
+			LinkLabel: "About this certification",
+		}
+	default:
+		text := fmt.Sprintf("Test message from the Trailhead Leaderboard API (%s event).", event.Type)
+		return message{Fallback: text, Headline: text}
+	}
+}
+
+// slackPayload writes a message as Slack Block Kit blocks, with the image beside the text.
+func slackPayload(m message) map[string]interface{} {
+	text := slackEscape(m.Headline)
+	if m.Text != "" {
+		text += "\n" + slackEscape(m.Text)
+	}
+
+	section := map[string]interface{}{
+		"type": "section",
+		"text": map[string]interface{}{"type": "mrkdwn", "text": text},
+	}
+	if m.ImageURL != "" {
+		section["accessory"] = map[string]interface{}{"type": "image", "image_url": m.ImageURL, "alt_text": m.ImageAlt}
+	}
+	if len(m.Facts) > 0 {
+		var fields []map[string]interface{}
+		for _, f := range m.Facts {
+			fields = append(fields, map[string]interface{}{
+				"type": "mrkdwn",
+				"text": fmt.Sprintf("*%s*\n%s", slackEscape(f.Title), slackEscape(f.Value)),
+			})
+		}
+		section["fields"] = fields
+	}
+
+	blocks := []interface{}{section}
+	if m.LinkURL != "" {
+		blocks = append(blocks, map[string]interface{}{
+			"type": "actions",
+			"elements": []interface{}{map[string]interface{}{
+				"type": "button",
+				"text": map[string]interface{}{"type": "plain_text", "text": m.LinkLabel},
+				"url":  m.LinkURL,
+			}},
+		})
+	}
+
+	return map[string]interface{}{"text": slackEscape(m.Fallback), "blocks": blocks}
+}
+
+// slackEscape escapes the characters Slack treats as markup, and turns markdown bold into Slack's
+// single asterisks.
+func slackEscape(text string) string {
+	text = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+	return strings.ReplaceAll(text, "**", "*")
+}
+
+// teamsPayload writes a message as an Adaptive Card in the message envelope Teams expects, with the
+// image in a column beside the text.
+func teamsPayload(m message) map[string]interface{} {
+	textColumn := []interface{}{
+		map[string]interface{}{"type": "TextBlock", "text": m.Headline, "wrap": true, "size": "Medium"},
+	}
+	if m.Text != "" {
+		textColumn = append(textColumn, map[string]interface{}{"type": "TextBlock", "text": m.Text, "wrap": true, "isSubtle": true})
+	}
+	if len(m.Facts) > 0 {
+		var facts []map[string]interface{}
+		for _, f := range m.Facts {
+			facts = append(facts, map[string]interface{}{"title": f.Title, "value": f.Value})
+		}
+		textColumn = append(textColumn, map[string]interface{}{"type": "FactSet", "facts": facts})
+	}
+
+	var columns []interface{}
+	if m.ImageURL != "" {
+		columns = append(columns, map[string]interface{}{
+			"type":  "Column",
+			"width": "auto",
+			"items": []interface{}{map[string]interface{}{"type": "Image", "url": m.ImageURL, "altText": m.ImageAlt, "size": "Medium"}},
+		})
+	}
+	columns = append(columns, map[string]interface{}{"type": "Column", "width": "stretch", "items": textColumn})
+
+	card := map[string]interface{}{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"body":    []interface{}{map[string]interface{}{"type": "ColumnSet", "columns": columns}},
+	}
+	if m.LinkURL != "" {
+		card["actions"] = []interface{}{map[string]interface{}{"type": "Action.OpenUrl", "title": m.LinkLabel, "url": m.LinkURL}}
+	}
+
+	return map[string]interface{}{
+		"type":    "message",
+		"summary": m.Fallback,
+		"attachments": []interface{}{map[string]interface{}{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content":     card,
+		}},
+	}
+}
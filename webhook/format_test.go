@@ -0,0 +1,168 @@
+package webhook
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/meruff/go-trailhead-leaderboard-api/events"
+)
+
+func badgeEvent() events.Event {
+	return events.Event{
+		ID:         "evt1",
+		Type:       events.BadgeEarned,
+		Handle:     "astro",
+		Name:       "Astro <Nomer>",
+		OccurredAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Badge: &events.Badge{
+			ID:          "b1",
+			Title:       "Apex Basics",
+			Icon:        "https://example.com/apex.png",
+			URL:         "https://trailhead.salesforce.com/content/learn/modules/apex_basics",
+			Description: "Learn Apex & more.",
+		},
+	}
+}
+
+func rankEvent() events.Event {
+	return events.Event{
+		ID:   "evt2",
+		Type: events.RankUp,
+		Name: "Astro",
+		Rank: &events.Rank{Title: "Ranger", Previous: "Mountaineer", ImageURL: "https://example.com/ranger.png", Points: 50200, Badges: 101},
+	}
+}
+
+// decode unmarshals a payload into generic JSON values for the tests to walk.
+func decode(t *testing.T, raw []byte) map[string]interface{} {
+	t.Helper()
+
+	var v map[string]interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		t.Fatalf("payload isn't valid JSON: %v\n%s", err, raw)
+	}
+
+	return v
+}
+
+// at walks a decoded payload along a path of object keys and array indexes.
+func at(t *testing.T, v interface{}, path ...interface{}) interface{} {
+	t.Helper()
+
+	for _, step := range path {
+		switch key := step.(type) {
+		case string:
+			object, ok := v.(map[string]interface{})
+			if !ok {
+				t.Fatalf("no object at %v to look up %q in", path, key)
+			}
+			v = object[key]
+		case int:
+			array, ok := v.([]interface{})
+			if !ok || key >= len(array) {
+				t.Fatalf("no element %d at %v", key, path)
+			}
+			v = array[key]
+		}
+	}
+
+	return v
+}
+
+func TestPayloadJSON(t *testing.T) {
+	for _, format := range []Format{"", FormatJSON} {
+		raw, err := Payload(format, badgeEvent())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got events.Event
+		if err := json.Unmarshal(raw, &got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, badgeEvent()) {
+			t.Errorf("Payload(%q) = %s, want the event itself", format, raw)
+		}
+	}
+}
+
+func TestPayloadSlack(t *testing.T) {
+	raw, err := Payload(FormatSlack, badgeEvent())
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := decode(t, raw)
+
+	if got, want := at(t, v, "text"), "Astro &lt;Nomer&gt; earned the Apex Basics badge"; got != want {
+		t.Errorf("text = %q, want %q", got, want)
+	}
+	if got, want := at(t, v, "blocks", 0, "text", "text"), "*Astro &lt;Nomer&gt;* earned the *Apex Basics* badge\nLearn Apex &amp; more."; got != want {
+		t.Errorf("section text = %q, want %q", got, want)
+	}
+	if got := at(t, v, "blocks", 0, "accessory", "image_url"); got != "https://example.com/apex.png" {
+		t.Errorf("accessory image = %v, want the badge icon", got)
+	}
+	if got := at(t, v, "blocks", 1, "elements", 0, "url"); got != badgeEvent().Badge.URL {
+		t.Errorf("button url = %v, want the badge URL", got)
+	}
+
+	raw, err = Payload(FormatSlack, rankEvent())
+	if err != nil {
+		t.Fatal(err)
+	}
+	v = decode(t, raw)
+
+	if got := at(t, v, "blocks", 0, "fields", 0, "text"); got != "*Points*\n50,200" {
+		t.Errorf("points field = %q, want the formatted points", got)
+	}
+	if blocks := at(t, v, "blocks").([]interface{}); len(blocks) != 1 {
+		t.Errorf("rank message has %d blocks, want no button without a link", len(blocks))
+	}
+}
+
+func TestPayloadTeams(t *testing.T) {
+	raw, err := Payload(FormatTeams, badgeEvent())
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := decode(t, raw)
+
+	if got := at(t, v, "type"); got != "message" {
+		t.Errorf("type = %v, want message", got)
+	}
+	if got := at(t, v, "attachments", 0, "contentType"); got != "application/vnd.microsoft.card.adaptive" {
+		t.Errorf("contentType = %v, want an adaptive card", got)
+	}
+
+	card := at(t, v, "attachments", 0, "content")
+	if got := at(t, card, "type"); got != "AdaptiveCard" {
+		t.Errorf("content type = %v, want AdaptiveCard", got)
+	}
+	if got := at(t, card, "body", 0, "columns", 0, "items", 0, "url"); got != "https://example.com/apex.png" {
+		t.Errorf("image = %v, want the badge icon", got)
+	}
+	if got := at(t, card, "body", 0, "columns", 1, "items", 0, "text"); got != "**Astro <Nomer>** earned the **Apex Basics** badge" {
+		t.Errorf("headline = %q", got)
+	}
+	if got := at(t, card, "actions", 0, "url"); got != badgeEvent().Badge.URL {
+		t.Errorf("action url = %v, want the badge URL", got)
+	}
+
+	raw, err = Payload(FormatTeams, rankEvent())
+	if err != nil {
+		t.Fatal(err)
+	}
+	facts := at(t, decode(t, raw), "attachments", 0, "content", "body", 0, "columns", 1, "items", 1, "facts")
+	if got := at(t, facts, 1, "value"); got != "101" {
+		t.Errorf("badges fact = %v, want 101", got)
+	}
+}
+
+func TestPayloadUnknownFormat(t *testing.T) {
+	if _, err := Payload("discord", badgeEvent()); err == nil || !strings.Contains(err.Error(), "discord") {
+		t.Errorf("Payload(discord) error = %v, want an unknown format error", err)
+	}
+}
@@ -0,0 +1,155 @@
+package webhook
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/meruff/go-trailhead-leaderboard-api/events"
+	"github.com/meruff/go-trailhead-leaderboard-api/jsonfile"
+)
+
+// ErrNotFound is returned when a subscription doesn't exist in the Store.
+var ErrNotFound = errors.New("webhook not found")
+
+// Subscription is a URL events are delivered to, written in Format. Events and Leaderboards narrow
+// which events are sent, every event is sent when they're empty. Each delivery is signed with
+// Secret.
+type Subscription struct {
+	ID           string        `json:"id"`
+	URL          string        `json:"url"`
+	Format       Format        `json:"format,omitempty"`
+	Secret       string        `json:"secret,omitempty"`
+	Events       []events.Type `json:"events,omitempty"`
+	Leaderboards []string      `json:"leaderboards,omitempty"`
+	CreatedAt    time.Time     `json:"createdAt"`
+}
+
+// Matches reports whether the event should be delivered to the subscription. Pings always match.
+func (s Subscription) Matches(event events.Event) bool {
+	if event.Type == events.Ping {
+		return true
+	}
+
+	if len(s.Events) > 0 && !containsType(s.Events, event.Type) {
+		return false
+	}
+
+	if len(s.Leaderboards) == 0 {
+		return true
+	}
+	for _, name := range event.Leaderboards {
+		for _, wanted := range s.Leaderboards {
+			if name == wanted {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Store keeps webhook subscriptions in memory, writing them to a JSON file on every change.
+type Store struct {
+	path string
+
+	mu            sync.RWMutex
+	subscriptions map[string]Subscription
+}
+
+// OpenStore loads the Store saved at path. A missing file is treated as an empty store.
+func OpenStore(path string) (*Store, error) {
+	s := &Store{path: path, subscriptions: map[string]Subscription{}}
+
+	if err := jsonfile.Load(path, &s.subscriptions); err != nil {
+		return nil, err
+	}
+	if s.subscriptions == nil {
+		s.subscriptions = map[string]Subscription{}
+	}
+
+	return s, nil
+}
+
+// List returns every subscription, oldest first.
+func (s *Store) List() []Subscription {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	subscriptions := make([]Subscription, 0, len(s.subscriptions))
+	for _, subscription := range s.subscriptions {
+		subscriptions = append(subscriptions, subscription)
+	}
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return subscriptions[i].CreatedAt.Before(subscriptions[j].CreatedAt)
+	})
+
+	return subscriptions
+}
+
+// Get returns the subscription with the given ID.
+func (s *Store) Get(id string) (Subscription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	subscription, ok := s.subscriptions[id]
+	if !ok {
+		return Subscription{}, ErrNotFound
+	}
+
+	return subscription, nil
+}
+
+// Add saves a new subscription, filling in its ID, format, secret and creation time if they're
+// unset.
+func (s *Store) Add(subscription Subscription) (Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if subscription.ID == "" {
+		subscription.ID = events.NewID()
+	}
+	if subscription.Format == "" {
+		subscription.Format = FormatJSON
+	}
+	if subscription.Secret == "" {
+		subscription.Secret = events.NewID() + events.NewID()
+	}
+	if subscription.CreatedAt.IsZero() {
+		subscription.CreatedAt = time.Now()
+	}
+
+	s.subscriptions[subscription.ID] = subscription
+
+	return subscription, jsonfile.Save(s.path, s.subscriptions)
+}
+
+// Delete removes a subscription.
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.subscriptions[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.subscriptions, id)
+
+	return jsonfile.Save(s.path, s.subscriptions)
+}
+
+// containsType reports whether t is in types.
+func containsType(types []events.Type, t events.Type) bool {
+	for _, v := range types {
+		if v == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Check reports whether the file the Store is saved to can still be read and written.
+func (s *Store) Check() error {
+	return jsonfile.Check(s.path)
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/meruff/go-trailhead-leaderboard-api/config"
+	"github.com/meruff/go-trailhead-leaderboard-api/events"
+	"github.com/meruff/go-trailhead-leaderboard-api/webhook"
+)
+
+// webhooks delivers events to the registered webhook subscriptions.
+var webhooks *webhook.Dispatcher
+
+// webhookRequest is the body accepted when registering a webhook.
+type webhookRequest struct {
+	URL          string         `json:"url"`
+	Format       webhook.Format `json:"format"`
+	Secret       string         `json:"secret"`
+	Events       []events.Type  `json:"events"`
+	Leaderboards []string       `json:"leaderboards"`
+}
+
+// webhooksFromConfig opens the webhook subscriptions saved at the given path, webhooks.json if
+// empty, and builds their dispatcher from the webhooks settings. Private addresses are refused
+// unless they're in one of the allowed networks.
+func webhooksFromConfig(path string, conf config.Webhooks) (*webhook.Dispatcher, error) {
+	if path == "" {
+		path = "webhooks.json"
+	}
+
+	subscriptions, err := webhook.OpenStore(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening webhook store %s: %w", path, err)
+	}
+
+	dispatcher := webhook.NewDispatcher(subscriptions, 4, 500)
+	dispatcher.MaxAttempts = config.Or(conf.MaxAttempts, dispatcher.MaxAttempts)
+	dispatcher.HTTPClient.Timeout = conf.Timeout.Or(dispatcher.HTTPClient.Timeout)
+
+	for _, cidr := range conf.AllowedNetworks {
+		// Checked by config to be a valid network.
+		_, network, _ := net.ParseCIDR(cidr)
+		dispatcher.AllowedNetworks = append(dispatcher.AllowedNetworks, network)
+	}
+
+	return dispatcher, nil
+}
+
+// memberStatesFromConfig opens the member state saved at the given path, events.json if empty.
+func memberStatesFromConfig(path string) (*events.StateStore, error) {
+	if path == "" {
+		path = "events.json"
+	}
+
+	states, err := events.OpenStateStore(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening event state store %s: %w", path, err)
+	}
+
+	return states, nil
+}
+
+// webhooksHandler lists the registered webhooks, without their secrets.
+func webhooksHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdminKey(w, r) {
+		return
+	}
+
+	subscriptions := webhooks.Store.List()
+	for i := range subscriptions {
+		subscriptions[i].Secret = ""
+	}
+
+	encodeAndWriteToBrowser(w, subscriptions)
+}
+
+// postWebhookHandler registers a webhook. The response is the only time its secret is returned.
+func postWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdminKey(w, r) {
+		return
+	}
+
+	var body webhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeErrorToBrowser(w, `Expected a JSON body like {"url": "https://...", "events": ["badge.earned"]}.`, 400)
+		return
+	}
+
+	if target, err := url.Parse(body.URL); err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		writeErrorToBrowser(w, "Expected url to be an absolute http or https URL.", 400)
+		return
+	}
+
+	if err := webhooks.CheckURL(r.Context(), body.URL); errors.Is(err, webhook.ErrPrivateAddress) {
+		writeErrorToBrowser(w, "Expected url to be on a public address.", 400)
+		return
+	} else if err != nil {
+		writeErrorToBrowser(w, "Couldn't resolve the url's host.", 400)
+		return
+	}
+
+	if body.Format != "" && !containsFormat(webhook.Formats, body.Format) {
+		writeErrorToBrowser(w, "Expected format to be one of: json, slack, teams.", 400)
+		return
+	}
+
+	for _, eventType := range body.Events {
+		if !containsEventType(events.Types, eventType) {
+			writeErrorToBrowser(w, fmt.Sprintf("Expected events to be any of: %s.", eventTypeList()), 400)
+			return
+		}
+	}
+
+	for _, name := range body.Leaderboards {
+		if _, err := store.Get(name); err != nil {
+			writeErrorToBrowser(w, fmt.Sprintf("No leaderboard named %s.", name), 400)
+			return
+		}
+	}
+
+	subscription, err := webhooks.Store.Add(webhook.Subscription{
+		URL:          body.URL,
+		Format:       body.Format,
+		Secret:       body.Secret,
+		Events:       body.Events,
+		Leaderboards: body.Leaderboards,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "saving webhook", "error", err)
+		writeErrorToBrowser(w, "Problem saving webhook.", 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(subscription)
+}
+
+// webhookHandler returns a registered webhook, without its secret.
+func webhookHandler(w http.ResponseWriter, r *http.Request) {
+	subscription, ok := getWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	subscription.Secret = ""
+	encodeAndWriteToBrowser(w, subscription)
+}
+
+// deleteWebhookHandler removes a registered webhook.
+func deleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdminKey(w, r) {
+		return
+	}
+
+	err := webhooks.Store.Delete(mux.Vars(r)["id"])
+	if errors.Is(err, webhook.ErrNotFound) {
+		writeErrorToBrowser(w, fmt.Sprintf("No webhook with the ID %s.", mux.Vars(r)["id"]), 404)
+		return
+	}
+
+	if err != nil {
+		slog.ErrorContext(r.Context(), "deleting webhook", "webhook_id", mux.Vars(r)["id"], "error", err)
+		writeErrorToBrowser(w, "Problem deleting webhook.", 500)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// webhookDeliveriesHandler returns the recent deliveries to a webhook, newest first.
+func webhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	subscription, ok := getWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	encodeAndWriteToBrowser(w, webhooks.Deliveries(subscription.ID))
+}
+
+// pingTimeout bounds the single attempt made to deliver a ping, well within HTTP_WRITE_TIMEOUT.
+const pingTimeout = 5 * time.Second
+
+// pingWebhookHandler sends a ping event to a webhook right away and returns how the delivery went.
+// The ping is tried once, without the retries of a regular delivery, and gives up after
+// pingTimeout.
+func pingWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	subscription, ok := getWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), pingTimeout)
+	defer cancel()
+
+	encodeAndWriteToBrowser(w, webhooks.Ping(ctx, subscription, events.Event{
+		ID:         events.NewID(),
+		Type:       events.Ping,
+		OccurredAt: time.Now(),
+	}))
+}
+
+// getWebhook returns the webhook named in the URL. Writes an error to the browser and returns false
+// if the request isn't from an admin or there's no such webhook.
+func getWebhook(w http.ResponseWriter, r *http.Request) (webhook.Subscription, bool) {
+	if !requireAdminKey(w, r) {
+		return webhook.Subscription{}, false
+	}
+
+	subscription, err := webhooks.Store.Get(mux.Vars(r)["id"])
+	if err != nil {
+		writeErrorToBrowser(w, fmt.Sprintf("No webhook with the ID %s.", mux.Vars(r)["id"]), 404)
+		return webhook.Subscription{}, false
+	}
+
+	return subscription, true
+}
+
+// containsEventType checks if an event type exists inside a slice.
+func containsEventType(types []events.Type, t events.Type) bool {
+	for _, v := range types {
+		if v == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+// containsFormat checks if a webhook format exists inside a slice.
+func containsFormat(formats []webhook.Format, f webhook.Format) bool {
+	for _, v := range formats {
+		if v == f {
+			return true
+		}
+	}
+
+	return false
+}
+
+// eventTypeList returns the event types webhooks can subscribe to, comma separated.
+func eventTypeList() string {
+	names := make([]string, len(events.Types))
+	for i, t := range events.Types {
+		names[i] = string(t)
+	}
+
+	return strings.Join(names, ", ")
+}